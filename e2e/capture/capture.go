@@ -0,0 +1,157 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Форматы записи трафика
+const (
+	// pcapng с направлением кадров, открывается в Wireshark
+	FormatPcap = "pcap"
+	// Текстовый лог: время, направление и байты кадра в hex
+	FormatHex = "hex"
+)
+
+// Тип канального уровня pcap. Используются пользовательские DLT:
+// в Wireshark протокол задается в Preferences > Protocols > DLT_USER
+const (
+	// LINKTYPE_USER0 - modbus rtu (mbrtu)
+	LinkRTU uint16 = 147
+	// LINKTYPE_USER1 - modbus tcp (mbtcp)
+	LinkTCP uint16 = 148
+	// LINKTYPE_USER2 - modbus ascii
+	LinkASCII uint16 = 149
+	// LINKTYPE_USER3 - произвольный протокол
+	LinkCustom uint16 = 150
+)
+
+// Формат времени в hex логе, микросекунды
+const TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// Direction - направление кадра
+type Direction int
+
+const (
+	// Принятый кадр
+	RX Direction = iota
+	// Отправленный кадр
+	TX
+)
+
+func (d Direction) String() string {
+	if d == TX {
+		return "TX"
+	}
+	return "RX"
+}
+
+// Capture - запись кадров с временем и направлением
+type Capture interface {
+	Frame(direction Direction, data []byte)
+	Close() error
+}
+
+// ParseSpec - разбирает настройку вида "pcap=path" или "hex=path"
+func ParseSpec(spec string) (format string, path string, err error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("expected format=path")
+	}
+	format = strings.ToLower(parts[0])
+	switch format {
+	case FormatPcap, FormatHex:
+	default:
+		return "", "", fmt.Errorf("unknown capture format %s", parts[0])
+	}
+	return format, parts[1], nil
+}
+
+// Validate - проверяет настройку записи трафика. Пустая настройка отключает запись
+func Validate(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	_, _, err := ParseSpec(spec)
+	return err
+}
+
+// Open - создает файл записи трафика по настройке spec. link - тип кадров для pcap
+func Open(spec string, link uint16) (Capture, error) {
+	format, path, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var writer frameWriter
+	switch format {
+	case FormatPcap:
+		pcap := &pcapWriter{}
+		if err := pcap.header(file, link); err != nil {
+			file.Close()
+			return nil, err
+		}
+		writer = pcap
+	default:
+		writer = &hexWriter{}
+	}
+	return &fileCapture{file: file, writer: writer}, nil
+}
+
+// timedCapture - запись кадра с временем его начала
+type timedCapture interface {
+	frameAt(t time.Time, direction Direction, data []byte)
+}
+
+// frameWriter - формат записи одного кадра
+type frameWriter interface {
+	write(file *os.File, t time.Time, direction Direction, data []byte) error
+}
+
+type fileCapture struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer frameWriter
+}
+
+// Frame - записывает кадр. Ошибка записи не должна прерывать тесты, поэтому игнорируется
+func (fc *fileCapture) Frame(direction Direction, data []byte) {
+	fc.frameAt(time.Now(), direction, data)
+}
+
+func (fc *fileCapture) frameAt(t time.Time, direction Direction, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.file == nil {
+		return
+	}
+	_ = fc.writer.write(fc.file, t, direction, data)
+}
+
+func (fc *fileCapture) Close() error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.file == nil {
+		return nil
+	}
+	err := fc.file.Close()
+	fc.file = nil
+	return err
+}
+
+type hexWriter struct{}
+
+// write - строка вида "2006-01-02T15:04:05.000000Z TX 01 03 00 00 00 01 84 0a"
+func (hexWriter) write(file *os.File, t time.Time, direction Direction, data []byte) error {
+	_, err := fmt.Fprintf(file, "%s %s % x\n", t.UTC().Format(TimeFormat), direction, data)
+	return err
+}
@@ -0,0 +1,172 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/schnack/gotest"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	format, path, err := ParseSpec("HEX=traffic.log")
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(format + ":" + path).Eq("hex:traffic.log"); err != nil {
+		t.Error(err)
+	}
+
+	for _, spec := range []string{"traffic.log", "pcap=", "csv=traffic.csv"} {
+		if _, _, err := ParseSpec(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestOpen_Hex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.log")
+	c, err := Open("hex="+path, LinkRTU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Frame(TX, []byte{0x01, 0x03, 0x00, 0x00})
+	c.Frame(RX, nil)
+	c.Frame(RX, []byte{0x01, 0x83, 0x02})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if err := gotest.Expect(len(lines)).Eq(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(strings.SplitN(lines[0], " ", 2)[1]).Eq("TX 01 03 00 00"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(strings.SplitN(lines[1], " ", 2)[1]).Eq("RX 01 83 02"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpen_Pcap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.pcapng")
+	c, err := Open("pcap="+path, LinkRTU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Frame(TX, []byte{0x01, 0x03, 0x00})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Заголовок секции
+	if err := gotest.Expect(binary.LittleEndian.Uint32(data[0:])).Eq(blockSectionHeader); err != nil {
+		t.Error(err)
+	}
+	shbLength := binary.LittleEndian.Uint32(data[4:])
+	// Описание интерфейса с типом кадров
+	idb := data[shbLength:]
+	if err := gotest.Expect(binary.LittleEndian.Uint16(idb[8:])).Eq(LinkRTU); err != nil {
+		t.Error(err)
+	}
+	// Кадр: данные выровнены до 4 байт, флаг направления outbound
+	epb := idb[binary.LittleEndian.Uint32(idb[4:]):]
+	if err := gotest.Expect(binary.LittleEndian.Uint32(epb[0:])).Eq(blockEnhancedPacket); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(epb[28:31]).Eq([]byte{0x01, 0x03, 0x00}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(binary.LittleEndian.Uint32(epb[36:])).Eq(epbFlagsOutbound); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(int(binary.LittleEndian.Uint32(epb[4:]))).Eq(len(epb)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadHex(t *testing.T) {
+	log := `# запись мастера
+2021-03-01T10:00:00.000000Z TX 01 03 00 00 00 01 84 0a
+
+2021-03-01T10:00:00.015000Z RX 01 03 02 01 02 39 c5
+`
+	records, err := ReadHex(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(len(records)).Eq(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(records[1].Direction).Eq(RX); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(records[1].Time.Sub(records[0].Time).String()).Eq("15ms"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(records[0].Data).Eq([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01, 0x84, 0x0a}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := ReadHex(strings.NewReader("2021-03-01T10:00:00.000000Z XX 01")); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// records - запись кадров в памяти
+type records struct {
+	mu   sync.Mutex
+	rows []string
+}
+
+func (r *records) Frame(direction Direction, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows = append(r.rows, fmt.Sprintf("%s % x", direction, data))
+}
+
+func (r *records) Close() error {
+	return nil
+}
+
+func (r *records) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.rows...)
+}
+
+func TestStream(t *testing.T) {
+	traffic := &records{}
+	s := NewStream(traffic, 20*time.Millisecond)
+
+	// Части без паузы и мусор перед кадром записываются одним кадром
+	reader := s.Reader(bytes.NewReader([]byte{0x00, 0xfe, 0x01}))
+	if _, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatal(err)
+	}
+	s.Received([]byte{0xfc})
+	// Отправка записывается после принятых байт
+	s.Sent([]byte{0xfe, 0x02, 0xfc})
+	s.Received([]byte{0xff})
+	time.Sleep(50 * time.Millisecond)
+	s.Received([]byte{0xee})
+	s.Flush()
+
+	if err := gotest.Expect(traffic.get()).Eq([]string{"RX 00 fe 01 fc", "TX fe 02 fc", "RX ff", "RX ee"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"encoding/binary"
+	"os"
+	"time"
+)
+
+// Блоки pcapng. Формат pcapng выбран из-за флагов направления кадра,
+// которых нет в классическом pcap
+const (
+	blockSectionHeader    uint32 = 0x0A0D0D0A
+	blockInterface        uint32 = 0x00000001
+	blockEnhancedPacket   uint32 = 0x00000006
+	byteOrderMagic        uint32 = 0x1A2B3C4D
+	optionEndOfOpt        uint16 = 0
+	optionEpbFlags        uint16 = 2
+	epbFlagsInbound       uint32 = 1
+	epbFlagsOutbound      uint32 = 2
+	pcapSectionLengthNone uint64 = 0xFFFFFFFFFFFFFFFF
+)
+
+type pcapWriter struct{}
+
+// header - заголовок секции и описание интерфейса. Время кадров в микросекундах (по умолчанию)
+func (pcapWriter) header(file *os.File, link uint16) error {
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint16(shb[6:], 0)
+	binary.LittleEndian.PutUint64(shb[8:], pcapSectionLengthNone)
+	if _, err := file.Write(pcapBlock(blockSectionHeader, shb)); err != nil {
+		return err
+	}
+
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], link)
+	// snaplen 0 - без ограничения
+	_, err := file.Write(pcapBlock(blockInterface, idb))
+	return err
+}
+
+func (pcapWriter) write(file *os.File, t time.Time, direction Direction, data []byte) error {
+	ts := uint64(t.UnixNano() / int64(time.Microsecond))
+	body := make([]byte, 20, 20+len(data)+16)
+	binary.LittleEndian.PutUint32(body[4:], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(body[16:], uint32(len(data)))
+	body = append(body, data...)
+	body = append(body, make([]byte, pad(len(data)))...)
+
+	flags := epbFlagsInbound
+	if direction == TX {
+		flags = epbFlagsOutbound
+	}
+	option := make([]byte, 12)
+	binary.LittleEndian.PutUint16(option[0:], optionEpbFlags)
+	binary.LittleEndian.PutUint16(option[2:], 4)
+	binary.LittleEndian.PutUint32(option[4:], flags)
+	binary.LittleEndian.PutUint16(option[8:], optionEndOfOpt)
+	body = append(body, option...)
+
+	_, err := file.Write(pcapBlock(blockEnhancedPacket, body))
+	return err
+}
+
+// pcapBlock - блок с типом и длиной в начале и в конце
+func pcapBlock(blockType uint32, body []byte) []byte {
+	length := uint32(len(body) + 12)
+	block := make([]byte, 8, length)
+	binary.LittleEndian.PutUint32(block[0:], blockType)
+	binary.LittleEndian.PutUint32(block[4:], length)
+	block = append(block, body...)
+	tail := make([]byte, 4)
+	binary.LittleEndian.PutUint32(tail, length)
+	return append(block, tail...)
+}
+
+// pad - выравнивание данных до 4 байт
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
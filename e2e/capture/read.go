@@ -0,0 +1,52 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Record - кадр из hex лога
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Data      []byte
+}
+
+// ReadHex - читает hex лог записанный в формате FormatHex.
+// Пустые строки и строки начинающиеся с # пропускаются
+func ReadHex(reader io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected time, direction and data", line)
+		}
+		t, err := time.Parse(TimeFormat, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		record := Record{Time: t}
+		switch strings.ToUpper(fields[1]) {
+		case TX.String():
+			record.Direction = TX
+		case RX.String():
+			record.Direction = RX
+		default:
+			return nil, fmt.Errorf("line %d: unknown direction %s", line, fields[1])
+		}
+		if record.Data, err = hex.DecodeString(strings.Join(fields[2:], "")); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,106 @@
+package capture
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Stream - запись трафика потокового порта. Принятые байты записываются как пришли по линии, включая мусор,
+// обрывки и кадры с неверной контрольной суммой. Части, пришедшие без паузы gap, объединяются в один
+// кадр RX со временем первого байта. Перед отправленным кадром записываются накопленные принятые байты
+type Stream struct {
+	capture Capture
+	gap     time.Duration
+
+	mu    sync.Mutex
+	rx    []byte
+	start time.Time
+	last  time.Time
+	timer *time.Timer
+}
+
+func NewStream(capture Capture, gap time.Duration) *Stream {
+	return &Stream{capture: capture, gap: gap}
+}
+
+// Reader - записывает все прочитанные из r байты как принятые
+func (s *Stream) Reader(r io.Reader) io.Reader {
+	return &streamReader{reader: r, stream: s}
+}
+
+// Received - добавляет принятые байты к текущему кадру
+func (s *Stream) Received(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = time.Now()
+	if len(s.rx) == 0 {
+		s.start = s.last
+	}
+	s.rx = append(s.rx, data...)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.gap, s.expire)
+	} else {
+		s.timer.Reset(s.gap)
+	}
+}
+
+// Sent - записывает отправленный кадр после накопленных принятых байт
+func (s *Stream) Sent(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flush()
+	s.capture.Frame(TX, data)
+}
+
+// Flush - записывает накопленные принятые байты. Вызывается перед закрытием записи
+func (s *Stream) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.flush()
+}
+
+// expire - кадр завершается, если после последнего байта прошла пауза gap
+func (s *Stream) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.rx) == 0 {
+		return
+	}
+	if wait := s.gap - time.Since(s.last); wait > 0 {
+		s.timer.Reset(wait)
+		return
+	}
+	s.flush()
+}
+
+func (s *Stream) flush() {
+	if len(s.rx) == 0 {
+		return
+	}
+	if tc, ok := s.capture.(timedCapture); ok {
+		tc.frameAt(s.start, RX, s.rx)
+	} else {
+		s.capture.Frame(RX, s.rx)
+	}
+	s.rx = nil
+}
+
+type streamReader struct {
+	reader io.Reader
+	stream *Stream
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	n, err := sr.reader.Read(p)
+	if n > 0 {
+		sr.stream.Received(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+package common
+
+import "fmt"
+
+// ConfigError - ошибка в файле конфигурации. Позволяет отличить ее от ошибок порта
+type ConfigError struct {
+	Err error
+}
+
+func NewConfigError(format string, a ...interface{}) error {
+	return &ConfigError{Err: fmt.Errorf(format, a...)}
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Validator - проверка конфигурации после загрузки. Возвращает все найденные ошибки
+type Validator interface {
+	Validate() []error
+}
+
+// FieldError - ошибка конфигурации в поле. Path - путь до поля относительно
+// проверяемого объекта через точку, например tests.0.next
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func NewFieldError(path string, format string, a ...interface{}) error {
+	return &FieldError{Path: path, Err: fmt.Errorf(format, a...)}
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// PrefixErrors - добавляет префикс к пути ошибок вложенного объекта
+func PrefixErrors(prefix string, errs []error) []error {
+	result := make([]error, 0, len(errs))
+	for _, err := range errs {
+		path := prefix
+		if fieldError, ok := err.(*FieldError); ok {
+			if fieldError.Path != "" {
+				path = prefix + "." + fieldError.Path
+			}
+			err = fieldError.Err
+		}
+		result = append(result, &FieldError{Path: path, Err: err})
+	}
+	return result
+}
@@ -11,4 +11,8 @@ type ReportExpected struct {
 	Got         string
 	GotHex      string
 	GotBin      string
+	// Единица измерения и значения в регистрах для значений в инженерных единицах
+	Unit        string
+	ExpectedRaw string
+	GotRaw      string
 }
@@ -108,7 +108,8 @@ func ValueToByte(v []*Value) (data []byte) {
 // Есть особенность если указан в конфигурации
 //  int8 будет дополнен []{byte{int8, 0}} LittleEndian
 //  int16 #TODO Доделат!!!
-func ValueToByte16(v []*Value) (data []byte) {
+// wordOrder - порядок слов устройства для 32 и 64 битных значений
+func ValueToByte16(v []*Value, wordOrder WordOrder) (data []byte) {
 	var i int
 	var vByte uint8
 	for _, w := range v {
@@ -139,7 +140,7 @@ func ValueToByte16(v []*Value) (data []byte) {
 			if len(data)%2 != 0 {
 				data = append(data, 0)
 			}
-			data = append(data, w.Write(binary.BigEndian)...)
+			data = append(data, w.WriteOrder(binary.BigEndian, wordOrder)...)
 		}
 	}
 	if i != 0 {
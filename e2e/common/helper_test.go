@@ -82,7 +82,7 @@ func Test_valueToByte16(t *testing.T) {
 		{Uint32: &param4},
 		{Uint64: &param5},
 	}
-	data := ValueToByte16(values)
+	data := ValueToByte16(values, ABCD)
 	if err := gotest.Expect(data).Eq([]byte{1, 1, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1}); err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,218 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// exactNumbers - числовые типы с точным ожидаемым значением
+var exactNumbers = []TypeValue{Int8, Int16, Int32, Int64, Uint8, Uint16, Uint32, Uint64, Float32, Float64}
+
+// integerLimits - пределы значений списка oneOf целых типов. Значения больше 2^53 не хранятся в float64 точно
+var integerLimits = map[TypeValue][2]float64{
+	Int8:   {math.MinInt8, math.MaxInt8},
+	Int16:  {math.MinInt16, math.MaxInt16},
+	Int32:  {math.MinInt32, math.MaxInt32},
+	Int64:  {-1 << 53, 1 << 53},
+	Uint8:  {0, math.MaxUint8},
+	Uint16: {0, math.MaxUint16},
+	Uint32: {0, math.MaxUint32},
+	Uint64: {0, 1 << 53},
+}
+
+// Точность вычислений с полученными значениями, достаточная для int64 и uint64
+const matchPrec = 128
+
+// matched - у значения заданы tolerance, tolerancePercent, not или oneOf
+func (v *Value) matched() bool {
+	return v.Tolerance != nil || v.TolerancePercent != nil || v.Not || len(v.OneOf) > 0
+}
+
+// validateMatch - проверка tolerance, tolerancePercent, not и oneOf
+func (v *Value) validateMatch() (errs []error) {
+	tolerance := v.Tolerance != nil || v.TolerancePercent != nil
+	if v.Tolerance != nil && *v.Tolerance < 0 {
+		errs = append(errs, NewFieldError("tolerance", "tolerance can not be negative"))
+	}
+	if v.TolerancePercent != nil && *v.TolerancePercent < 0 {
+		errs = append(errs, NewFieldError("tolerancePercent", "tolerancePercent can not be negative"))
+	}
+	if v.Tolerance != nil && v.TolerancePercent != nil {
+		errs = append(errs, NewFieldError("tolerancePercent", "tolerancePercent can not be used with tolerance"))
+	}
+	if tolerance && !v.exact() {
+		errs = append(errs, NewFieldError("tolerance", "tolerance requires exact numeric value"))
+	}
+	if len(v.OneOf) > 0 {
+		if tolerance {
+			errs = append(errs, NewFieldError("oneOf", "oneOf can not be used with tolerance"))
+		}
+		errs = append(errs, v.validateOneOf()...)
+	}
+	if v.Not {
+		switch v.Type() {
+		case Nil, Time, Error:
+			errs = append(errs, NewFieldError("not", "not requires expected value"))
+		}
+	}
+	return errs
+}
+
+// validateOneOf - значения списка целого типа без scale и offset должны быть целыми в пределах типа
+func (v *Value) validateOneOf() (errs []error) {
+	limits, ok := integerLimits[v.rawType()]
+	if !ok || !v.integer() {
+		return nil
+	}
+	for i, f := range v.OneOf {
+		switch {
+		case f != math.Trunc(f):
+			errs = append(errs, NewFieldError(fmt.Sprintf("oneOf[%d]", i), "value %s is not an integer of type %s", number(f), v.rawType()))
+		case f < limits[0] || f > limits[1]:
+			errs = append(errs, NewFieldError(fmt.Sprintf("oneOf[%d]", i), "value %s is out of range of type %s", number(f), v.rawType()))
+		}
+	}
+	return errs
+}
+
+// oneOfType - тип в регистрах для списка без raw: целые помещаются в наименьший из uint16, int16, uint32,
+// int32, uint64, int64, дробные хранятся в float32
+func oneOfType(values []float64) TypeValue {
+	for _, t := range []TypeValue{Uint16, Int16, Uint32, Int32, Uint64, Int64} {
+		fit := true
+		for _, f := range values {
+			limits := integerLimits[t]
+			if f != math.Trunc(f) || f < limits[0] || f > limits[1] {
+				fit = false
+			}
+		}
+		if fit {
+			return t
+		}
+	}
+	return Float32
+}
+
+// integer - значение сравнивается как целое, без потери точности int64 и uint64
+func (v *Value) integer() bool {
+	if v.Scale != nil || v.Offset != nil {
+		return false
+	}
+	_, ok := integerLimits[v.Type()]
+	return ok
+}
+
+// exact - точное числовое значение
+func (v *Value) exact() bool {
+	if v.Scaled() {
+		return v.Float != nil
+	}
+	for _, t := range exactNumbers {
+		if v.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMatch - Check с допуском, списком значений или отрицанием. Значение проверяется без них,
+// затем результат пересчитывается по полученному числу
+func (v *Value) checkMatch(rawBite []byte, rawTime time.Duration, rawError string, currentBit int, minBitSize int, byteOrder binary.ByteOrder) (offsetBit int, report ReportExpected) {
+	plain := *v
+	plain.Tolerance, plain.TolerancePercent, plain.Not, plain.OneOf = nil, nil, false, nil
+	if len(v.OneOf) > 0 {
+		plain.Raw, plain.Float = v.rawType().String(), &v.OneOf[0]
+	}
+	offsetBit, report = plain.Check(rawBite, rawTime, rawError, currentBit, minBitSize, byteOrder)
+
+	unit := ""
+	if v.Unit != "" {
+		unit = " " + v.Unit
+	}
+	got, ok := v.parse(report.GotRaw, report.Got)
+	switch {
+	case len(v.OneOf) > 0:
+		items := make([]string, len(v.OneOf))
+		for i, f := range v.OneOf {
+			items[i] = number(f)
+		}
+		report.Expected = fmt.Sprintf("one of [%s]%s", strings.Join(items, ", "), unit)
+		report.ExpectedRaw, report.ExpectedHex, report.ExpectedBin = "", "", ""
+		if ok {
+			report.Pass = false
+			for _, f := range v.OneOf {
+				if v.near(got, big.NewFloat(f), new(big.Float)) {
+					report.Pass = true
+				}
+			}
+		}
+
+	case v.Tolerance != nil || v.TolerancePercent != nil:
+		expected, _ := v.parse(report.ExpectedRaw, report.Expected)
+		tolerance := new(big.Float).SetPrec(matchPrec)
+		var text string
+		if v.Tolerance != nil {
+			tolerance.SetFloat64(*v.Tolerance)
+			text = number(*v.Tolerance)
+		} else {
+			tolerance.Abs(expected).Mul(tolerance, big.NewFloat(*v.TolerancePercent)).Quo(tolerance, big.NewFloat(100))
+			text = number(*v.TolerancePercent) + "%"
+		}
+		report.Expected = fmt.Sprintf("%s ±%s%s", v.text(expected), text, unit)
+		if ok {
+			report.Pass = v.near(got, expected, tolerance)
+		}
+	}
+
+	if v.Not {
+		report.Expected = "not " + report.Expected
+		// Без полученных данных проверка не проходит и с отрицанием
+		if report.Got != "" {
+			report.Pass = !report.Pass
+		}
+	}
+	return
+}
+
+// parse - число из отчета о значении. Целые берутся из значения в регистрах, если оно выведено,
+// и разбираются без потери точности
+func (v *Value) parse(raw, s string) (*big.Float, bool) {
+	text := raw
+	if !v.integer() || text == "" {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			return new(big.Float), false
+		}
+		text = fields[0]
+	}
+	f, ok := new(big.Float).SetPrec(matchPrec).SetString(text)
+	if !ok {
+		return new(big.Float), false
+	}
+	return f, true
+}
+
+// near - got отличается от expected не больше чем на tolerance. Целые сравниваются точно,
+// дробные - с погрешностью вычислений с плавающей точкой
+func (v *Value) near(got, expected, tolerance *big.Float) bool {
+	limit := new(big.Float).SetPrec(matchPrec).Set(tolerance)
+	if !v.integer() {
+		e, _ := expected.Float64()
+		limit.Add(limit, big.NewFloat(1e-9*math.Max(1, math.Abs(e))))
+	}
+	diff := new(big.Float).SetPrec(matchPrec).Sub(got, expected)
+	return diff.Abs(diff).Cmp(limit) <= 0
+}
+
+// text - ожидаемое значение для отчета: целые без потери точности, дробные в кратчайшей записи
+func (v *Value) text(f *big.Float) string {
+	if v.integer() {
+		return f.Text('f', 0)
+	}
+	x, _ := f.Float64()
+	return number(x)
+}
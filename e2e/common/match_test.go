@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/binary"
+	"github.com/schnack/gotest"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestValue_CheckMatch(t *testing.T) {
+	for _, c := range []struct {
+		config   string
+		data     []byte
+		pass     bool
+		expected string
+	}{
+		{"{uint16: 100, tolerance: 2}", []byte{0, 98}, true, "100 ±2"},
+		{"{uint16: 100, tolerance: 2}", []byte{0, 97}, false, "100 ±2"},
+		{"{int16: -200, tolerancePercent: 5}", []byte{0xff, 0x2e}, true, "-200 ±5%"},
+		{"{int16: -200, tolerancePercent: 5}", []byte{0xff, 0x2d}, false, "-200 ±5%"},
+		{"{float32: 1.5, tolerance: 0.01}", []byte{0x3f, 0xc0, 0x20, 0xc5}, true, "1.5 ±0.01"},
+		{"{raw: uint16, scale: 0.1, unit: V, float: 230.5, tolerance: 0.5}", []byte{0x09, 0x05}, true, "230.5 ±0.5 V"},
+		{"{raw: uint16, scale: 0.1, unit: V, float: 230.5, tolerance: 0.5}", []byte{0x09, 0x07}, false, "230.5 ±0.5 V"},
+		{"{raw: uint16, oneOf: [1, 2, 4]}", []byte{0, 4}, true, "one of [1, 2, 4]"},
+		{"{raw: uint16, oneOf: [1, 2, 4]}", []byte{0, 3}, false, "one of [1, 2, 4]"},
+		{"{raw: int16, scale: 0.5, unit: Hz, oneOf: [49.5, 50]}", []byte{0, 99}, true, "one of [49.5, 50] Hz"},
+		// Тип списка без raw определяется по значениям
+		{"{oneOf: [1, 2, 3]}", []byte{0, 3}, true, "one of [1, 2, 3]"},
+		{"{oneOf: [1, 2, 3]}", []byte{0, 5}, false, "one of [1, 2, 3]"},
+		{"{oneOf: [-1, 1]}", []byte{0xff, 0xff}, true, "one of [-1, 1]"},
+		{"{oneOf: [0.5, 1.5]}", []byte{0x3f, 0xc0, 0, 0}, true, "one of [0.5, 1.5]"},
+		// int64 и uint64 сравниваются без потери точности
+		{"{uint64: 18446744073709551000, tolerance: 1}", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false, "18446744073709551000 ±1"},
+		{"{uint64: 18446744073709551614, tolerance: 1}", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, true, "18446744073709551614 ±1"},
+		{"{int64: 9223372036854775807, tolerancePercent: 0}", []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, false, "9223372036854775807 ±0%"},
+		{"{uint16: 0, not: true}", []byte{0, 1}, true, "not 0"},
+		{"{uint16: 0, not: true}", []byte{0, 0}, false, "not 0"},
+		{"{minUint16: 10, maxUint16: 20, not: true}", []byte{0, 30}, true, "not 10..20"},
+		{"{raw: uint16, oneOf: [1, 2], not: true}", []byte{0, 2}, false, "not one of [1, 2]"},
+		{"{uint16: 100, tolerance: 2, not: true}", []byte{0, 90}, true, "not 100 ±2"},
+		// Без данных проверка не проходит и с отрицанием
+		{"{uint16: 0, not: true}", []byte{}, false, "not 0"},
+	} {
+		var v Value
+		if err := yaml.Unmarshal([]byte(c.config), &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := gotest.Expect(len(v.Validate())).Eq(0); err != nil {
+			t.Error(c.config, err)
+		}
+		_, report := v.Check(c.data, 0, "", 0, 16, binary.BigEndian)
+		if err := gotest.Expect([]interface{}{report.Pass, report.Expected}).Eq([]interface{}{c.pass, c.expected}); err != nil {
+			t.Error(c.config, c.data, err)
+		}
+	}
+}
+
+func TestValue_ValidateMatch(t *testing.T) {
+	for _, config := range []string{
+		"{uint16: 1, tolerance: -1}",
+		"{uint16: 1, tolerance: 1, tolerancePercent: 1}",
+		"{minUint16: 1, tolerance: 1}",
+		"{bool: true, tolerance: 1}",
+		"{raw: uint16, minFloat: 1, tolerance: 1}",
+		"{raw: uint16, oneOf: [1.5]}",
+		"{raw: uint16, oneOf: [70000]}",
+		"{raw: uint64, oneOf: [18446744073709551615]}",
+		"{uint16: 1, oneOf: [1, 2]}",
+		"{raw: uint16, float: 1, oneOf: [1, 2]}",
+		"{raw: uint16, oneOf: [1, 2], tolerance: 1}",
+		"{error: timeout, not: true}",
+		"{not: true}",
+	} {
+		var v Value
+		if err := yaml.Unmarshal([]byte(config), &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := gotest.Expect(len(v.Validate()) > 0).True(); err != nil {
+			t.Error(config, err)
+		}
+	}
+}
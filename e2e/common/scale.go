@@ -0,0 +1,265 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Значение в инженерных единицах: в регистрах хранится число типа raw,
+// проверяется и записывается raw * scale + offset
+
+// rawTypes - типы, в которых хранится значение в инженерных единицах
+var rawTypes = []TypeValue{Int8, Int16, Int32, Int64, Uint8, Uint16, Uint32, Uint64, Float32, Float64}
+
+// Scaled - значение задано в инженерных единицах или списком oneOf
+func (v *Value) Scaled() bool {
+	return v.Raw != "" || len(v.OneOf) > 0
+}
+
+// rawType - тип значения в регистрах. Без raw тип списка oneOf определяется по значениям
+func (v *Value) rawType() TypeValue {
+	if v.Raw == "" && len(v.OneOf) > 0 {
+		return oneOfType(v.OneOf)
+	}
+	for _, t := range rawTypes {
+		if t.String() == v.Raw {
+			return t
+		}
+	}
+	return Nil
+}
+
+func (v *Value) scale() float64 {
+	if v.Scale == nil {
+		return 1
+	}
+	return *v.Scale
+}
+
+func (v *Value) offset() float64 {
+	if v.Offset == nil {
+		return 0
+	}
+	return *v.Offset
+}
+
+// validateScale - проверка значения в инженерных единицах
+func (v *Value) validateScale() (errs []error) {
+	float := v.Float != nil || v.MinFloat != nil || v.MaxFloat != nil
+	switch {
+	case v.Raw == "" && float:
+		errs = append(errs, NewFieldError("raw", "raw type of float value is not set"))
+	case v.Raw != "" && v.rawType() == Nil:
+		errs = append(errs, NewFieldError("raw", "unknown raw type %q", v.Raw))
+	case v.Raw != "" && !float && len(v.OneOf) == 0:
+		errs = append(errs, NewFieldError("float", "float, minFloat or maxFloat is not set"))
+	}
+	if v.Raw == "" && (v.Scale != nil || v.Offset != nil) {
+		errs = append(errs, NewFieldError("scale", "scale and offset require raw type"))
+	}
+	if v.Scale != nil && *v.Scale == 0 {
+		errs = append(errs, NewFieldError("scale", "scale can not be zero"))
+	}
+	if v.Float != nil && (v.MinFloat != nil || v.MaxFloat != nil) {
+		errs = append(errs, NewFieldError("float", "float can not be used with minFloat and maxFloat"))
+	}
+	if v.MinFloat != nil && v.MaxFloat != nil && *v.MinFloat > *v.MaxFloat {
+		errs = append(errs, NewFieldError("minFloat", "minFloat %g is greater than maxFloat %g", *v.MinFloat, *v.MaxFloat))
+	}
+	return errs
+}
+
+// toRaw - значение в регистрах для инженерного значения x. Целые округляются и ограничиваются пределами типа.
+// MaxInt64 и MaxUint64 в float64 округляются за пределы типа, поэтому верхняя граница - ближайшее меньшее float64
+func (v *Value) toRaw(x float64) *Value {
+	r := (x - v.offset()) / v.scale()
+	raw := &Value{Name: v.Name, Address: v.Address, WordOrder: v.WordOrder}
+	switch v.rawType() {
+	case Int8:
+		n := int8(limit(r, math.MinInt8, math.MaxInt8))
+		raw.Int8 = &n
+	case Int16:
+		n := int16(limit(r, math.MinInt16, math.MaxInt16))
+		raw.Int16 = &n
+	case Int32:
+		n := int32(limit(r, math.MinInt32, math.MaxInt32))
+		raw.Int32 = &n
+	case Int64:
+		n := int64(limit(r, math.MinInt64, math.Nextafter(math.MaxInt64, 0)))
+		raw.Int64 = &n
+	case Uint8:
+		n := uint8(limit(r, 0, math.MaxUint8))
+		raw.Uint8 = &n
+	case Uint16:
+		n := uint16(limit(r, 0, math.MaxUint16))
+		raw.Uint16 = &n
+	case Uint32:
+		n := uint32(limit(r, 0, math.MaxUint32))
+		raw.Uint32 = &n
+	case Uint64:
+		n := uint64(limit(r, 0, math.Nextafter(math.MaxUint64, 0)))
+		raw.Uint64 = &n
+	case Float32:
+		n := float32(r)
+		raw.Float32 = &n
+	case Float64:
+		raw.Float64 = &r
+	}
+	return raw
+}
+
+// target - инженерное значение для записи. У диапазона записывается нижняя граница, у списка - первое значение
+func (v *Value) target() float64 {
+	switch {
+	case v.Float != nil:
+		return *v.Float
+	case v.MinFloat != nil:
+		return *v.MinFloat
+	case v.MaxFloat != nil:
+		return *v.MaxFloat
+	case len(v.OneOf) > 0:
+		return v.OneOf[0]
+	}
+	return 0
+}
+
+// checkUnit - Check для значения в инженерных единицах или с единицей измерения
+func (v *Value) checkUnit(rawBite []byte, rawTime time.Duration, rawError string, currentBit int, minBitSize int, byteOrder binary.ByteOrder) (offsetBit int, report ReportExpected) {
+	if !v.Scaled() {
+		plain := *v
+		plain.Unit = ""
+		offsetBit, report = plain.Check(rawBite, rawTime, rawError, currentBit, minBitSize, byteOrder)
+		report.Unit = v.Unit
+		report.Expected = withUnit(report.Expected, v.Unit, "")
+		report.Got = withUnit(report.Got, v.Unit, "")
+		return
+	}
+
+	offsetBit, report = v.toRaw(v.target()).Check(rawBite, rawTime, rawError, currentBit, minBitSize, byteOrder)
+	report.Unit = v.Unit
+	if v.Float != nil {
+		report.ExpectedRaw = report.Expected
+		report.Expected = number(*v.Float)
+	} else {
+		var min, max string
+		if v.MinFloat != nil {
+			min = number(*v.MinFloat)
+		}
+		if v.MaxFloat != nil {
+			max = number(*v.MaxFloat)
+		}
+		report.Expected = fmt.Sprintf(FormatRange, min, max)
+		report.ExpectedHex, report.ExpectedBin = "", ""
+	}
+	report.Expected = withUnit(report.Expected, v.Unit, v.rawText(report.ExpectedRaw))
+
+	got, err := strconv.ParseFloat(report.Got, 64)
+	if err != nil {
+		report.Pass = false
+		return
+	}
+	x := got*v.scale() + v.offset()
+	report.GotRaw = report.Got
+	report.Got = withUnit(v.format(x), v.Unit, v.rawText(report.GotRaw))
+	report.Pass = v.inRange(x)
+	return
+}
+
+// inRange - инженерное значение совпадает с ожидаемым или входит в диапазон.
+// Сравнение допускает погрешность вычислений с плавающей точкой
+func (v *Value) inRange(x float64) bool {
+	eps := 1e-9
+	if v.rawType() == Float32 {
+		eps = 1e-6
+	}
+	near := func(bound float64) float64 {
+		return eps * math.Max(1, math.Abs(bound))
+	}
+	if v.Float != nil {
+		return math.Abs(x-*v.Float) <= near(*v.Float)
+	}
+	if v.MinFloat != nil && x < *v.MinFloat-near(*v.MinFloat) {
+		return false
+	}
+	if v.MaxFloat != nil && x > *v.MaxFloat+near(*v.MaxFloat) {
+		return false
+	}
+	return true
+}
+
+// reportUnit - ReportWrite для значения в инженерных единицах или с единицей измерения
+func (v *Value) reportUnit(byteOrder binary.ByteOrder) ReportWrite {
+	if !v.Scaled() {
+		plain := *v
+		plain.Unit = ""
+		report := plain.ReportWrite(byteOrder)
+		report.Data = withUnit(report.Data, v.Unit, "")
+		return report
+	}
+	report := v.toRaw(v.target()).ReportWrite(byteOrder)
+	if got, err := strconv.ParseFloat(report.Data, 64); err == nil {
+		report.Data = withUnit(v.format(got*v.scale()+v.offset()), v.Unit, v.rawText(report.Data))
+	}
+	return report
+}
+
+// rawText - значение в регистрах для отчета. Без scale и offset оно совпадает с инженерным и не выводится
+func (v *Value) rawText(s string) string {
+	if v.Scale == nil && v.Offset == nil {
+		return ""
+	}
+	return s
+}
+
+// format - инженерное значение с точностью шага scale и offset
+func (v *Value) format(x float64) string {
+	switch v.rawType() {
+	case Float32:
+		return strconv.FormatFloat(x, 'f', -1, 32)
+	case Float64:
+		return number(x)
+	}
+	decimals := 0
+	for _, f := range []float64{v.scale(), v.offset()} {
+		s := number(f)
+		if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > decimals {
+			decimals = len(s) - i - 1
+		}
+	}
+	return strconv.FormatFloat(x, 'f', decimals, 64)
+}
+
+// number - число в кратчайшей записи
+func number(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// limit - округленное значение в пределах типа
+func limit(f, lowest, highest float64) float64 {
+	f = math.Round(f)
+	if f < lowest {
+		return lowest
+	}
+	if f > highest {
+		return highest
+	}
+	return f
+}
+
+// withUnit - значение с единицей измерения и значением в регистрах
+func withUnit(s, unit, raw string) string {
+	if s == "" {
+		return s
+	}
+	if unit != "" {
+		s += " " + unit
+	}
+	if raw != "" {
+		s += " (raw " + raw + ")"
+	}
+	return s
+}
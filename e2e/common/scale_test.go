@@ -0,0 +1,94 @@
+package common
+
+import (
+	"encoding/binary"
+	"github.com/schnack/gotest"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestValue_CheckScaled(t *testing.T) {
+	var v Value
+	if err := yaml.Unmarshal([]byte("{name: voltage, raw: uint16, scale: 0.1, unit: V, float: 230.5}"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(len(v.Validate())).Eq(0); err != nil {
+		t.Error(err)
+	}
+	data := v.Write(binary.BigEndian)
+	if err := gotest.Expect(data).Eq([]byte{0x09, 0x01}); err != nil {
+		t.Error(err)
+	}
+
+	_, report := v.Check(data, 0, "", 0, 16, binary.BigEndian)
+	if err := gotest.Expect(report).Eq(ReportExpected{
+		Name:        "voltage",
+		Pass:        true,
+		Type:        "uint16",
+		Expected:    "230.5 V (raw 2305)",
+		ExpectedHex: "0901",
+		ExpectedBin: "[00001001 00000001]",
+		Got:         "230.5 V (raw 2305)",
+		GotHex:      "0901",
+		GotBin:      "[00001001 00000001]",
+		Unit:        "V",
+		ExpectedRaw: "2305",
+		GotRaw:      "2305",
+	}); err != nil {
+		t.Error(err)
+	}
+
+	_, report = v.Check([]byte{0x08, 0xfc}, 0, "", 0, 16, binary.BigEndian)
+	if err := gotest.Expect([]interface{}{report.Pass, report.Got}).Eq([]interface{}{false, "230.0 V (raw 2300)"}); err != nil {
+		t.Error(err)
+	}
+
+	// Диапазон со смещением: температура -40..+125 в десятых долях
+	min, max, scale, offset := 20.0, 25.0, 0.1, -40.0
+	r := &Value{Name: "temperature", Raw: "int32", Scale: &scale, Offset: &offset, MinFloat: &min, MaxFloat: &max}
+	_, report = r.Check([]byte{0, 0, 0x02, 0x62}, 0, "", 0, 16, binary.BigEndian)
+	if err := gotest.Expect([]interface{}{report.Pass, report.Expected, report.Got}).Eq([]interface{}{true, "20..25", "21.0 (raw 610)"}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(r.Type()).Eq(Int32); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(v.ReportWrite(binary.BigEndian).Data).Eq("230.5 V (raw 2305)"); err != nil {
+		t.Error(err)
+	}
+
+	// Значение за пределами int64 ограничивается типом
+	huge := 1e19
+	if err := gotest.Expect(*(&Value{Raw: "int64", Float: &huge}).toRaw(huge).Int64).Eq(int64(9223372036854774784)); err != nil {
+		t.Error(err)
+	}
+
+	// Единица измерения у обычного значения
+	rpm := uint16(1500)
+	_, report = (&Value{Uint16: &rpm, Unit: "rpm"}).Check([]byte{0x05, 0xdc}, 0, "", 0, 16, binary.BigEndian)
+	if err := gotest.Expect([]interface{}{report.Pass, report.Expected, report.Got}).Eq([]interface{}{true, "1500 rpm", "1500 rpm"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValue_ValidateScale(t *testing.T) {
+	for _, config := range []string{
+		"{float: 1}",
+		"{raw: uint16}",
+		"{raw: bool, float: 1}",
+		"{raw: uint16, scale: 0, float: 1}",
+		"{uint16: 1, scale: 0.1}",
+		"{raw: uint16, float: 1, minFloat: 0}",
+		"{raw: uint16, minFloat: 2, maxFloat: 1}",
+		"{raw: uint16, float: 1, uint16: 1}",
+	} {
+		var v Value
+		if err := yaml.Unmarshal([]byte(config), &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := gotest.Expect(len(v.Validate()) > 0).True(); err != nil {
+			t.Error(config, err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"math"
+	"strings"
 	"time"
 )
 
@@ -81,6 +82,15 @@ type Value struct {
 	Name string `yaml:"name"`
 	// Используется для сервера
 	Address string `yaml:"address"`
+	// Порядок слов 32 и 64 битного значения: ABCD, CDAB, BADC или DCBA. По умолчанию порядок устройства
+	WordOrder WordOrder `yaml:"wordOrder"`
+	// Тип значения в регистрах для float, minFloat и maxFloat
+	Raw string `yaml:"raw"`
+	// Значение в инженерных единицах: raw * scale + offset. По умолчанию scale 1, offset 0
+	Scale  *float64 `yaml:"scale"`
+	Offset *float64 `yaml:"offset"`
+	// Единица измерения для отчета
+	Unit string `yaml:"unit"`
 
 	Int8    *int8 `yaml:"int8"`
 	MaxInt8 *int8 `yaml:"maxInt8"`
@@ -122,6 +132,11 @@ type Value struct {
 	MaxFloat64 *float64 `yaml:"maxFloat64"`
 	MinFloat64 *float64 `yaml:"minFloat64"`
 
+	// Значение в инженерных единицах, в регистрах хранится тип raw
+	Float    *float64 `yaml:"float"`
+	MaxFloat *float64 `yaml:"maxFloat"`
+	MinFloat *float64 `yaml:"minFloat"`
+
 	Bool *bool `yaml:"bool"`
 
 	String *string `yaml:"string"`
@@ -132,10 +147,57 @@ type Value struct {
 	Time *string `yaml:"time"`
 	// Проверяем ошибку
 	Error *string `yaml:"error"`
+
+	// Допуск точного числового значения: абсолютный или в процентах от ожидаемого
+	Tolerance        *float64 `yaml:"tolerance"`
+	TolerancePercent *float64 `yaml:"tolerancePercent"`
+	// Допустимые значения в инженерных единицах. Без raw тип в регистрах определяется по значениям
+	OneOf []float64 `yaml:"oneOf"`
+	// Инвертирует результат проверки
+	Not bool `yaml:"not"`
 }
 
 const FormatRange = "%s..%s"
 
+// Validate - у значения должен быть задан только один тип
+func (v *Value) Validate() []error {
+	types := []struct {
+		name string
+		set  bool
+	}{
+		{"int8", v.Int8 != nil || v.MaxInt8 != nil || v.MinInt8 != nil},
+		{"int16", v.Int16 != nil || v.MaxInt16 != nil || v.MinInt16 != nil},
+		{"int32", v.Int32 != nil || v.MaxInt32 != nil || v.MinInt32 != nil},
+		{"int64", v.Int64 != nil || v.MaxInt64 != nil || v.MinInt64 != nil},
+		{"uint8", v.Uint8 != nil || v.MaxUint8 != nil || v.MinUint8 != nil},
+		{"uint16", v.Uint16 != nil || v.MaxUint16 != nil || v.MinUint16 != nil},
+		{"uint32", v.Uint32 != nil || v.MaxUint32 != nil || v.MinUint32 != nil},
+		{"uint64", v.Uint64 != nil || v.MaxUint64 != nil || v.MinUint64 != nil},
+		{"float32", v.Float32 != nil || v.MaxFloat32 != nil || v.MinFloat32 != nil},
+		{"float64", v.Float64 != nil || v.MaxFloat64 != nil || v.MinFloat64 != nil},
+		{"float", v.Float != nil || v.MaxFloat != nil || v.MinFloat != nil},
+		{"oneOf", len(v.OneOf) > 0},
+		{"bool", v.Bool != nil},
+		{"string", v.String != nil},
+		{"byte", v.Byte != nil},
+		{"time", v.Time != nil},
+		{"error", v.Error != nil},
+	}
+	var names []string
+	for _, t := range types {
+		if t.set {
+			names = append(names, t.name)
+		}
+	}
+	if len(names) > 1 {
+		return []error{fmt.Errorf("value has several types: %s", strings.Join(names, ", "))}
+	}
+	if err := v.WordOrder.Validate(); err != nil {
+		return []error{NewFieldError("wordOrder", "%s", err)}
+	}
+	return append(v.validateScale(), v.validateMatch()...)
+}
+
 // LengthBit - Длина значения в байтах
 func (v *Value) LengthBit() int {
 	switch v.Type() {
@@ -227,6 +289,12 @@ func (v *Value) cursorBit(currentBit, basicBitSize int, byteOrder binary.ByteOrd
 // minBitSize - размер данных хранимых в табличке модбас 8 до 64 (дополняет нулями если тип например bool)
 // orderByte - порядок байт
 func (v *Value) Check(rawBite []byte, rawTime time.Duration, rawError string, currentBit int, minBitSize int, byteOrder binary.ByteOrder) (offsetBit int, report ReportExpected) {
+	if v.matched() {
+		return v.checkMatch(rawBite, rawTime, rawError, currentBit, minBitSize, byteOrder)
+	}
+	if v.Scaled() || v.Unit != "" {
+		return v.checkUnit(rawBite, rawTime, rawError, currentBit, minBitSize, byteOrder)
+	}
 	report.Name = v.Name
 	report.Pass = true
 	report.Type = v.Type().String()
@@ -925,6 +993,9 @@ func (v *Value) Check(rawBite []byte, rawTime time.Duration, rawError string, cu
 
 // ReportWrite - возвращает отчет о записанных данных
 func (v *Value) ReportWrite(byteOrder binary.ByteOrder) ReportWrite {
+	if v.Scaled() || v.Unit != "" {
+		return v.reportUnit(byteOrder)
+	}
 	report := ReportWrite{Name: v.Name, Type: v.Type().String()}
 	b := v.Write(byteOrder)
 	switch v.Type() {
@@ -1005,6 +1076,9 @@ func (v *Value) ReportWrite(byteOrder binary.ByteOrder) ReportWrite {
 
 // Write - Возвращает значение в байтах
 func (v *Value) Write(byteOrder binary.ByteOrder) (b []byte) {
+	if v.Scaled() {
+		return v.toRaw(v.target()).Write(byteOrder)
+	}
 
 	buf := new(bytes.Buffer)
 	switch v.Type() {
@@ -1075,6 +1149,8 @@ func (v *Value) Write(byteOrder binary.ByteOrder) (b []byte) {
 /// Type - возвращает тип текущего значения
 func (v *Value) Type() TypeValue {
 	switch {
+	case v.Scaled():
+		return v.rawType()
 	case v.Int8 != nil:
 		return Int8
 	case v.MinInt8 != nil || v.MaxInt8 != nil:
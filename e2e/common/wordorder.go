@@ -0,0 +1,104 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// WordOrder - порядок байт 32 и 64 битных значений в регистрах modbus.
+// Буквы - байты значения от старшего к младшему
+type WordOrder string
+
+const (
+	// Старшее слово первым, старший байт первым (по умолчанию)
+	ABCD = WordOrder("ABCD")
+	// Младшее слово первым
+	CDAB = WordOrder("CDAB")
+	// Байты в каждом слове переставлены
+	BADC = WordOrder("BADC")
+	// Обратный порядок байт
+	DCBA = WordOrder("DCBA")
+)
+
+// Validate - проверка порядка после загрузки конфигурации
+func (o WordOrder) Validate() error {
+	switch o {
+	case "", ABCD, CDAB, BADC, DCBA:
+		return nil
+	}
+	return fmt.Errorf("unknown word order %q, expected ABCD, CDAB, BADC or DCBA", string(o))
+}
+
+// Or - порядок o или def, если o не задан
+func (o WordOrder) Or(def WordOrder) WordOrder {
+	if o == "" {
+		return def
+	}
+	return o
+}
+
+// Reorder - переставляет байты значения между ABCD и порядком o. Перестановка обратна сама себе,
+// поэтому используется и при записи, и при чтении. Значения меньше двух слов не меняются
+func (o WordOrder) Reorder(b []byte) []byte {
+	result := append([]byte{}, b...)
+	if len(b) < 4 || len(b)%2 != 0 {
+		return result
+	}
+	if o == CDAB || o == DCBA {
+		for i, j := 0, len(result)-2; i < j; i, j = i+2, j-2 {
+			result[i], result[i+1], result[j], result[j+1] = result[j], result[j+1], result[i], result[i+1]
+		}
+	}
+	if o == BADC || o == DCBA {
+		for i := 0; i+1 < len(result); i += 2 {
+			result[i], result[i+1] = result[i+1], result[i]
+		}
+	}
+	return result
+}
+
+// WriteOrder - Write с порядком слов wordOrder. Порядок значения заменяет wordOrder
+func (v *Value) WriteOrder(byteOrder binary.ByteOrder, wordOrder WordOrder) []byte {
+	if !v.multiWord() {
+		return v.Write(byteOrder)
+	}
+	return v.WordOrder.Or(wordOrder).Reorder(v.Write(byteOrder))
+}
+
+// ReportWriteOrder - ReportWrite с байтами значения в порядке слов wordOrder, как они отправляются в регистры
+func (v *Value) ReportWriteOrder(byteOrder binary.ByteOrder, wordOrder WordOrder) ReportWrite {
+	report := v.ReportWrite(byteOrder)
+	if !v.multiWord() {
+		return report
+	}
+	b := v.WriteOrder(byteOrder, wordOrder)
+	report.DataHex = fmt.Sprintf("%x", b)
+	report.DataBin = fmt.Sprintf("%08b", b)
+	return report
+}
+
+// CheckOrder - Check с порядком слов wordOrder для 32 и 64 битных значений.
+// Порядок значения заменяет wordOrder
+func (v *Value) CheckOrder(rawBite []byte, rawTime time.Duration, rawError string, currentBit int, minBitSize int, byteOrder binary.ByteOrder, wordOrder WordOrder) (offsetBit int, report ReportExpected) {
+	order := v.WordOrder.Or(wordOrder)
+	if order != "" && order != ABCD && v.multiWord() {
+		start, end, _ := v.cursorByte(currentBit, v.LengthBit()/8, minBitSize, byteOrder)
+		if end <= len(rawBite) {
+			raw := append([]byte{}, rawBite...)
+			copy(raw[start:end], order.Reorder(raw[start:end]))
+			rawBite = raw
+		}
+	}
+	return v.Check(rawBite, rawTime, rawError, currentBit, minBitSize, byteOrder)
+}
+
+// multiWord - числовое значение из двух и более слов
+func (v *Value) multiWord() bool {
+	switch v.Type() {
+	case Int32, Int32Range, Uint32, Uint32Range, Float32, Float32Range,
+		Int64, Int64Range, Uint64, Uint64Range, Float64, Float64Range:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"encoding/binary"
+	"github.com/schnack/gotest"
+	"testing"
+)
+
+func TestWordOrder_Reorder(t *testing.T) {
+	for order, expected := range map[WordOrder][]byte{
+		ABCD: {0xa, 0xb, 0xc, 0xd},
+		CDAB: {0xc, 0xd, 0xa, 0xb},
+		BADC: {0xb, 0xa, 0xd, 0xc},
+		DCBA: {0xd, 0xc, 0xb, 0xa},
+	} {
+		if err := gotest.Expect(order.Reorder([]byte{0xa, 0xb, 0xc, 0xd})).Eq(expected); err != nil {
+			t.Error(order, err)
+		}
+		// Перестановка обратна сама себе
+		if err := gotest.Expect(order.Reorder(expected)).Eq([]byte{0xa, 0xb, 0xc, 0xd}); err != nil {
+			t.Error(order, err)
+		}
+	}
+	if err := gotest.Expect(CDAB.Reorder([]byte{1, 2, 3, 4, 5, 6, 7, 8})).Eq([]byte{7, 8, 5, 6, 3, 4, 1, 2}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(DCBA.Reorder([]byte{1, 2})).Eq([]byte{1, 2}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(WordOrder("ACBD").Validate() != nil).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValue_CheckOrder(t *testing.T) {
+	var param1 uint16 = 1
+	var param2 uint32 = 0x0a0b0c0d
+	values := []*Value{
+		{Uint16: &param1},
+		{Uint32: &param2},
+	}
+	data := ValueToByte16(values, CDAB)
+	if err := gotest.Expect(data).Eq([]byte{0, 1, 0x0c, 0x0d, 0x0a, 0x0b}); err != nil {
+		t.Error(err)
+	}
+
+	countBit := 0
+	var report ReportExpected
+	for _, v := range values {
+		countBit, report = v.CheckOrder(data, 0, "", countBit, 16, binary.BigEndian, CDAB)
+		if err := gotest.Expect(report.Pass).True(); err != nil {
+			t.Error(err, report)
+		}
+	}
+
+	// Порядок значения заменяет порядок устройства
+	v := &Value{Uint32: &param2, WordOrder: BADC}
+	if err := gotest.Expect(v.WriteOrder(binary.BigEndian, CDAB)).Eq([]byte{0x0b, 0x0a, 0x0d, 0x0c}); err != nil {
+		t.Error(err)
+	}
+
+	// В отчете записи байты в порядке отправки
+	if err := gotest.Expect(values[1].ReportWriteOrder(binary.BigEndian, CDAB).DataHex).Eq("0c0d0a0b"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(values[0].ReportWriteOrder(binary.BigEndian, CDAB).DataHex).Eq("0001"); err != nil {
+		t.Error(err)
+	}
+	if _, report = v.CheckOrder([]byte{0x0b, 0x0a, 0x0d, 0x0c}, 0, "", 0, 16, binary.BigEndian, CDAB); !report.Pass {
+		t.Error(report)
+	}
+}
@@ -0,0 +1,30 @@
+package master
+
+import (
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/transport"
+)
+
+// capturePort - записывает все принятые из порта байты и отправленные фреймы
+type capturePort struct {
+	transport.SerialPort
+	stream *capture.Stream
+}
+
+func (cp *capturePort) Read(p []byte) (int, error) {
+	n, err := cp.SerialPort.Read(p)
+	if n > 0 {
+		cp.stream.Received(p[:n])
+	}
+	return n, err
+}
+
+func (cp *capturePort) Write(p []byte) (int, error) {
+	cp.stream.Sent(p)
+	return cp.SerialPort.Write(p)
+}
+
+func (cp *capturePort) Close() error {
+	cp.stream.Flush()
+	return cp.SerialPort.Close()
+}
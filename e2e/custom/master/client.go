@@ -0,0 +1,129 @@
+package master
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"rtu-test/e2e/transport"
+	"time"
+)
+
+// Время ожидания ответа по умолчанию
+const DefaultTimeout = 5 * time.Second
+
+// Client - обмен фреймами с тестируемым устройством
+type Client interface {
+	// Send - отправляет фрейм устройству
+	Send(adu []byte) error
+	// Receive - ожидает фрейм от устройства. Фрейм выделяется из потока с помощью split
+	Receive(split bufio.SplitFunc) ([]byte, error)
+	// Close - закрывает порт
+	Close() error
+}
+
+func NewClient(port transport.SerialPort, timeout time.Duration) Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &client{port: port, timeout: timeout}
+}
+
+type client struct {
+	port    transport.SerialPort
+	timeout time.Duration
+
+	// Не разобранные данные из порта
+	buff []byte
+	cb   <-chan []byte
+}
+
+func (c *client) Send(adu []byte) error {
+	if c.cb == nil {
+		if err := c.port.Connect(); err != nil {
+			return err
+		}
+		c.cb = c.readChan()
+	}
+
+	// Отбрасываем данные, пришедшие до запроса
+	c.buff = nil
+	for drop := true; drop; {
+		select {
+		case data, ok := <-c.cb:
+			if !ok {
+				c.cb = nil
+				return fmt.Errorf("port closed")
+			}
+			logrus.Debugf("Drop the trash: % 02x", data)
+		default:
+			drop = false
+		}
+	}
+
+	logrus.Debugf("Send: % 02x", adu)
+	_, err := c.port.Write(adu)
+	return err
+}
+
+func (c *client) Receive(split bufio.SplitFunc) ([]byte, error) {
+	if c.cb == nil {
+		return nil, fmt.Errorf("port closed")
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	for {
+		// Разбираем накопленные данные
+		for len(c.buff) > 0 {
+			advance, token, _ := split(c.buff, false)
+			if advance > 0 && advance <= len(c.buff) {
+				c.buff = c.buff[advance:]
+			}
+			if token != nil {
+				logrus.Debugf("Receive: % 02x", token)
+				return token, nil
+			}
+			if advance <= 0 {
+				break
+			}
+		}
+
+		select {
+		case data, ok := <-c.cb:
+			if !ok {
+				c.cb = nil
+				return nil, fmt.Errorf("port closed")
+			}
+			c.buff = append(c.buff, data...)
+		case <-timer.C:
+			return nil, fmt.Errorf("timeout")
+		}
+	}
+}
+
+func (c *client) Close() error {
+	c.cb = nil
+	c.buff = nil
+	return c.port.Close()
+}
+
+// readChan - читает порт в отдельном потоке до первой ошибки
+func (c *client) readChan() <-chan []byte {
+	cb := make(chan []byte, 256)
+	go func(port transport.SerialPort) {
+		defer close(cb)
+		b := make([]byte, 256)
+		for {
+			n, err := port.Read(b)
+			if n > 0 {
+				data := make([]byte, n)
+				copy(data, b[:n])
+				cb <- data
+			}
+			if err != nil {
+				logrus.Debugf("read port: %s", err)
+				return
+			}
+		}
+	}(c.port)
+	return cb
+}
@@ -1,8 +1,18 @@
 package master
 
 import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math"
 	"rtu-test/e2e/common"
 	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/custom/slave"
+	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/template"
+	"strconv"
+	"time"
 )
 
 type CustomMasterTest struct {
@@ -18,71 +28,163 @@ type CustomMasterTest struct {
 	Disconnect bool            `yaml:"disconnect"`
 
 	// Заменяет глобальные настройки
-	Const       map[string][]string         `yaml:"const"`
-	Staffing    *module.Staffing            `yaml:"staffing"`
-	Len         map[string]*module.LenBytes `yaml:"len"`
-	Crc         map[string]*module.Crc      `yaml:"crc"`
-	WriteFormat []string                    `yaml:"writeFormat"`
-	ReadFormat  []string                    `yaml:"readFormat"`
-	ErrorFormat []string                    `yaml:"errorFormat"`
+	Const       map[string][]string `yaml:"const"`
+	Staffing    *module.Staffing    `yaml:"staffing"`
+	Len         *module.LenBytes    `yaml:"len"`
+	Crc         *module.Crc         `yaml:"crc"`
+	WriteFormat []string            `yaml:"writeFormat"`
+	ReadFormat  []string            `yaml:"readFormat"`
+	ErrorFormat []string            `yaml:"errorFormat"`
+
+	// Лог исполнителя. Задается мастером перед запуском теста
+	Log logrus.FieldLogger `yaml:"-"`
+}
+
+func (mt *CustomMasterTest) logger() logrus.FieldLogger {
+	if mt.Log == nil {
+		return logrus.StandardLogger()
+	}
+	return mt.Log
+}
+
+// Run - отправляет запрос и проверяет ответ устройства.
+// frame - описание фрейма с учетом переопределений теста. Ошибка - ошибка конфигурации фрейма
+func (mt *CustomMasterTest) Run(client Client, frame *slave.CustomSlave) (master.ReportMasterTest, error) {
+	report := master.ReportMasterTest{Name: mt.Name, Pass: true, Skip: mt.Skip}
+	mt.logger().Warn(common.Render(template.TestMasterModBusRUN, report))
+	if report.Skip != "" {
+		mt.logger().Warn(common.Render(template.TestMasterModBusSKIP, report))
+		return report, nil
+	}
+	mt.Before.PrintReportMasterTest(report)
+	data, err := mt.Exec(client, frame, &report)
+	if err != nil {
+		return report, err
+	}
+	if err := mt.Check(data, frame.Order(), &report); err != nil {
+		return report, err
+	}
+	if report.Pass {
+		mt.logger().Warn(common.Render(template.TestMasterModBusPASS, report))
+		mt.Success.PrintReportMasterTest(report)
+	} else {
+		mt.logger().Error(common.Render(template.TestMasterModBusFAIL, report))
+		mt.Error.PrintReportMasterTest(report)
+		// Дальнейшие тесты не выполняются
+		if mt.Fatal != "" {
+			mt.logger().Error(mt.Fatal)
+			return report, nil
+		}
+	}
+	mt.After.PrintReportMasterTest(report)
+	return report, nil
+}
+
+// Exec - отправляет фрейм собранный по writeFormat и ожидает ответ по readFormat или errorFormat.
+// Возвращает поле data# ответа. Ошибки обмена записываются в отчет, возвращается ошибка конфигурации фрейма
+func (mt *CustomMasterTest) Exec(client Client, frame *slave.CustomSlave, report *master.ReportMasterTest) ([]byte, error) {
+	order := frame.Order()
+	var out []byte
+	for i := range mt.Write {
+		report.Write = append(report.Write, mt.Write[i].ReportWrite(order))
+		out = append(out, mt.Write[i].Write(order)...)
+	}
+
+	action := slave.ActionRead
+	request, err := frame.GenerateAnswer(slave.ActionWrite, out)
+	if err != nil {
+		return nil, err
+	}
+	split, err := getSplit(frame, &action)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	defer func() { report.GotTime = time.Since(startTime) }()
+	if err := client.Send(request); err != nil {
+		report.GotError = err.Error()
+		return nil, nil
+	}
+
+	adu, err := client.Receive(split)
+	if err != nil {
+		report.GotError = err.Error()
+		return nil, nil
+	}
+	report.GotByte = adu
+
+	data, err := frame.ParseData(action, adu)
+	if err != nil {
+		return nil, err
+	}
+	if action == slave.ActionError {
+		// Содержимое ответа с ошибкой доступно для проверки значением error
+		report.GotError = fmt.Sprintf("% 02x", data)
+	}
+	return data, nil
 }
 
-//func (mt *CustomMasterTest) Run(port serial.Port) ReportMasterTest {
-//	// TODO Требует реализации
-//
-//	scanner := bufio.NewScanner(port)
-//	scanner.Split(frameAmsSplit)
-//	scanner.Scan()
-//
-//	return ReportMasterTest{Name: mt.Name, Pass: true, Skip: mt.Skip}
-//}
-//
-//// Сканер пакетов AMS
-//func frameAmsSplit(data []byte, atEOF bool) (int, []byte, error) {
-//	start := -1
-//	stop := -1
-//
-//	if len(data) < minSizePackage {
-//		return 0, nil, nil
-//	}
-//
-//	for i := 0; i < len(data); i++ {
-//		switch {
-//		case i == 0:
-//			// Пропускаем. Для определения начала пакета нужно 2 байта
-//			continue
-//		case data[i] == startBit && data[i-1] == startBit:
-//			// Поиск стартовых битов
-//			start = i - 1
-//		case data[i] == stopBit && data[i-1] == stopBit:
-//			// Поиск стоповых битов
-//			stop = i + 1
-//		}
-//
-//		// Удалось собрать пакет
-//		if start != -1 && stop != -1 {
-//			return stop, data[start:stop], nil
-//		}
-//	}
-//
-//	// Если отсуствуют данные для чтения
-//	if atEOF {
-//		return 0, data[:0], bufio.ErrFinalToken
-//	}
-//
-//	switch {
-//	case start == -1:
-//		// Данные - мусор очищаем буфер
-//		return len(data), data[:0], nil
-//	case start != -1 && stop == -1:
-//		// Нашли стартовый но не нашли стоповый бит. Если собираемый пакет превышает 255 байт то удаляем
-//		if (len(data) - start) > 255 {
-//			return len(data), data[:0], nil
-//		} else {
-//			return 0, nil, nil
-//		}
-//	}
-//
-//	// Запрашиваем еще данных удаляя мусор с сохранением одного бита
-//	return len(data) - 1, nil, nil
-//}
+// Check - проверяет поле data# ответа. Ошибка - неверный адрес значения в конфигурации
+func (mt *CustomMasterTest) Check(data []byte, order binary.ByteOrder, report *master.ReportMasterTest) error {
+	offsetBit := 0
+	for _, v := range mt.Expected {
+		if v.Address != "" {
+			// Делаем смещение согласно заданному адресу
+			rawAddress, err := strconv.Atoi(v.Address)
+			if err != nil {
+				return common.NewConfigError("parse address %s", err)
+			}
+			rawAddress = int(math.Abs(float64(rawAddress)))
+			if rawAddress != 0 {
+				offsetBit = (rawAddress - 1) * 8
+			}
+		}
+		offset, expected := v.Check(data, report.GotTime, report.GotError, offsetBit, 8, order)
+		// Время и ошибка не занимают места в данных
+		if v.Type() != common.Time && v.Type() != common.Error {
+			offsetBit = offset
+		}
+		if !expected.Pass {
+			report.Pass = false
+		}
+		report.Expected = append(report.Expected, expected)
+	}
+	return nil
+}
+
+// getSplit - объединяет сплиттеры ответа и ошибки. В action записывается тип найденного фрейма
+func getSplit(frame *slave.CustomSlave, action *string) (bufio.SplitFunc, error) {
+	read, err := frame.GetSplit(slave.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame.ErrorFormat) == 0 {
+		*action = slave.ActionRead
+		return read, nil
+	}
+	readError, err := frame.GetSplit(slave.ActionError)
+	if err != nil {
+		return nil, err
+	}
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := read(data, atEOF)
+		if err != nil && err != bufio.ErrFinalToken {
+			return 0, nil, err
+		}
+		if token != nil {
+			*action = slave.ActionRead
+			return advance, token, err
+		}
+		advanceError, tokenError, err := readError(data, atEOF)
+		if tokenError != nil {
+			*action = slave.ActionError
+			return advanceError, tokenError, err
+		}
+		// Отбрасываем только то, что оба формата посчитали мусором
+		if advanceError < advance {
+			advance = advanceError
+		}
+		return advance, nil, err
+	}, nil
+}
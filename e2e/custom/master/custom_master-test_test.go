@@ -1 +1,187 @@
 package master
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/custom/slave"
+	"rtu-test/e2e/modbus/master"
+	"testing"
+	"time"
+)
+
+func TestCustomMasterTest(t *testing.T) {
+	suite.Run(t, new(CustomMasterTestTestSuite))
+}
+
+type CustomMasterTestTestSuite struct {
+	suite.Suite
+}
+
+func (s *CustomMasterTestTestSuite) frame() *slave.CustomSlave {
+	return &slave.CustomSlave{
+		ByteOrder: "big",
+		MaxLen:    255,
+		Const: map[string][]string{
+			"start": {"0xfe", "0xfe"},
+			"error": {"0xee"},
+			"end":   {"0xfc"},
+		},
+		Crc: &module.Crc{
+			Algorithm: module.Mod256,
+			Read:      []string{"data#"},
+			Write:     []string{"data#"},
+			Error:     []string{"data#"},
+		},
+		WriteFormat: []string{"start", "data#", "crc#", "end"},
+		ReadFormat:  []string{"start", "data#", "crc#", "end"},
+		ErrorFormat: []string{"error", "data#", "crc#", "end"},
+	}
+}
+
+func (s *CustomMasterTestTestSuite) TestRun() {
+	var param1 uint8 = 0x03
+	var param2 uint16 = 0x0102
+	var noError = ""
+	mt := CustomMasterTest{
+		Name: "Test",
+		Write: []*common.Value{
+			{Name: "func", Uint8: &param1},
+		},
+		Expected: []*common.Value{
+			{Name: "error", Error: &noError},
+			{Name: "func", Uint8: &param1},
+			{Name: "param", Uint16: &param2},
+		},
+	}
+
+	client := NewFixtureClient([]byte{0x00, 0xfe, 0xfe, 0x03, 0x01, 0x02, 0x06, 0xfc}, nil)
+	report, err := mt.Run(client, s.frame())
+	s.NoError(err)
+
+	s.Equal([]byte{0xfe, 0xfe, 0x03, 0x03, 0xfc}, client.Value)
+	s.Equal([]byte{0xfe, 0xfe, 0x03, 0x01, 0x02, 0x06, 0xfc}, report.GotByte)
+	s.True(report.Pass)
+	s.Len(report.Expected, 3)
+	s.Len(report.Write, 1)
+	s.Equal("03", report.Write[0].DataHex)
+}
+
+// frames - запись кадров в памяти
+type frames struct {
+	directions []capture.Direction
+	data       [][]byte
+}
+
+func (f *frames) Frame(direction capture.Direction, data []byte) {
+	f.directions = append(f.directions, direction)
+	f.data = append(f.data, data)
+}
+
+func (f *frames) Close() error {
+	return nil
+}
+
+// answerPort - порт, который на каждый запрос отвечает answer
+type answerPort struct {
+	answer []byte
+	read   chan []byte
+}
+
+func (p *answerPort) Connect() error {
+	if p.read == nil {
+		p.read = make(chan []byte, 1)
+	}
+	return nil
+}
+
+func (p *answerPort) Close() error {
+	return nil
+}
+
+func (p *answerPort) Read(b []byte) (int, error) {
+	data, ok := <-p.read
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(b, data), nil
+}
+
+func (p *answerPort) Write(b []byte) (int, error) {
+	p.read <- p.answer
+	return len(b), nil
+}
+
+func (s *CustomMasterTestTestSuite) TestRunCapture() {
+	var param1 uint8 = 0x03
+	mt := CustomMasterTest{
+		Name:  "Test",
+		Write: []*common.Value{{Name: "func", Uint8: &param1}},
+	}
+
+	traffic := &frames{}
+	port := &capturePort{
+		SerialPort: &answerPort{answer: []byte{0x00, 0xfe, 0xfe, 0x03, 0x01, 0x02, 0x06, 0xfc}},
+		stream:     capture.NewStream(traffic, time.Second),
+	}
+	client := NewClient(port, time.Second)
+	report, err := mt.Run(client, s.frame())
+	s.NoError(err)
+	s.True(report.Pass)
+	s.NoError(client.Close())
+
+	// Записывается все, что пришло по линии, включая мусор перед кадром ответа
+	s.Equal([]capture.Direction{capture.TX, capture.RX}, traffic.directions)
+	s.Equal([][]byte{{0xfe, 0xfe, 0x03, 0x03, 0xfc}, {0x00, 0xfe, 0xfe, 0x03, 0x01, 0x02, 0x06, 0xfc}}, traffic.data)
+}
+
+func (s *CustomMasterTestTestSuite) TestExecError() {
+	mt := CustomMasterTest{Name: "Test"}
+
+	client := NewFixtureClient([]byte{0xee, 0x0a, 0x04, 0x0e, 0xfc}, nil)
+	report := master.ReportMasterTest{}
+	data, err := mt.Exec(client, s.frame(), &report)
+	s.NoError(err)
+
+	s.Equal([]byte{0x0a, 0x04}, data)
+	s.Equal("0a 04", report.GotError)
+
+	client = NewFixtureClient(nil, fmt.Errorf("timeout"))
+	report = master.ReportMasterTest{}
+	data, err = mt.Exec(client, s.frame(), &report)
+	s.NoError(err)
+	s.Nil(data)
+	s.Equal("timeout", report.GotError)
+	s.True(report.GotTime > 0)
+}
+
+func (s *CustomMasterTestTestSuite) TestCheck() {
+	var param1 uint8 = 0x03
+	var param2 uint16 = 0x0201
+	mt := CustomMasterTest{
+		Expected: []*common.Value{
+			{Name: "param2", Address: "2", Uint16: &param2},
+			{Name: "param1", Address: "1", Uint8: &param1},
+		},
+	}
+	report := master.ReportMasterTest{Pass: true}
+	s.NoError(mt.Check([]byte{0x03, 0x01, 0x02}, s.frame().Order(), &report))
+	s.False(report.Pass)
+	s.False(report.Expected[0].Pass)
+	s.True(report.Expected[1].Pass)
+}
+
+func (s *CustomMasterTestTestSuite) TestCheckAddressError() {
+	var param1 uint8 = 0x03
+	mt := CustomMasterTest{
+		Expected: []*common.Value{{Name: "param1", Address: "first", Uint8: &param1}},
+	}
+	report := master.ReportMasterTest{Pass: true}
+	err := mt.Check([]byte{0x03}, s.frame().Order(), &report)
+	var configError *common.ConfigError
+	s.True(errors.As(err, &configError), "%v", err)
+}
@@ -2,13 +2,14 @@ package master
 
 import (
 	"context"
-	"fmt"
 	"github.com/sirupsen/logrus"
-	"go.bug.st/serial"
+	"rtu-test/e2e/capture"
 	"rtu-test/e2e/common"
 	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/custom/slave"
 	"rtu-test/e2e/modbus/master"
 	"rtu-test/e2e/template"
+	"rtu-test/e2e/transport"
 	"strings"
 )
 
@@ -22,58 +23,105 @@ type CustomMaster struct {
 	Filter    string `yaml:"filter"`
 	ByteOrder string `yaml:"byteOrder"`
 
-	Const       map[string][]string         `yaml:"const"`
-	Staffing    *module.Staffing            `yaml:"staffing"`
-	Len         map[string]*module.LenBytes `yaml:"len"`
-	Crc         map[string]*module.Crc      `yaml:"crc"`
-	WriteFormat []string                    `yaml:"writeFormat"`
-	ReadFormat  []string                    `yaml:"readFormat"`
-	ErrorFormat []string                    `yaml:"errorFormat"`
+	Const       map[string][]string `yaml:"const"`
+	Staffing    *module.Staffing    `yaml:"staffing"`
+	MaxLen      int                 `yaml:"maxLen"`
+	Len         *module.LenBytes    `yaml:"len"`
+	Crc         *module.Crc         `yaml:"crc"`
+	WriteFormat []string            `yaml:"writeFormat"`
+	ReadFormat  []string            `yaml:"readFormat"`
+	ErrorFormat []string            `yaml:"errorFormat"`
 
 	Tests map[string][]*CustomMasterTest `yaml:"tests"`
-}
+	// Запись трафика: pcap=path или hex=path
+	Capture string `yaml:"capture"`
 
-func (m *CustomMaster) getPort() (serial.Port, error) {
-	parity := serial.NoParity
-	switch m.Parity {
-	case "N":
-		parity = serial.NoParity
-	case "E":
-		parity = serial.EvenParity
-	case "O":
-		parity = serial.OddParity
+	// Лог исполнителя. По умолчанию стандартный лог logrus
+	Log logrus.FieldLogger `yaml:"-"`
 
-	}
+	// Порядок групп в файле конфигурации
+	groupOrder []string
+}
 
-	stopBits := serial.TwoStopBits
+// getFrame - собирает описание фрейма с учетом переопределений в тесте
+func (m *CustomMaster) getFrame(test *CustomMasterTest) *slave.CustomSlave {
+	frame := &slave.CustomSlave{
+		ByteOrder:   m.ByteOrder,
+		Const:       m.Const,
+		Staffing:    m.Staffing,
+		MaxLen:      m.MaxLen,
+		Len:         m.Len,
+		Crc:         m.Crc,
+		WriteFormat: m.WriteFormat,
+		ReadFormat:  m.ReadFormat,
+		ErrorFormat: m.ErrorFormat,
+	}
+	if test == nil {
+		return frame
+	}
+	if test.Const != nil {
+		frame.Const = make(map[string][]string)
+		for name, value := range m.Const {
+			frame.Const[name] = value
+		}
+		for name, value := range test.Const {
+			frame.Const[name] = value
+		}
+	}
+	if test.Staffing != nil {
+		frame.Staffing = test.Staffing
+	}
+	if test.Len != nil {
+		frame.Len = test.Len
+	}
+	if test.Crc != nil {
+		frame.Crc = test.Crc
+	}
+	if test.WriteFormat != nil {
+		frame.WriteFormat = test.WriteFormat
+	}
+	if test.ReadFormat != nil {
+		frame.ReadFormat = test.ReadFormat
+	}
+	if test.ErrorFormat != nil {
+		frame.ErrorFormat = test.ErrorFormat
+	}
+	return frame
+}
 
-	switch m.StopBits {
-	case 1:
-		stopBits = serial.OneStopBit
-	case 15:
-		stopBits = serial.OnePointFiveStopBits
-	case 2:
-		stopBits = serial.TwoStopBits
+func (m *CustomMaster) logger() logrus.FieldLogger {
+	if m.Log == nil {
+		return logrus.StandardLogger()
 	}
+	return m.Log
+}
 
-	return serial.Open(m.Port, &serial.Mode{
+func (m *CustomMaster) getClient(traffic capture.Capture) Client {
+	port := transport.NewSerialPort(&transport.SerialPortConfig{
+		Port:     m.Port,
 		BaudRate: m.BoundRate,
 		DataBits: m.DataBits,
-		Parity:   parity,
-		StopBits: stopBits,
+		Parity:   m.Parity,
+		StopBits: m.StopBits,
 	})
+	if traffic != nil {
+		port = &capturePort{SerialPort: port, stream: capture.NewStream(traffic, transport.SilentInterval(m.BoundRate))}
+	}
+	return NewClient(port, common.ParseDuration(m.Timeout))
 }
 
 func (m *CustomMaster) Run(ctx context.Context, reports *master.ReportGroups) error {
-	// TODO Доработать этот функционал
-	port, err := m.getPort()
-	if err != nil {
-		return fmt.Errorf("port error %s", err)
+	var traffic capture.Capture
+	if m.Capture != "" {
+		c, err := capture.Open(m.Capture, capture.LinkCustom)
+		if err != nil {
+			return common.NewConfigError("open capture: %s", err)
+		}
+		defer c.Close()
+		traffic = c
 	}
-	defer port.Close()
-
-	// TODO Для передачи детям и переопределния
-	ctx = context.WithValue(ctx, "const", m.Const)
+	client := m.getClient(traffic)
+	defer client.Close()
 
 	filterGroup := ""
 	filterTest := ""
@@ -85,20 +133,35 @@ func (m *CustomMaster) Run(ctx context.Context, reports *master.ReportGroups) er
 		filterGroup = filter[0]
 	}
 
-	for group, tests := range m.Tests {
+	for _, group := range m.groups() {
+		tests := m.Tests[group]
 		if filterGroup != "" && filterGroup != "all" && filterGroup != group {
 			continue
 		}
 		report := master.ReportGroup{Name: group}
-		logrus.Warnf(common.Render(template.TestMasterModBusGROUP, report))
+		m.logger().Warnf(common.Render(template.TestMasterModBusGROUP, report))
 		for _, test := range tests {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			if filterTest != "" && filterTest != "all" && filterTest != test.Name {
 				continue
 			}
-			//report.Tests = append(report.Tests, test.Run(port))
+			test.Log = m.logger()
+			testReport, err := test.Run(client, m.getFrame(test))
+			if err != nil {
+				return err
+			}
+			report.Tests = append(report.Tests, testReport)
 			// При необходимости закрываем порт
 			if test.Disconnect {
-				port.Close()
+				client.Close()
+			}
+
+			// Фатальная ошибка прекращает выполнение тестов
+			if !testReport.Pass && test.Fatal != "" {
+				reports.ReportGroup = append(reports.ReportGroup, report)
+				return nil
 			}
 		}
 		reports.ReportGroup = append(reports.ReportGroup, report)
@@ -1 +1,79 @@
 package master
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/modbus/master"
+	"testing"
+)
+
+func TestCustomMaster(t *testing.T) {
+	suite.Run(t, new(CustomMasterTestSuite))
+}
+
+type CustomMasterTestSuite struct {
+	suite.Suite
+}
+
+func (s *CustomMasterTestSuite) TestGetFrame() {
+	m := CustomMaster{
+		ByteOrder: "little",
+		Const: map[string][]string{
+			"start": {"0xfe"},
+			"end":   {"0xfc"},
+		},
+		Crc:         &module.Crc{Algorithm: module.Mod256},
+		WriteFormat: []string{"start", "data#", "end"},
+		ReadFormat:  []string{"start", "data#", "end"},
+	}
+
+	frame := m.getFrame(nil)
+	s.Equal(m.Const, frame.Const)
+	s.Equal(m.ReadFormat, frame.ReadFormat)
+
+	test := &CustomMasterTest{
+		Const:      map[string][]string{"end": {"0xfd"}},
+		Crc:        &module.Crc{Algorithm: module.ModBus},
+		ReadFormat: []string{"start", "data#", "crc#", "end"},
+	}
+	frame = m.getFrame(test)
+	s.Equal([]string{"0xfe"}, frame.Const["start"])
+	s.Equal([]string{"0xfd"}, frame.Const["end"])
+	s.Equal([]string{"0xfc"}, m.Const["end"])
+	s.Equal(module.ModBus, frame.Crc.Algorithm)
+	s.Equal(test.ReadFormat, frame.ReadFormat)
+	s.Equal(m.WriteFormat, frame.WriteFormat)
+}
+
+func (s *CustomMasterTestSuite) TestRunCaptureError() {
+	m := CustomMaster{Capture: "pcap=" + filepath.Join(s.T().TempDir(), "missing", "out.pcapng")}
+
+	// Ошибка файла записи - ошибка конфигурации, а не порта
+	err := m.Run(context.Background(), &master.ReportGroups{})
+	var configError *common.ConfigError
+	s.True(errors.As(err, &configError), "%v", err)
+}
+
+func (s *CustomMasterTestSuite) TestGroups() {
+	var m CustomMaster
+	err := yaml.Unmarshal([]byte(`
+tests:
+  write:
+    - name: Write
+  read:
+    - name: Read
+  check:
+    - name: Check
+`), &m)
+	s.NoError(err)
+	s.Equal([]string{"write", "read", "check"}, m.groups())
+
+	// Группы заданные без файла конфигурации выполняются по имени
+	m = CustomMaster{Tests: map[string][]*CustomMasterTest{"b": nil, "a": nil}}
+	s.Equal([]string{"a", "b"}, m.groups())
+}
@@ -0,0 +1,38 @@
+package master
+
+import (
+	"bufio"
+	"time"
+)
+
+func NewFixtureClient(results []byte, err error) *FixtureClient {
+	return &FixtureClient{Results: results, Error: err, Sleep: 1}
+}
+
+type FixtureClient struct {
+	Value  []byte
+	Sleep  time.Duration
+	Closed bool
+
+	Results []byte
+	Error   error
+}
+
+func (f *FixtureClient) Send(adu []byte) error {
+	f.Value = adu
+	return nil
+}
+
+func (f *FixtureClient) Receive(split bufio.SplitFunc) ([]byte, error) {
+	time.Sleep(f.Sleep)
+	if f.Error != nil {
+		return nil, f.Error
+	}
+	_, token, _ := split(f.Results, true)
+	return token, nil
+}
+
+func (f *FixtureClient) Close() error {
+	f.Closed = true
+	return nil
+}
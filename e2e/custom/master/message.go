@@ -2,6 +2,8 @@ package master
 
 import (
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/display"
+	"rtu-test/e2e/modbus/master"
 	"time"
 )
 
@@ -17,3 +19,9 @@ func (m *Message) GetMessage() string {
 func (m *Message) GetPause() time.Duration {
 	return common.ParseDuration(m.Pause)
 }
+
+func (m *Message) PrintReportMasterTest(report master.ReportMasterTest) {
+	d := common.ParseDuration(m.Pause)
+	report.Pause = d.String()
+	display.Console().Print(m, report)
+}
@@ -0,0 +1,37 @@
+package master
+
+import (
+	"gopkg.in/yaml.v3"
+	"rtu-test/e2e/modbus/master"
+	"sort"
+)
+
+// UnmarshalYAML - дополнительно запоминает порядок групп тестов в файле
+func (m *CustomMaster) UnmarshalYAML(value *yaml.Node) error {
+	type customMaster CustomMaster
+	if err := value.Decode((*customMaster)(m)); err != nil {
+		return err
+	}
+	m.groupOrder = master.YamlKeys(value, "tests")
+	return nil
+}
+
+// groups - порядок выполнения групп тестов: в порядке файла, затем группы заданные без файла по имени
+func (m *CustomMaster) groups() []string {
+	var names []string
+	added := make(map[string]bool)
+	for _, name := range m.groupOrder {
+		if _, ok := m.Tests[name]; ok && !added[name] {
+			added[name] = true
+			names = append(names, name)
+		}
+	}
+	var other []string
+	for name := range m.Tests {
+		if !added[name] {
+			other = append(other, name)
+		}
+	}
+	sort.Strings(other)
+	return append(names, other...)
+}
@@ -0,0 +1,31 @@
+package master
+
+import (
+	"fmt"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/common"
+)
+
+// Validate - проверка форматов фрейма с учетом переопределений в тестах и записи трафика
+func (m *CustomMaster) Validate() []error {
+	errs := m.getFrame(nil).ValidateFrame()
+	if err := capture.Validate(m.Capture); err != nil {
+		errs = append(errs, common.NewFieldError("capture", "%s", err))
+	}
+	found := make(map[string]bool)
+	for _, err := range errs {
+		found[err.Error()] = true
+	}
+	for _, group := range m.groups() {
+		for i, test := range m.Tests[group] {
+			for _, err := range m.getFrame(test).ValidateFrame() {
+				// Ошибки общих настроек уже выведены
+				if found[err.Error()] {
+					continue
+				}
+				errs = append(errs, common.PrefixErrors(fmt.Sprintf("tests.%s.%d", group, i), []error{err})...)
+			}
+		}
+	}
+	return errs
+}
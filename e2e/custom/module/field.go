@@ -0,0 +1,20 @@
+package module
+
+import "strings"
+
+// Поля фрейма в форматах. Имя относится к полю, если начинается с его названия, например data#payload
+const (
+	FieldData = "data#"
+	FieldLen  = "len#"
+	FieldCrc  = "crc#"
+)
+
+// Field - поле фрейма, к которому относится имя из формата. Для констант возвращает пустую строку
+func Field(name string) string {
+	for _, field := range []string{FieldData, FieldLen, FieldCrc} {
+		if strings.HasPrefix(name, field) {
+			return field
+		}
+	}
+	return ""
+}
@@ -10,7 +10,7 @@ type LenBytes struct {
 	Error      []string `yaml:"error"`
 }
 
-// Проверяет есть ли текущий параметр в массиве
+// Проверяет есть ли текущее поле в массиве. Имена сравниваются по правилу Field
 func (l *LenBytes) Contains(action, param string) bool {
 	var data []string
 	switch action {
@@ -22,8 +22,9 @@ func (l *LenBytes) Contains(action, param string) bool {
 		data = l.Error
 	}
 
+	field := Field(param)
 	for _, read := range data {
-		if read == param {
+		if read == param || field != "" && Field(read) == field {
 			return true
 		}
 	}
@@ -28,4 +28,8 @@ func (s *LenBytesTestSuite) TestContains() {
 	s.False(l.Contains(ActionWrite, "crc#"))
 	s.False(l.Contains(ActionError, "data#"))
 	s.True(l.Contains(ActionError, "crc#"))
+
+	// Поле с уточнением имени
+	l.Write = []string{"data#payload"}
+	s.True(l.Contains(ActionWrite, "data#"))
 }
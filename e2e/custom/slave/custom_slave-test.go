@@ -2,9 +2,9 @@ package slave
 
 import (
 	"encoding/binary"
-	"github.com/sirupsen/logrus"
 	"math"
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/fault"
 	"strconv"
 )
 
@@ -24,13 +24,16 @@ type CustomSlaveTest struct {
 	Success    Message        `yaml:"success"`
 	Error      Message        `yaml:"error"`
 	After      Message        `yaml:"after"`
+	// Ошибки в ответе теста. Заменяют общие ошибки слейва
+	Faults []*fault.Fault `yaml:"faults"`
 }
 
-// Проверяем пакет принадлежит этому тесту или нет с использованием Pattern
-func (s *CustomSlaveTest) Check(data []byte, previousTest string) bool {
+// Проверяем пакет принадлежит этому тесту или нет с использованием Pattern.
+// Ошибка - неверный адрес значения в конфигурации
+func (s *CustomSlaveTest) Check(data []byte, previousTest string) (bool, error) {
 	// Если время жизни теста истекло
 	if s.LifeTime < 0 {
-		return false
+		return false, nil
 	}
 
 	// Соблюдаем порядок выполнения тестов
@@ -43,7 +46,7 @@ func (s *CustomSlaveTest) Check(data []byte, previousTest string) bool {
 			}
 		}
 		if !previosCheck {
-			return false
+			return false, nil
 		}
 	}
 
@@ -55,7 +58,7 @@ func (s *CustomSlaveTest) Check(data []byte, previousTest string) bool {
 			// Делаем смещение согласно заданному адресу
 			rawAddress, err := strconv.Atoi(s.Pattern[i].Address)
 			if err != nil {
-				logrus.Fatalf("parse address %s", err)
+				return false, common.NewConfigError("parse address %s", err)
 			}
 			rawAddress = int(math.Abs(float64(rawAddress)))
 			if rawAddress != 0 {
@@ -70,7 +73,7 @@ func (s *CustomSlaveTest) Check(data []byte, previousTest string) bool {
 
 	// Если значение не установленно то тест выигрывает всегда
 	if s.LifeTime == 0 {
-		return result
+		return result, nil
 	}
 
 	s.LifeTime--
@@ -79,11 +82,11 @@ func (s *CustomSlaveTest) Check(data []byte, previousTest string) bool {
 		s.LifeTime--
 	}
 
-	return result
+	return result, nil
 }
 
 // Запускает тест и поверяет значение
-func (s *CustomSlaveTest) Exec(data []byte, report *ReportCustomSlaveTest) {
+func (s *CustomSlaveTest) Exec(data []byte, report *ReportCustomSlaveTest) error {
 	offsetBit := 0
 	report.Pass = true
 	for i := range s.Expected {
@@ -91,7 +94,7 @@ func (s *CustomSlaveTest) Exec(data []byte, report *ReportCustomSlaveTest) {
 			// Делаем смещение согласно заданному адресу
 			rawAddress, err := strconv.Atoi(s.Expected[i].Address)
 			if err != nil {
-				logrus.Fatalf("parse address %s", err)
+				return common.NewConfigError("parse address %s", err)
 			}
 			rawAddress = int(math.Abs(float64(rawAddress)))
 			if rawAddress != 0 {
@@ -105,7 +108,7 @@ func (s *CustomSlaveTest) Exec(data []byte, report *ReportCustomSlaveTest) {
 			report.Pass = false
 		}
 	}
-	return
+	return nil
 }
 
 // Возвращает данны для записи в компорт
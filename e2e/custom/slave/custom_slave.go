@@ -4,13 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/sirupsen/logrus"
+	"io"
+	"rtu-test/e2e/capture"
 	"rtu-test/e2e/common"
 	"rtu-test/e2e/custom/module"
 	"rtu-test/e2e/display"
+	"rtu-test/e2e/fault"
 	"rtu-test/e2e/template"
 	"rtu-test/e2e/transport"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,6 +25,16 @@ const (
 	ActionError = "Error"
 )
 
+// Реакция на фрейм с неверной контрольной суммой
+const (
+	// Фрейм отбрасывается сплиттером
+	CrcErrorDrop = "drop"
+	// На фрейм отвечаем writeError теста
+	CrcErrorWriteError = "writeError"
+	// Тест считается проваленным
+	CrcErrorFail = "fail"
+)
+
 type CustomSlave struct {
 	Port            string              `yaml:"port"`
 	BoundRate       int                 `yaml:"boundRate"`
@@ -33,17 +48,64 @@ type CustomSlave struct {
 	MaxLen          int                 `yaml:"maxLen"`
 	Len             *module.LenBytes    `yaml:"len"`
 	Crc             *module.Crc         `yaml:"crc"`
+	CrcError        string              `yaml:"crcError"`
 	WriteFormat     []string            `yaml:"writeFormat"`
 	ReadFormat      []string            `yaml:"readFormat"`
 	ErrorFormat     []string            `yaml:"errorFormat"`
 	CustomSlaveTest []CustomSlaveTest   `yaml:"test"`
+	// Запись трафика: pcap=path или hex=path
+	Capture string `yaml:"capture"`
+	// Ошибки в ответах всех тестов. Ошибки теста заменяют общие
+	Faults []*fault.Fault `yaml:"faults"`
+
+	// Лог исполнителя. По умолчанию стандартный лог logrus
+	Log logrus.FieldLogger `yaml:"-"`
+	// Вызывается, когда порт открыт и слейв готов принимать запросы
+	OnReady func() `yaml:"-"`
+
+	// Отчеты выполненных тестов
+	reports   []*ReportCustomSlaveTest
+	muReports sync.Mutex
+}
+
+// Reports - отчеты выполненных тестов
+func (s *CustomSlave) Reports() []*ReportCustomSlaveTest {
+	s.muReports.Lock()
+	defer s.muReports.Unlock()
+	return append([]*ReportCustomSlaveTest{}, s.reports...)
+}
+
+func (s *CustomSlave) addReport(report *ReportCustomSlaveTest) {
+	s.muReports.Lock()
+	s.reports = append(s.reports, report)
+	s.muReports.Unlock()
 }
 
-// TODO Сделать проверку контрольной суммы
+func (s *CustomSlave) logger() logrus.FieldLogger {
+	if s.Log == nil {
+		return logrus.StandardLogger()
+	}
+	return s.Log
+}
 
 // Запускает тест на выполнение
 // TODO тесты
 func (s *CustomSlave) Run() error {
+	// Собираем сканер пакетов, ошибки формата выводятся до открытия порта
+	split, err := s.GetSplit(ActionRead)
+	if err != nil {
+		return err
+	}
+	var traffic *capture.Stream
+	if s.Capture != "" {
+		c, err := capture.Open(s.Capture, capture.LinkCustom)
+		if err != nil {
+			return common.NewConfigError("open capture: %s", err)
+		}
+		defer c.Close()
+		traffic = capture.NewStream(c, s.silentInterval())
+		defer traffic.Flush()
+	}
 	port := transport.NewSerialPort(&transport.SerialPortConfig{
 		Port:     s.Port,
 		BaudRate: s.BoundRate,
@@ -51,93 +113,141 @@ func (s *CustomSlave) Run() error {
 		Parity:   s.Parity,
 		StopBits: s.StopBits,
 	})
-	listen := bufio.NewScanner(port)
-	// Собираем сканер пакетов,
-	start, lenPosition, suffix, end := s.ParseReadFormat()
-	// Определяем парсер пакетов по длине или по стартовым и стоповым байтам
-	if lenPosition == 0 {
-		listen.Split(s.GetSplitStartEnd(start, end))
-	} else {
-		listen.Split(s.GetSplitLen(start, lenPosition, suffix))
+	if err := port.Connect(); err != nil {
+		return err
+	}
+	defer port.Close()
+	if s.OnReady != nil {
+		s.OnReady()
 	}
+	// Записываются все принятые байты, а не только кадры, выделенные сплиттером
+	var reader io.Reader = port
+	if traffic != nil {
+		reader = traffic.Reader(port)
+	}
+	listen := bufio.NewScanner(reader)
+	listen.Split(split)
 
 	previousTest := ""
 	// Включаем прослушку ком порта
 	for listen.Scan() {
+		// Достаем только данные
+		data, err := s.ParseReadData(listen.Bytes())
+		if err != nil {
+			return err
+		}
 		for i := range s.CustomSlaveTest {
-			// Достаем только данные
-			data := s.ParseReadData(listen.Bytes())
 
-			if s.CustomSlaveTest[i].Check(data, previousTest) {
+			match, err := s.CustomSlaveTest[i].Check(data, previousTest)
+			if err != nil {
+				return err
+			}
+			if match {
 				// Запоминаем текущий тест
 				previousTest = s.CustomSlaveTest[i].Name
 
 				// Получаем отчет для использования в сообщениях
 				report := s.CustomSlaveTest[i].GetReport()
 				report.GotByte = listen.Bytes()
+				if report.Crc, err = s.ReportCrc(ActionRead, listen.Bytes()); err != nil {
+					return err
+				}
+				crcFail := report.Crc != nil && !report.Crc.Pass
 
-				logrus.Warn(common.Render(template.TestSlaveCustomRUN, report))
+				s.logger().Warn(common.Render(template.TestSlaveCustomRUN, report))
 
 				if report.Skip != "" {
-					logrus.Warn(common.Render(template.TestSlaveCustomSKIP, report))
+					s.logger().Warn(common.Render(template.TestSlaveCustomSKIP, report))
+					s.addReport(report)
 					continue
 				}
 
 				// Проверяем результат
-				s.CustomSlaveTest[i].Exec(data, report)
-				//
+				if err := s.CustomSlaveTest[i].Exec(data, report); err != nil {
+					return err
+				}
+				if crcFail && s.CrcError == CrcErrorFail {
+					report.Pass = false
+				}
 
 				// Сообщение перед тестом
 				display.Console().Print(&s.CustomSlaveTest[i].Before, report)
 
 				// определяем порядок байт
-				var order binary.ByteOrder = binary.BigEndian
-				if s.ByteOrder == "little" {
-					order = binary.LittleEndian
-				}
+				order := s.Order()
 
 				// Задержка перед ответом
 				duration := common.ParseDuration(s.CustomSlaveTest[i].Timeout)
 
+				// Ошибки теста заменяют общие
+				faults := s.Faults
+				if s.CustomSlaveTest[i].Faults != nil {
+					faults = s.CustomSlaveTest[i].Faults
+				}
+				plan := fault.Choose(faults)
+				report.Faults = plan.Names()
+				if len(plan) > 0 {
+					s.logger().Warnf("Inject faults: %s", strings.Join(report.Faults, ", "))
+				}
+
 				// Готовим ответ для устройства. Ошибка в приоритете
-				if len(s.CustomSlaveTest[i].WriteError) > 0 {
+				if crcFail && s.CrcError == CrcErrorWriteError && len(s.CustomSlaveTest[i].WriteError) == 0 {
+					s.logger().Debugf("Crc fail. writeError is not specified, no answer")
+				} else if len(s.CustomSlaveTest[i].WriteError) > 0 {
 					// Отвечаем тестируемому устройству
 					out := make([]byte, 0)
 					out, report.Write = s.CustomSlaveTest[i].ReturnError(order)
-					out = s.GenerateAnswer(ActionError, out)
-					if duration > 0 {
-						logrus.Debugf("Timeout %s", duration)
-						time.Sleep(duration)
+					frames, delay, err := s.answer(ActionError, out, plan)
+					if err != nil {
+						return err
 					}
-					logrus.Debugf("Send error: % 02x", out)
-					if _, err := port.Write(out); err != nil {
-						logrus.Fatalf("write answer error: %s", err.Error())
+					if duration+delay > 0 {
+						s.logger().Debugf("Timeout %s", duration+delay)
+						time.Sleep(duration + delay)
+					}
+					for _, out := range frames {
+						s.logger().Debugf("Send error: % 02x", out)
+						if traffic != nil {
+							traffic.Sent(out)
+						}
+						if _, err := port.Write(out); err != nil {
+							return fmt.Errorf("write answer error: %s", err)
+						}
 					}
 				} else if len(s.CustomSlaveTest[i].Write) > 0 {
 					// Отвечаем тестируемому устройству
 					out := make([]byte, 0)
 					out, report.Write = s.CustomSlaveTest[i].ReturnData(order)
-					out = s.GenerateAnswer(ActionWrite, out)
-					if duration > 0 {
-						logrus.Debugf("Timeout %s", duration)
-						time.Sleep(duration)
+					frames, delay, err := s.answer(ActionWrite, out, plan)
+					if err != nil {
+						return err
 					}
-					logrus.Debugf("Send answer: % 02x", out)
-					if _, err := port.Write(out); err != nil {
-						logrus.Fatalf("write answer error: %s", err.Error())
+					if duration+delay > 0 {
+						s.logger().Debugf("Timeout %s", duration+delay)
+						time.Sleep(duration + delay)
+					}
+					for _, out := range frames {
+						s.logger().Debugf("Send answer: % 02x", out)
+						if traffic != nil {
+							traffic.Sent(out)
+						}
+						if _, err := port.Write(out); err != nil {
+							return fmt.Errorf("write answer error: %s", err)
+						}
 					}
 				}
 
 				// отчет о проделанном тесте
+				s.addReport(report)
 				if report.Pass {
-					logrus.Warn(common.Render(template.TestSlaveCustomPASS, report))
+					s.logger().Warn(common.Render(template.TestSlaveCustomPASS, report))
 					display.Console().Print(&s.CustomSlaveTest[i].Success, report)
 
 				} else {
-					logrus.Warn(common.Render(template.TestSlaveCustomFAIL, report))
+					s.logger().Warn(common.Render(template.TestSlaveCustomFAIL, report))
 					display.Console().Print(&s.CustomSlaveTest[i].Error, report)
 					if s.CustomSlaveTest[i].Fatal != "" {
-						logrus.Error(common.Render(template.TestSlaveCustomFATAL, report))
+						s.logger().Error(common.Render(template.TestSlaveCustomFATAL, report))
 						return nil
 					}
 				}
@@ -147,15 +257,97 @@ func (s *CustomSlave) Run() error {
 			}
 		}
 	}
-	return nil
+	// Ошибка порта
+	return listen.Err()
+}
+
+// GetSplit - создает сплиттер фреймов согласно формату action (read, error).
+// Пакет определяется по длине или по стартовым и стоповым байтам
+func (s *CustomSlave) GetSplit(action string) (bufio.SplitFunc, error) {
+	start, lenPosition, suffix, end, err := s.ParseFormat(action)
+	if err != nil {
+		return nil, err
+	}
+	if lenPosition == 0 {
+		return s.getSplitStartEnd(action, start, end), nil
+	}
+	return s.getSplitLen(action, start, lenPosition, suffix), nil
+}
+
+// silentInterval - пауза между кадрами в записи трафика
+func (s *CustomSlave) silentInterval() time.Duration {
+	if silentInterval := common.ParseDuration(s.SilentInterval); silentInterval > 0 {
+		return silentInterval
+	}
+	return transport.SilentInterval(s.BoundRate)
+}
+
+// dropCrc - фреймы с неверной контрольной суммой отбрасываются сплиттером
+func (s *CustomSlave) dropCrc() bool {
+	return s.CrcError == "" || s.CrcError == CrcErrorDrop
+}
+
+// Order - возвращает порядок байт согласно конфигурации
+func (s *CustomSlave) Order() binary.ByteOrder {
+	if s.ByteOrder == "little" {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// getFormat - возвращает формат фрейма для action
+func (s *CustomSlave) getFormat(action string) ([]string, error) {
+	switch action {
+	case ActionRead:
+		return s.ReadFormat, nil
+	case ActionWrite:
+		return s.WriteFormat, nil
+	case ActionError:
+		return s.ErrorFormat, nil
+	}
+	return nil, fmt.Errorf("action not found %s", action)
+}
+
+// constBytes - байты константы name из consts
+func (s *CustomSlave) constBytes(consts map[string][]string, name string) ([]byte, error) {
+	constanta, ok := consts[name]
+	if !ok {
+		return nil, common.NewConfigError("constant not found %s", name)
+	}
+	var out []byte
+	for _, stringBytes := range constanta {
+		data, err := common.ParseStringByte(stringBytes)
+		if err != nil {
+			return nil, common.NewConfigError("constant %s: %s", name, err)
+		}
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// lenAction - переводит action в формат настроек длины
+func (s *CustomSlave) lenAction(action string) string {
+	switch action {
+	case ActionWrite:
+		return module.ActionWrite
+	case ActionError:
+		return module.ActionError
+	default:
+		return module.ActionRead
+	}
 }
 
 // CalcCrc - Подсчитывает контрольную сумму согласно шаблону.
 // action - read, write, error
 // data - чистые данные из теста (writeError, expected, write) без staffing byte
-func (s *CustomSlave) CalcCrc(action string, data []byte) []byte {
+func (s *CustomSlave) CalcCrc(action string, data []byte) ([]byte, error) {
+	return s.calcCrc(action, data, s.Const)
+}
+
+// calcCrc - подсчитывает контрольную сумму с константами consts
+func (s *CustomSlave) calcCrc(action string, data []byte, consts map[string][]string) ([]byte, error) {
 	if s.Crc == nil {
-		logrus.Fatal("Crc is not specified in the configuration")
+		return nil, common.NewConfigError("crc is not specified in the configuration")
 	}
 
 	var tmpData []byte
@@ -169,53 +361,145 @@ func (s *CustomSlave) CalcCrc(action string, data []byte) []byte {
 	case ActionError:
 		format = s.Crc.Error
 	default:
-		logrus.Fatalf("Action not found %s", action)
+		return nil, fmt.Errorf("action not found %s", action)
 	}
 
 	for _, name := range format {
 		if strings.Contains(name, "#") {
 			if strings.HasPrefix(name, "len#") {
-				_, l := s.CalcLen(action, data)
-				tmpData = append(tmpData, s.StaffingProcessing(s.Crc.Staffing, l)...)
+				_, l, err := s.CalcLen(action, data)
+				if err != nil {
+					return nil, err
+				}
+				l, err = s.StaffingProcessing(s.Crc.Staffing, l)
+				if err != nil {
+					return nil, err
+				}
+				tmpData = append(tmpData, l...)
 			}
 			if strings.HasPrefix(name, "data#") {
-				tmpData = append(tmpData, s.StaffingProcessing(s.Crc.Staffing, data)...)
+				staffed, err := s.StaffingProcessing(s.Crc.Staffing, data)
+				if err != nil {
+					return nil, err
+				}
+				tmpData = append(tmpData, staffed...)
 			}
 			continue
 		}
-		if constanta, ok := s.Const[name]; ok {
-			for _, stringBytes := range constanta {
-				dataConst, err := common.ParseStringByte(stringBytes)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				tmpData = append(tmpData, dataConst...)
+		dataConst, err := s.constBytes(consts, name)
+		if err != nil {
+			return nil, err
+		}
+		tmpData = append(tmpData, dataConst...)
+	}
+
+	return s.Crc.Calc(s.Order(), tmpData), nil
+}
+
+// CheckCrc - проверяет контрольную сумму фрейма согласно формату action.
+// Если crc# не входит в формат проверка всегда проходит
+func (s *CustomSlave) CheckCrc(action string, adu []byte) (bool, error) {
+	report, err := s.ReportCrc(action, adu)
+	if err != nil {
+		return false, err
+	}
+	return report == nil || report.Pass, nil
+}
+
+// ReportCrc - находит поле crc# в adu согласно формату action, удаляет staffing
+// и сравнивает его с контрольной суммой, подсчитанной по полям crc.
+// Возвращает nil если crc# не входит в формат
+func (s *CustomSlave) ReportCrc(action string, adu []byte) (*common.ReportExpected, error) {
+	if s.Crc == nil {
+		return nil, nil
+	}
+	clean, err := s.StaffingProcessing(false, adu)
+	if err != nil {
+		return nil, err
+	}
+	format, err := s.getFormat(action)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := 0
+	suffix := 0
+	header := true
+	// Положение crc от начала или от конца пакета
+	crcIndex := -1
+	crcHeader := true
+	for _, templ := range format {
+		size := 0
+		switch {
+		case strings.HasPrefix(templ, "data#"):
+			header = false
+			continue
+		case strings.HasPrefix(templ, "len#"):
+			if s.Len != nil {
+				size = s.Len.CountBytes
+			}
+		case strings.HasPrefix(templ, "crc#"):
+			crcHeader = header
+			if header {
+				crcIndex = prefix
+			} else {
+				crcIndex = suffix
+			}
+			size = s.Crc.Len()
+		default:
+			data, err := s.constBytes(s.Const, templ)
+			if err != nil {
+				return nil, err
 			}
+			size = len(data)
+		}
+		if header {
+			prefix += size
 		} else {
-			logrus.Fatalf("Constant not found %s", constanta)
+			suffix += size
 		}
 	}
 
-	// определяем порядок байт
-	var order binary.ByteOrder = binary.BigEndian
-	if s.ByteOrder == "little" {
-		order = binary.LittleEndian
+	if crcIndex < 0 {
+		return nil, nil
+	}
+	if !crcHeader {
+		crcIndex += len(clean) - suffix
 	}
 
-	return s.Crc.Calc(order, tmpData)
-}
+	report := &common.ReportExpected{Name: "crc#", Type: common.Byte.String(), Pass: true}
 
-func (s *CustomSlave) CheckCrc(action string, adu []byte) bool {
-	// TODO
-	return true
+	data, err := s.ParseData(action, adu)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := s.CalcCrc(action, data)
+	if err != nil {
+		return nil, err
+	}
+	report.Expected = fmt.Sprintf("% x", expected)
+	report.ExpectedHex = fmt.Sprintf("%02x", expected)
+	report.ExpectedBin = fmt.Sprintf("%08b", expected)
+
+	if len(clean) < prefix+suffix || crcIndex < 0 || len(clean) < crcIndex+len(expected) {
+		report.Pass = false
+		return report, nil
+	}
+
+	got := clean[crcIndex : crcIndex+len(expected)]
+	report.Got = fmt.Sprintf("% x", got)
+	report.GotHex = fmt.Sprintf("%02x", got)
+	report.GotBin = fmt.Sprintf("%08b", got)
+	report.Pass = bytes.Equal(got, expected)
+	return report, nil
 }
 
 // CalcLen - Подсчитывает длину согласно шаблону
 // action - read, write, error
 // data - Длина в byte
-func (s *CustomSlave) CalcLen(action string, data []byte) (int, []byte) {
+func (s *CustomSlave) CalcLen(action string, data []byte) (int, []byte, error) {
 	if s.Len == nil {
-		logrus.Fatal("Length is not specified in the configuration")
+		return 0, nil, common.NewConfigError("length is not specified in the configuration")
 	}
 
 	countByte := 0
@@ -229,7 +513,7 @@ func (s *CustomSlave) CalcLen(action string, data []byte) (int, []byte) {
 	case ActionError:
 		format = s.Len.Error
 	default:
-		logrus.Fatalf("Action not found %s", action)
+		return 0, nil, fmt.Errorf("action not found %s", action)
 	}
 
 	for _, name := range format {
@@ -237,7 +521,11 @@ func (s *CustomSlave) CalcLen(action string, data []byte) (int, []byte) {
 			// Подсчитываем шаблоны
 			if strings.HasPrefix(name, "data#") {
 				if s.Len.CountStaffing {
-					countByte += len(s.StaffingProcessing(true, data))
+					staffed, err := s.StaffingProcessing(true, data)
+					if err != nil {
+						return 0, nil, err
+					}
+					countByte += len(staffed)
 				} else {
 					countByte += len(data)
 				}
@@ -245,24 +533,15 @@ func (s *CustomSlave) CalcLen(action string, data []byte) (int, []byte) {
 			continue
 		}
 
-		if constanta, ok := s.Const[name]; ok {
-			for _, stringBytes := range constanta {
-				dataConst, err := common.ParseStringByte(stringBytes)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				countByte += len(dataConst)
-			}
-		} else {
-			logrus.Fatalf("Constant not found %s", constanta)
+		dataConst, err := s.constBytes(s.Const, name)
+		if err != nil {
+			return 0, nil, err
 		}
+		countByte += len(dataConst)
 	}
 
 	// определяем порядок байт
-	var order binary.ByteOrder = binary.BigEndian
-	if s.ByteOrder == "little" {
-		order = binary.LittleEndian
-	}
+	order := s.Order()
 
 	b := make([]byte, s.Len.CountBytes)
 
@@ -276,76 +555,143 @@ func (s *CustomSlave) CalcLen(action string, data []byte) (int, []byte) {
 	case 8:
 		order.PutUint64(b, uint64(countByte))
 	default:
-		logrus.Fatalf("error countByte to len %d", s.Len.CountBytes)
+		return 0, nil, common.NewConfigError("error countByte to len %d", s.Len.CountBytes)
 	}
 
-	return countByte, b
+	return countByte, b, nil
 }
 
 // data - чистая без стаффинг байтов
 // TODO тесты
-func (s *CustomSlave) GenerateAnswer(action string, data []byte) (out []byte) {
-	var format []string
-	switch action {
-	case ActionRead:
-		format = s.ReadFormat
-	case ActionWrite:
-		format = s.WriteFormat
-	case ActionError:
-		format = s.ErrorFormat
-	default:
-		logrus.Fatalf("Action not found %s", action)
+func (s *CustomSlave) GenerateAnswer(action string, data []byte) ([]byte, error) {
+	return s.generateAnswer(action, data, s.Const, false)
+}
+
+// generateAnswer - собирает ответ с константами consts, при corruptCrc с инвертированной контрольной суммой
+func (s *CustomSlave) generateAnswer(action string, data []byte, consts map[string][]string, corruptCrc bool) ([]byte, error) {
+	format, err := s.getFormat(action)
+	if err != nil {
+		return nil, err
 	}
+	var out []byte
 	for _, templ := range format {
 		if strings.Contains(templ, "#") {
 			if strings.HasPrefix(templ, "len#") {
-				_, l := s.CalcLen(action, data)
+				_, l, err := s.CalcLen(action, data)
+				if err != nil {
+					return nil, err
+				}
 				if s.Len.Staffing {
-					out = append(out, s.StaffingProcessing(true, l)...)
-				} else {
-					out = append(out, l...)
+					if l, err = s.StaffingProcessing(true, l); err != nil {
+						return nil, err
+					}
 				}
+				out = append(out, l...)
 				continue
 			}
 
 			// ======== Собирается суфикс ============
 			if strings.HasPrefix(templ, "data#") {
-				out = append(out, s.StaffingProcessing(true, data)...)
+				staffed, err := s.StaffingProcessing(true, data)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, staffed...)
 				continue
 			}
 
 			if strings.HasPrefix(templ, "crc#") {
-				out = append(out, s.StaffingProcessing(true, s.CalcCrc(action, data))...)
+				crc, err := s.calcCrc(action, data, consts)
+				if err != nil {
+					return nil, err
+				}
+				if corruptCrc {
+					for i := range crc {
+						crc[i] ^= 0xff
+					}
+				}
+				crc, err = s.StaffingProcessing(true, crc)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, crc...)
 				continue
 			}
 		}
 
 		// Ищем стартовые байты в константах
-		if constanta, ok := s.Const[templ]; ok {
-			for _, stringBytes := range constanta {
-				data, err := common.ParseStringByte(stringBytes)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				out = append(out, data...)
-			}
-		} else {
-			logrus.Fatalf("Constant not found %s", constanta)
+		dataConst, err := s.constBytes(consts, templ)
+		if err != nil {
+			return nil, err
 		}
+		out = append(out, dataConst...)
 	}
-	return
+	return out, nil
+}
+
+// answer - собирает ответ action с ошибками плана. Ошибки wrongId и corruptCrc вносятся при сборке,
+// остальные в собранный кадр. Возвращает кадры для отправки и задержку ответа
+func (s *CustomSlave) answer(action string, data []byte, plan fault.Plan) ([][]byte, time.Duration, error) {
+	consts := s.Const
+	if f := plan.Get(fault.WrongId); f != nil {
+		var err error
+		if consts, err = s.wrongId(f); err != nil {
+			return nil, 0, err
+		}
+	}
+	out, err := s.generateAnswer(action, data, consts, plan.Get(fault.CorruptCrc) != nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	frames, delay := plan.Apply(out)
+	return frames, delay, nil
+}
+
+// wrongId - копия констант с адресом другого устройства. Меняется последний байт константы f.Const
+func (s *CustomSlave) wrongId(f *fault.Fault) (map[string][]string, error) {
+	consts := make(map[string][]string, len(s.Const))
+	for name, value := range s.Const {
+		consts[name] = value
+	}
+	address := append([]string{}, consts[f.Const]...)
+	if len(address) == 0 {
+		return consts, nil
+	}
+	last, err := common.ParseStringByte(address[len(address)-1])
+	if err != nil || len(last) == 0 {
+		return nil, common.NewConfigError("parse constant %s: %v", f.Const, err)
+	}
+	last[len(last)-1] = f.WrongSlaveId(last[len(last)-1])
+	address[len(address)-1] = fmt.Sprintf("%x", last)
+	consts[f.Const] = address
+	return consts, nil
 }
 
 // Возвращает чистую дату без staffing
 // TODO тесты
-func (s *CustomSlave) ParseReadData(adu []byte) []byte {
-	adu = s.StaffingProcessing(false, adu)
+func (s *CustomSlave) ParseReadData(adu []byte) ([]byte, error) {
+	return s.ParseData(ActionRead, adu)
+}
+
+// ParseData - возвращает чистую дату без staffing согласно формату action
+func (s *CustomSlave) ParseData(action string, adu []byte) ([]byte, error) {
+	adu, err := s.StaffingProcessing(false, adu)
+	if err != nil {
+		return nil, err
+	}
+	format, err := s.getFormat(action)
+	if err != nil {
+		return nil, err
+	}
 	prefix := 0
 	suffix := 0
 	header := true
-	for _, templ := range s.ReadFormat {
+	for _, templ := range format {
 		if strings.Contains(templ, "#") {
 			if strings.HasPrefix(templ, "len#") {
+				if s.Len == nil {
+					return nil, common.NewConfigError("length is not specified in the configuration")
+				}
 				if header {
 					prefix += s.Len.CountBytes
 				} else {
@@ -361,6 +707,9 @@ func (s *CustomSlave) ParseReadData(adu []byte) []byte {
 			}
 
 			if strings.HasPrefix(templ, "crc#") {
+				if s.Crc == nil {
+					return nil, common.NewConfigError("crc is not specified in the configuration")
+				}
 				if header {
 					prefix += s.Crc.Len()
 				} else {
@@ -370,41 +719,44 @@ func (s *CustomSlave) ParseReadData(adu []byte) []byte {
 			}
 		}
 		// Ищем стартовые байты в константах
-		if constanta, ok := s.Const[templ]; ok {
-			for _, stringBytes := range constanta {
-				data, err := common.ParseStringByte(stringBytes)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				// считаем количество байт в начале или конце пакета
-				if header {
-					prefix += len(data)
-				} else {
-					suffix += len(data)
-				}
-			}
+		data, err := s.constBytes(s.Const, templ)
+		if err != nil {
+			return nil, err
+		}
+		// считаем количество байт в начале или конце пакета
+		if header {
+			prefix += len(data)
 		} else {
-			logrus.Fatalf("Constant not found %s", constanta)
+			suffix += len(data)
 		}
 	}
 
-	if len(adu) == 0 || (len(adu)-suffix) < 0 {
-		return []byte{}
+	if len(adu) == 0 || (len(adu)-suffix) < prefix {
+		return []byte{}, nil
 	}
 	if suffix == 0 {
-		return adu[prefix:]
+		return adu[prefix:], nil
 	}
-	return adu[prefix:(len(adu) - suffix)]
+	return adu[prefix:(len(adu) - suffix)], nil
 }
 
 // ParseReadFormat создает сплиттер для поиска фреймов в потоке данных rs
-func (s *CustomSlave) ParseReadFormat() (start []byte, lenPosition int, suffix []string, end []byte) {
+func (s *CustomSlave) ParseReadFormat() (start []byte, lenPosition int, suffix []string, end []byte, err error) {
+	return s.ParseFormat(ActionRead)
+}
+
+// ParseFormat - разбирает формат action (read, write, error) на стартовые байты, позицию длины, суффикс и конечные байты
+func (s *CustomSlave) ParseFormat(action string) (start []byte, lenPosition int, suffix []string, end []byte, err error) {
+	format, err := s.getFormat(action)
+	if err != nil {
+		return nil, 0, nil, nil, err
+	}
 	prefixLen := 0
 	// позволяет собирать стартовые байты
 	findStart := true
-	// Если суффикс сработал перед len то фаталка
+	// Если суффикс сработал перед len то ошибка
 	suffixTrigger := false
-	for _, templ := range s.ReadFormat {
+	for _, templ := range format {
 		// Если нет специальной вставки то определяем всю строку как стартовые байты
 		if strings.Contains(templ, "#") {
 			// =======  Собирается хедер с фиксированной длиной  ===========
@@ -415,10 +767,10 @@ func (s *CustomSlave) ParseReadFormat() (start []byte, lenPosition int, suffix [
 			// #len должен быть первым после констант или типов с фиксированной длиной
 			if strings.HasPrefix(templ, "len#") {
 				if suffixTrigger {
-					logrus.Fatal("the suffix was used before len")
+					return nil, 0, nil, nil, common.NewConfigError("the suffix was used before len")
 				}
 				if s.Len == nil {
-					logrus.Fatal("Data len not found in config")
+					return nil, 0, nil, nil, common.NewConfigError("data len not found in config")
 				}
 				lenPosition += len(start) + prefixLen
 			}
@@ -426,14 +778,14 @@ func (s *CustomSlave) ParseReadFormat() (start []byte, lenPosition int, suffix [
 			// ======== Собирается суфикс ============
 			if strings.HasPrefix(templ, "data#") {
 				suffixTrigger = true
-				if s.Len != nil && !s.Len.Contains(ActionRead, "data#") {
+				if s.Len != nil && !s.Len.Contains(s.lenAction(action), "data#") {
 					suffix = append(suffix, "data#")
 				}
 			}
 
 			if strings.HasPrefix(templ, "crc#") {
 				suffixTrigger = true
-				if s.Len != nil && !s.Len.Contains(ActionRead, "crc#") {
+				if s.Len != nil && !s.Len.Contains(s.lenAction(action), "crc#") {
 					suffix = append(suffix, "crc#")
 				}
 			}
@@ -447,63 +799,50 @@ func (s *CustomSlave) ParseReadFormat() (start []byte, lenPosition int, suffix [
 			continue
 		}
 		// Ищем стартовые байты в константах
-		if constanta, ok := s.Const[templ]; ok {
-			for _, stringBytes := range constanta {
-				data, err := common.ParseStringByte(stringBytes)
-				if err != nil {
-					logrus.Fatal(err)
-				}
-				if findStart {
-					start = append(start, data...)
-				} else {
-					end = append(end, data...)
-				}
-			}
+		data, err := s.constBytes(s.Const, templ)
+		if err != nil {
+			return nil, 0, nil, nil, err
+		}
+		if findStart {
+			start = append(start, data...)
 		} else {
-			logrus.Fatalf("Constant not found %s", constanta)
+			end = append(end, data...)
 		}
-
 	}
 
 	// Если не надйен шаблон начала пакета
 	if len(start) == 0 {
-		logrus.Fatal("Start byte not found")
+		return nil, 0, nil, nil, common.NewConfigError("start byte not found")
 	}
 	// Если не найден шаблон конца пакета или хотябы длина
 	if lenPosition == 0 && len(end) == 0 {
-		logrus.Fatal("end byte or len not found")
+		return nil, 0, nil, nil, common.NewConfigError("end byte or len not found")
 	}
 	return
 }
 
 // StaffingProcessing - Добавляет staffing byte к data
 // TODO тесты
-func (s *CustomSlave) StaffingProcessing(isInsert bool, data []byte) []byte {
+func (s *CustomSlave) StaffingProcessing(isInsert bool, data []byte) ([]byte, error) {
 
 	if s.Staffing == nil || len(s.Staffing.Byte) == 0 || len(s.Staffing.Pattern) == 0 {
-		return data
+		return data, nil
 	}
 
 	staffingByte, err := common.ParseStringByte(s.Staffing.Byte)
 	if err != nil || len(staffingByte) == 0 {
-		logrus.Fatalf("StaffingProcessing byte error %s", err)
+		return nil, common.NewConfigError("staffing byte error %v", err)
 	}
 
 	// Собираем шаблоны которые надо экранировать
 	staffingPatterns := make(map[byte]struct{})
 	for _, name := range s.Staffing.Pattern {
-		if constanta, ok := s.Const[name]; ok {
-			for _, stringBytes := range constanta {
-				dataConst, err := common.ParseStringByte(stringBytes)
-				if err != nil {
-					logrus.Fatalf("StaffingProcessing parse const %s", err)
-				}
-				for _, c := range dataConst {
-					staffingPatterns[c] = struct{}{}
-				}
-			}
-		} else {
-			logrus.Fatalf("StaffingProcessing Constant not found %s", constanta)
+		dataConst, err := s.constBytes(s.Const, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range dataConst {
+			staffingPatterns[c] = struct{}{}
 		}
 	}
 
@@ -517,7 +856,7 @@ func (s *CustomSlave) StaffingProcessing(isInsert bool, data []byte) []byte {
 			out = bytes.ReplaceAll(out, append(b, staffingByte...), b)
 		}
 	}
-	return out
+	return out, nil
 }
 
 //func (rt *RtuTransport) SilentInterval() (frameDelay time.Duration) {
@@ -534,6 +873,10 @@ func (s *CustomSlave) StaffingProcessing(isInsert bool, data []byte) []byte {
 // GetSplitLen - parses packets with a fixed length
 // TODO Отладка
 func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string) bufio.SplitFunc {
+	return s.getSplitLen(ActionRead, start, lenPosition, suffix)
+}
+
+func (s *CustomSlave) getSplitLen(action string, start []byte, lenPosition int, suffix []string) bufio.SplitFunc {
 	return func(data []byte, atEOF bool) (int, []byte, error) {
 		lenLen := 1
 		if s.Len != nil && s.Len.CountBytes != 0 {
@@ -548,12 +891,16 @@ func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string
 		// Поиск стартовый байтов пакетов
 		startIndex := bytes.Index(data, start)
 		if startIndex < 0 {
+			n := len(data) - len(start)
+			if n < 0 {
+				return 0, nil, err
+			}
 			// Если начало пакета не найдено
-			return len(data) - len(start), nil, err
+			return n, nil, err
 		}
 
 		// offset
-		lenPosition += startIndex
+		lenPosition := lenPosition + startIndex
 
 		tail := lenPosition + lenLen
 		// Waiting for the position length and size
@@ -561,10 +908,24 @@ func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string
 			return 0, nil, err
 		}
 
+		// Длина без staffing байтов
+		lenData := data[lenPosition : lenPosition+lenLen]
 		// Учитываем Staffing байт в длине
 		lenCountStaffing := 0
 		if s.Len != nil && s.Len.Staffing {
-			lenCountStaffing = lenLen - len(s.StaffingProcessing(false, data[lenPosition:lenPosition+lenLen]))
+			clean, staffingErr := s.StaffingProcessing(false, data[lenPosition:])
+			if staffingErr != nil {
+				return 0, nil, staffingErr
+			}
+			if len(clean) < lenLen {
+				return 0, nil, err
+			}
+			lenData = clean[:lenLen]
+			cleanLen, staffingErr := s.StaffingProcessing(false, data[lenPosition:lenPosition+lenLen])
+			if staffingErr != nil {
+				return 0, nil, staffingErr
+			}
+			lenCountStaffing = lenLen - len(cleanLen)
 		}
 		tail += lenCountStaffing
 		if len(data) < tail {
@@ -572,40 +933,32 @@ func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string
 		}
 
 		// Defining the byte order
-		var order binary.ByteOrder = binary.BigEndian
-		if s.ByteOrder == "little" {
-			order = binary.LittleEndian
-		}
+		order := s.Order()
 
 		// Парсим длину пакета
 		lengthData := 0
 		switch lenLen {
 		case 2:
-			if s.Len != nil && s.Len.Staffing {
-				lengthData = int(order.Uint16(s.StaffingProcessing(false, data[lenPosition:])[:lenLen]))
-			} else {
-				lengthData = int(order.Uint16(data[lenPosition : lenPosition+lenLen]))
-			}
+			lengthData = int(order.Uint16(lenData))
 		case 4:
-			if s.Len != nil && s.Len.Staffing {
-				lengthData = int(order.Uint32(s.StaffingProcessing(false, data[lenPosition:])[:lenLen]))
-			} else {
-				lengthData = int(order.Uint32(data[lenPosition : lenPosition+lenLen]))
-			}
+			lengthData = int(order.Uint32(lenData))
 		case 8:
-			if s.Len != nil && s.Len.Staffing {
-				lengthData = int(order.Uint64(s.StaffingProcessing(false, data[lenPosition:])[:lenLen]))
-			} else {
-				lengthData = int(order.Uint64(data[lenPosition : lenPosition+lenLen]))
-			}
+			lengthData = int(order.Uint64(lenData))
 		default:
-			lengthData = int(uint8(data[lenPosition]))
+			lengthData = int(uint8(lenData[0]))
 		}
 
 		// Учитываем стаффинг байт в данных
 		dataCountStaffing := 0
 		if s.Len != nil && s.Len.CountStaffing {
-			dataCountStaffing = lengthData - len(s.StaffingProcessing(false, data[lenPosition+lenLen+lenCountStaffing:lenPosition+lenLen+lenCountStaffing+lengthData]))
+			if len(data) < tail+lengthData {
+				return 0, nil, err
+			}
+			clean, staffingErr := s.StaffingProcessing(false, data[tail:tail+lengthData])
+			if staffingErr != nil {
+				return 0, nil, staffingErr
+			}
+			dataCountStaffing = lengthData - len(clean)
 		}
 		tail += lengthData + dataCountStaffing
 		if len(data) < tail {
@@ -616,24 +969,26 @@ func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string
 		for _, suff := range suffix {
 			switch suff {
 			case "crc#":
+				if s.Crc == nil {
+					return 0, nil, common.NewConfigError("crc is not specified in the configuration")
+				}
 				lenCrc := s.Crc.Len()
+				crcPosition := tail
 				tail += lenCrc
 				if len(data) < tail {
 					return 0, nil, err
 				}
-				tail += lenCrc - len(s.StaffingProcessing(false, data[lenPosition+lenLen+lenCountStaffing+lengthData+dataCountStaffing:lenPosition+lenLen+lenCountStaffing+lengthData+dataCountStaffing+lenCrc]))
+				clean, staffingErr := s.StaffingProcessing(false, data[crcPosition:tail])
+				if staffingErr != nil {
+					return 0, nil, staffingErr
+				}
+				tail += lenCrc - len(clean)
 			default:
-				if constanta, ok := s.Const[suff]; ok {
-					for _, stringBytes := range constanta {
-						dataConst, err := common.ParseStringByte(stringBytes)
-						if err != nil {
-							logrus.Fatalf("StaffingProcessing parse const %s", err)
-						}
-						tail += len(dataConst)
-					}
-				} else {
-					logrus.Fatalf("StaffingProcessing Constant not found %s", constanta)
+				dataConst, constErr := s.constBytes(s.Const, suff)
+				if constErr != nil {
+					return 0, nil, constErr
 				}
+				tail += len(dataConst)
 			}
 		}
 
@@ -645,6 +1000,17 @@ func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string
 			}
 			return 0, nil, err
 		} else {
+			// Если crc не прошел проверку
+			if s.dropCrc() {
+				pass, crcErr := s.CheckCrc(action, data[startIndex:tail])
+				if crcErr != nil {
+					return 0, nil, crcErr
+				}
+				if !pass {
+					s.logger().Debugf("Crc fail. Drop the trash: % 02x", data[:startIndex+len(start)])
+					return startIndex + len(start), nil, err
+				}
+			}
 			return tail, data[startIndex:tail], err
 		}
 	}
@@ -652,6 +1018,10 @@ func (s *CustomSlave) GetSplitLen(start []byte, lenPosition int, suffix []string
 
 // Сплиттер пакета по стартовым и конечным байтам
 func (s *CustomSlave) GetSplitStartEnd(start []byte, end []byte) bufio.SplitFunc {
+	return s.getSplitStartEnd(ActionRead, start, end)
+}
+
+func (s *CustomSlave) getSplitStartEnd(action string, start []byte, end []byte) bufio.SplitFunc {
 	return func(data []byte, atEOF bool) (int, []byte, error) {
 		// Если отсутствуют данные для чтения
 		var err error
@@ -662,39 +1032,45 @@ func (s *CustomSlave) GetSplitStartEnd(start []byte, end []byte) bufio.SplitFunc
 		// Поиск стартовый байтов пакетов
 		startIndex := bytes.Index(data, start)
 		if startIndex < 0 {
-			s := len(data) - len(start)
-			if s < 0 {
+			n := len(data) - len(start)
+			if n < 0 {
 				return 0, nil, err
 			}
-			logrus.Debugf("Search for start. Drop the trash: % 02x", data[:len(data)-len(start)])
+			s.logger().Debugf("Search for start. Drop the trash: % 02x", data[:len(data)-len(start)])
 			// Если начало пакета не найдено
 			return len(data) - len(start), nil, err
 		}
 
 		// Поиск финальных байтов пакета
-		endIndex := bytes.Index(data[len(start):], end)
+		endIndex := bytes.Index(data[startIndex+len(start):], end)
 		if endIndex < 0 {
 			// Если данные превысили верхнюю планку пакета
 			if len(data) > s.MaxLen {
-				logrus.Debugf("Buffer is full. Drop the trash: % 02x", data[:len(start)])
+				s.logger().Debugf("Buffer is full. Drop the trash: % 02x", data[:len(start)])
 				return len(start), nil, err
 			}
 			// Ждем конца пакета
 			return 0, nil, err
 		} else {
-			tail := (len(start) + endIndex) + len(end)
+			tail := (startIndex + len(start) + endIndex) + len(end)
 			// Отбрасываем мусор перед стартовыми байтами
 			if startIndex != 0 {
-				logrus.Debugf("Drop the trash: % 02x", data[:startIndex])
+				s.logger().Debugf("Drop the trash: % 02x", data[:startIndex])
 			}
 
 			// Если crc не прошел проверку
-			if !s.CheckCrc(ActionRead, data[startIndex:tail]) {
-				logrus.Debugf("Crc fail. Drop the trash: % 02x", data[:len(start)])
-				return len(start), nil, err
+			if s.dropCrc() {
+				pass, crcErr := s.CheckCrc(action, data[startIndex:tail])
+				if crcErr != nil {
+					return 0, nil, crcErr
+				}
+				if !pass {
+					s.logger().Debugf("Crc fail. Drop the trash: % 02x", data[:startIndex+len(start)])
+					return startIndex + len(start), nil, err
+				}
 			}
 
-			logrus.Debugf("Found a new package: % 02x", data[startIndex:tail])
+			s.logger().Debugf("Found a new package: % 02x", data[startIndex:tail])
 			return tail, data[startIndex:tail], err
 		}
 	}
@@ -3,6 +3,7 @@ package slave
 import (
 	"github.com/stretchr/testify/suite"
 	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/fault"
 	"testing"
 )
 
@@ -34,7 +35,9 @@ func (s *CustomSlaveTestSuit) TestParseReadFormat() {
 		},
 	}
 
-	start, lenPosition, suffix, end := v.ParseReadFormat()
+	start, lenPosition, suffix, end, err := v.ParseReadFormat()
+
+	s.NoError(err)
 
 	s.Equal([]byte{0x01, 0x02}, start)
 	s.Equal(2, lenPosition)
@@ -117,8 +120,12 @@ func (s *CustomSlaveTestSuit) TestStaffingProcessing() {
 		},
 	}
 
-	s.Equal([]byte{0x1, 0xcf, 0x0, 0x2, 0xbf, 0x0, 0x3, 0xff, 0x0, 0x4, 0xef, 0x0, 0x5}, v.StaffingProcessing(true, []byte{0x01, 0xcf, 0x02, 0xbf, 0x03, 0xff, 0x04, 0xef, 0x05}))
-	s.Equal([]byte{0x01, 0xcf, 0x02, 0xbf, 0x03, 0xff, 0x04, 0xef, 0x05}, v.StaffingProcessing(false, []byte{0x1, 0xcf, 0x0, 0x2, 0xbf, 0x0, 0x3, 0xff, 0x0, 0x4, 0xef, 0x0, 0x5}))
+	b, err := v.StaffingProcessing(true, []byte{0x01, 0xcf, 0x02, 0xbf, 0x03, 0xff, 0x04, 0xef, 0x05})
+	s.NoError(err)
+	s.Equal([]byte{0x1, 0xcf, 0x0, 0x2, 0xbf, 0x0, 0x3, 0xff, 0x0, 0x4, 0xef, 0x0, 0x5}, b)
+	b, err = v.StaffingProcessing(false, []byte{0x1, 0xcf, 0x0, 0x2, 0xbf, 0x0, 0x3, 0xff, 0x0, 0x4, 0xef, 0x0, 0x5})
+	s.NoError(err)
+	s.Equal([]byte{0x01, 0xcf, 0x02, 0xbf, 0x03, 0xff, 0x04, 0xef, 0x05}, b)
 }
 
 func (s *CustomSlaveTestSuit) TestCalcLen() {
@@ -142,20 +149,24 @@ func (s *CustomSlaveTestSuit) TestCalcLen() {
 		},
 	}
 
-	count, b := v.CalcLen(ActionRead, []byte{1, 2, 3, 4})
+	count, b, err := v.CalcLen(ActionRead, []byte{1, 2, 3, 4})
+	s.NoError(err)
 	s.Equal(6, count)
 	s.Equal([]byte{0x0, 0x6}, b)
 
 	// с добавлением стаффинга
-	count, b = v.CalcLen(ActionRead, []byte{1, 2, 3, 0xef, 4})
+	count, b, err = v.CalcLen(ActionRead, []byte{1, 2, 3, 0xef, 4})
+	s.NoError(err)
 	s.Equal(8, count)
 	s.Equal([]byte{0x0, 0x8}, b)
 
-	count, b = v.CalcLen(ActionWrite, []byte{1, 2, 3, 4})
+	count, b, err = v.CalcLen(ActionWrite, []byte{1, 2, 3, 4})
+	s.NoError(err)
 	s.Equal(4, count)
 	s.Equal([]byte{0x0, 0x4}, b)
 
-	count, b = v.CalcLen(ActionError, []byte{1, 2, 3, 4})
+	count, b, err = v.CalcLen(ActionError, []byte{1, 2, 3, 4})
+	s.NoError(err)
 	s.Equal(2, count)
 	s.Equal([]byte{0x0, 0x2}, b)
 }
@@ -190,17 +201,21 @@ func (s *CustomSlaveTestSuit) TestCalcCrc() {
 		},
 	}
 
-	b := v.CalcCrc(ActionRead, []byte{1, 2, 3, 4})
+	b, err := v.CalcCrc(ActionRead, []byte{1, 2, 3, 4})
+	s.NoError(err)
 	s.Equal([]byte{0xf8}, b)
 
 	// с добавлением стаффинга
-	b = v.CalcCrc(ActionRead, []byte{1, 2, 3, 0xef, 4})
+	b, err = v.CalcCrc(ActionRead, []byte{1, 2, 3, 0xef, 4})
+	s.NoError(err)
 	s.Equal([]byte{0xe7}, b)
 
-	b = v.CalcCrc(ActionWrite, []byte{1, 2, 3, 4})
+	b, err = v.CalcCrc(ActionWrite, []byte{1, 2, 3, 4})
+	s.NoError(err)
 	s.Equal([]byte{0x0a}, b)
 
-	b = v.CalcCrc(ActionError, []byte{1, 2, 3, 4})
+	b, err = v.CalcCrc(ActionError, []byte{1, 2, 3, 4})
+	s.NoError(err)
 	s.Equal([]byte{0xee}, b)
 
 	v = CustomSlave{
@@ -227,7 +242,8 @@ func (s *CustomSlaveTestSuit) TestCalcCrc() {
 		},
 	}
 
-	b = v.CalcCrc(ActionWrite, []byte{0x04, 0x09, 0x00, 0x00, 0x21, 0x00, 0x00})
+	b, err = v.CalcCrc(ActionWrite, []byte{0x04, 0x09, 0x00, 0x00, 0x21, 0x00, 0x00})
+	s.NoError(err)
 	s.Equal([]byte{0xc0, 0x40}, b)
 
 	v = CustomSlave{
@@ -255,6 +271,224 @@ func (s *CustomSlaveTestSuit) TestCalcCrc() {
 		},
 	}
 
-	b = v.CalcCrc(ActionWrite, []byte{0x06, 0xE8, 0x03, 0x00, 0x00, 0xC8, 0x42, 0x00, 0x00, 0x34, 0x42})
+	b, err = v.CalcCrc(ActionWrite, []byte{0x06, 0xE8, 0x03, 0x00, 0x00, 0xC8, 0x42, 0x00, 0x00, 0x34, 0x42})
+	s.NoError(err)
 	s.Equal([]byte{0xB8, 0xBB}, b)
 }
+
+func (s *CustomSlaveTestSuit) TestCheckCrc() {
+	v := CustomSlave{
+		ByteOrder: "little",
+		MaxLen:    255,
+		Const: map[string][]string{
+			"start":         {"0xFE", "0xFE"},
+			"addressMaster": {"0x00"},
+			"addressSlave":  {"0x06"},
+			"end":           {"0xFC", "0xFC"},
+		},
+		Staffing: &module.Staffing{
+			Byte:    "0x00",
+			Pattern: []string{"start", "end"},
+		},
+		ReadFormat: []string{"start", "addressSlave", "addressMaster", "data#", "crc#", "end"},
+		Crc: &module.Crc{
+			ByteOrder: "little",
+			Algorithm: "modBus",
+			Read:      []string{"start", "addressSlave", "addressMaster", "data#"},
+		},
+	}
+
+	adu, err := v.GenerateAnswer(ActionRead, []byte{0x04, 0xfe, 0x00, 0x21})
+	s.NoError(err)
+	pass, err := v.CheckCrc(ActionRead, adu)
+	s.NoError(err)
+	s.True(pass)
+
+	report, err := v.ReportCrc(ActionRead, adu)
+	s.NoError(err)
+	s.True(report.Pass)
+	s.Equal(report.Expected, report.Got)
+
+	// Порча данных
+	bad := make([]byte, len(adu))
+	copy(bad, adu)
+	bad[5] = 0x05
+	pass, err = v.CheckCrc(ActionRead, bad)
+	s.NoError(err)
+	s.False(pass)
+	report, err = v.ReportCrc(ActionRead, bad)
+	s.NoError(err)
+	s.False(report.Pass)
+	s.NotEqual(report.Expected, report.Got)
+
+	// По умолчанию фрейм с неверной контрольной суммой отбрасывается
+	split, err := v.GetSplit(ActionRead)
+	s.NoError(err)
+	offset, data, _ := split(bad, false)
+	s.Equal(4, offset)
+	s.Nil(data)
+
+	offset, data, _ = split(append([]byte{0x01}, adu...), false)
+	s.Equal(len(adu)+1, offset)
+	s.Equal(adu, data)
+
+	// Фрейм передается тесту
+	v.CrcError = CrcErrorFail
+	offset, data, _ = split(bad, false)
+	s.Equal(len(bad), offset)
+	s.Equal(bad, data)
+
+	// crc# не входит в формат
+	v.ReadFormat = []string{"start", "data#", "end"}
+	report, err = v.ReportCrc(ActionRead, bad)
+	s.NoError(err)
+	s.Nil(report)
+	pass, err = v.CheckCrc(ActionRead, bad)
+	s.NoError(err)
+	s.True(pass)
+}
+
+func (s *CustomSlaveTestSuit) TestGetSplitLenCrc() {
+	v := CustomSlave{
+		ByteOrder: "big",
+		MaxLen:    255,
+		Len:       &module.LenBytes{CountBytes: 1, Read: []string{"data#"}, Error: []string{"data#"}},
+		Const: map[string][]string{
+			"start": {"0xFE"},
+		},
+		ReadFormat:  []string{"start", "len#", "data#"},
+		ErrorFormat: []string{"start", "len#", "data#", "crc#"},
+		Crc: &module.Crc{
+			Algorithm: module.Mod256,
+			Error:     []string{"start", "len#", "data#"},
+		},
+	}
+
+	adu, err := v.GenerateAnswer(ActionError, []byte{0x01, 0x02})
+	s.NoError(err)
+	split, err := v.GetSplit(ActionError)
+	s.NoError(err)
+	offset, data, _ := split(adu, false)
+	s.Equal(len(adu), offset)
+	s.Equal(adu, data)
+
+	// Контрольная сумма проверяется по формату ошибки, а не чтения
+	bad := make([]byte, len(adu))
+	copy(bad, adu)
+	bad[len(bad)-1]++
+	offset, data, _ = split(bad, false)
+	s.Equal(1, offset)
+	s.Nil(data)
+}
+
+func (s *CustomSlaveTestSuit) TestGetSplitLenShort() {
+	v := CustomSlave{
+		ByteOrder: "big",
+		MaxLen:    255,
+		Len:       &module.LenBytes{CountBytes: 1, Read: []string{"data#"}},
+		Const: map[string][]string{
+			"start": {"0xFE", "0xFE"},
+		},
+		ReadFormat: []string{"start", "len#", "data#"},
+	}
+
+	split, err := v.GetSplit(ActionRead)
+	s.NoError(err)
+	// Буфер короче стартовых байт
+	offset, data, err := split([]byte{0x01}, false)
+	s.Equal(0, offset)
+	s.Nil(data)
+	s.NoError(err)
+
+	// Ошибка конфигурации возвращается сплиттером
+	v.Const = map[string][]string{"start": {"0xFE", "0xFE"}, "end": {"0xFC"}}
+	split = v.GetSplitLen([]byte{0xFE, 0xFE}, 2, []string{"stop"})
+	_, _, err = split([]byte{0xFE, 0xFE, 0x01, 0x0A, 0xFC}, false)
+	s.EqualError(err, "constant not found stop")
+}
+
+func (s *CustomSlaveTestSuit) TestValidateFrame() {
+	v := CustomSlave{
+		Const: map[string][]string{
+			"start": {"0xFE"},
+			"end":   {"0xZZ"},
+		},
+		Len:         &module.LenBytes{CountBytes: 3},
+		Staffing:    &module.Staffing{Byte: "byte"},
+		ReadFormat:  []string{"start", "len#", "data#", "crc#"},
+		WriteFormat: []string{"start", "len#", "data#", "end"},
+	}
+
+	var messages []string
+	for _, err := range v.ValidateFrame() {
+		messages = append(messages, err.Error())
+	}
+	s.Equal([]string{
+		"const.end.0: strconv.ParseUint: parsing \"ZZ\": invalid syntax",
+		"staffing.byte: invalid byte \"byte\"",
+		"len.coundBytes: must be 1, 2, 4 or 8",
+		"readFormat.3: crc is not specified",
+	}, messages)
+
+	// Формат без стартовых байт
+	v = CustomSlave{
+		Const:      map[string][]string{"end": {"0xFC"}},
+		ReadFormat: []string{"data#", "end"},
+	}
+	messages = nil
+	for _, err := range v.ValidateFrame() {
+		messages = append(messages, err.Error())
+	}
+	s.Equal([]string{"readFormat: start byte not found"}, messages)
+}
+
+func (s *CustomSlaveTestSuit) TestValidateFormat() {
+	v := CustomSlave{
+		Const: map[string][]string{
+			"start": {"0xFE"},
+		},
+		ReadFormat:  []string{"start", "len#", "data#payload", "crc#modbus"},
+		WriteFormat: []string{"start", "data#", "id#", "stop"},
+		Staffing:    &module.Staffing{Byte: "0x00", Pattern: []string{"start", "data#"}},
+	}
+
+	errs := v.ValidateFormat()
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	s.Equal([]string{
+		"writeFormat.2: unknown field id#",
+		"writeFormat.3: undefined constant stop",
+		"staffing.pattern.1: unknown field data#",
+	}, messages)
+}
+
+func (s *CustomSlaveTestSuit) TestAnswerFaults() {
+	v := CustomSlave{
+		ByteOrder: "big",
+		Const: map[string][]string{
+			"start":   {"0xFE"},
+			"address": {"0x06"},
+		},
+		Crc: &module.Crc{
+			Algorithm: "mod256",
+			Write:     []string{"address", "data#"},
+		},
+		WriteFormat: []string{"start", "address", "data#", "crc#"},
+	}
+
+	frames, _, err := v.answer(ActionWrite, []byte{0x01}, nil)
+	s.NoError(err)
+	s.Equal([][]byte{{0xFE, 0x06, 0x01, 0x07}}, frames)
+
+	// Адрес другого устройства с пересчитанной контрольной суммой
+	frames, _, err = v.answer(ActionWrite, []byte{0x01}, fault.Plan{{Type: fault.WrongId, Const: "address"}})
+	s.NoError(err)
+	s.Equal([][]byte{{0xFE, 0x07, 0x01, 0x08}}, frames)
+	s.Equal([]string{"0x06"}, v.Const["address"])
+
+	frames, _, err = v.answer(ActionWrite, []byte{0x01}, fault.Plan{{Type: fault.CorruptCrc}})
+	s.NoError(err)
+	s.Equal([][]byte{{0xFE, 0x06, 0x01, 0xF8}}, frames)
+}
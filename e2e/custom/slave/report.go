@@ -1,6 +1,8 @@
 package slave
 
 import (
+	"encoding/json"
+	"fmt"
 	"rtu-test/e2e/common"
 )
 
@@ -17,4 +19,20 @@ type ReportCustomSlaveTest struct {
 	Expected []common.ReportExpected
 	// сырые полученные данные
 	GotByte []byte
+	// Отчет о проверке контрольной суммы. nil если crc# не входит в формат
+	Crc *common.ReportExpected
+	// Ошибки, внесенные в ответ
+	Faults []string `json:",omitempty"`
+}
+
+// MarshalJSON - сырые данные выводятся в hex виде
+func (r ReportCustomSlaveTest) MarshalJSON() ([]byte, error) {
+	type report ReportCustomSlaveTest
+	return json.Marshal(struct {
+		report
+		GotByte string
+	}{
+		report:  report(r),
+		GotByte: fmt.Sprintf("% x", r.GotByte),
+	})
 }
@@ -0,0 +1,180 @@
+package slave
+
+import (
+	"fmt"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/fault"
+	"sort"
+	"strings"
+)
+
+// Validate - проверка настроек после загрузки конфигурации
+func (s *CustomSlave) Validate() (errs []error) {
+	switch s.CrcError {
+	case "", CrcErrorDrop, CrcErrorWriteError, CrcErrorFail:
+	default:
+		errs = append(errs, common.NewFieldError("crcError", "unknown value %q", s.CrcError))
+	}
+	if err := capture.Validate(s.Capture); err != nil {
+		errs = append(errs, common.NewFieldError("capture", "%s", err))
+	}
+	errs = append(errs, s.ValidateFrame()...)
+	errs = append(errs, s.validateFaults("faults", s.Faults)...)
+
+	names := make(map[string]bool)
+	for _, test := range s.CustomSlaveTest {
+		names[test.Name] = true
+	}
+	for i, test := range s.CustomSlaveTest {
+		errs = append(errs, s.validateFaults(fmt.Sprintf("test.%d.faults", i), test.Faults)...)
+		for j, next := range test.Next {
+			if !names[next] {
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("test.%d.next.%d", i, j), "test %s not found", next))
+			}
+		}
+	}
+	return errs
+}
+
+// validateFaults - wrongId меняет константу с адресом устройства, corruptCrc требует настроек crc
+func (s *CustomSlave) validateFaults(path string, faults []*fault.Fault) (errs []error) {
+	for i, f := range faults {
+		switch f.Type {
+		case fault.WrongId:
+			if _, ok := s.Const[f.Const]; !ok {
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d.const", path, i), "undefined constant %q", f.Const))
+			}
+		case fault.CorruptCrc:
+			if s.Crc == nil {
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d.type", path, i), "crc is not specified"))
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateFormat - все имена в форматах должны быть константами или полями data#, len#, crc#.
+// Как и при разборе фрейма, имя с # относится к полю по началу имени, например data#payload
+func (s *CustomSlave) ValidateFormat() (errs []error) {
+	check := func(path string, names []string, fields bool) {
+		for i, name := range names {
+			if _, ok := s.Const[name]; ok && !strings.Contains(name, "#") {
+				continue
+			}
+			switch {
+			case !strings.Contains(name, "#"):
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d", path, i), "undefined constant %s", name))
+			case !fields || module.Field(name) == "":
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d", path, i), "unknown field %s", name))
+			}
+		}
+	}
+	check("readFormat", s.ReadFormat, true)
+	check("writeFormat", s.WriteFormat, true)
+	check("errorFormat", s.ErrorFormat, true)
+	if s.Staffing != nil {
+		check("staffing.pattern", s.Staffing.Pattern, false)
+	}
+	if s.Len != nil {
+		check("len.read", s.Len.Read, true)
+		check("len.write", s.Len.Write, true)
+		check("len.error", s.Len.Error, true)
+	}
+	if s.Crc != nil {
+		check("crc.read", s.Crc.Read, true)
+		check("crc.write", s.Crc.Write, true)
+		check("crc.error", s.Crc.Error, true)
+	}
+	return errs
+}
+
+// ValidateFrame - проверка описания фрейма: имена в форматах, значения констант, staffing,
+// настройки длины и контрольной суммы для полей len# и crc#, стартовые и конечные байты
+// форматов чтения и ошибки. После проверки сборка и разбор фреймов не возвращают ошибок конфигурации
+func (s *CustomSlave) ValidateFrame() []error {
+	errs := s.ValidateFormat()
+
+	names := make([]string, 0, len(s.Const))
+	for name := range s.Const {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i, stringBytes := range s.Const[name] {
+			if _, err := common.ParseStringByte(stringBytes); err != nil {
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("const.%s.%d", name, i), "%s", err))
+			}
+		}
+	}
+	if s.Staffing != nil && s.Staffing.Byte != "" {
+		if b, err := common.ParseStringByte(s.Staffing.Byte); err != nil || len(b) == 0 {
+			errs = append(errs, common.NewFieldError("staffing.byte", "invalid byte %q", s.Staffing.Byte))
+		}
+	}
+
+	formats := []struct {
+		path   string
+		action string
+	}{
+		{"readFormat", ActionRead},
+		{"writeFormat", ActionWrite},
+		{"errorFormat", ActionError},
+	}
+	for _, f := range formats {
+		format, _ := s.getFormat(f.action)
+		for i, name := range format {
+			switch module.Field(name) {
+			case module.FieldLen:
+				if s.Len == nil {
+					errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d", f.path, i), "len is not specified"))
+				} else if !validCountBytes(s.Len.CountBytes) {
+					errs = append(errs, common.NewFieldError("len.coundBytes", "must be 1, 2, 4 or 8"))
+				}
+			case module.FieldCrc:
+				if s.Crc == nil {
+					errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d", f.path, i), "crc is not specified"))
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return uniqueErrors(errs)
+	}
+
+	// Форматы, по которым фреймы выделяются из потока
+	for _, f := range formats {
+		format, _ := s.getFormat(f.action)
+		if f.action == ActionWrite || len(format) == 0 {
+			continue
+		}
+		if _, _, _, _, err := s.ParseFormat(f.action); err != nil {
+			errs = append(errs, common.NewFieldError(f.path, "%s", err))
+		}
+	}
+	return errs
+}
+
+// validCountBytes - размер поля длины в байтах
+func validCountBytes(count int) bool {
+	switch count {
+	case 1, 2, 4, 8:
+		return true
+	}
+	return false
+}
+
+// uniqueErrors - убирает повторы одной ошибки для нескольких форматов
+func uniqueErrors(errs []error) []error {
+	found := make(map[string]bool)
+	result := make([]error, 0, len(errs))
+	for _, err := range errs {
+		if found[err.Error()] {
+			continue
+		}
+		found[err.Error()] = true
+		result = append(result, err)
+	}
+	return result
+}
@@ -7,30 +7,24 @@ import (
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
+	"rtu-test/e2e/common"
 	master2 "rtu-test/e2e/custom/master"
 	slave2 "rtu-test/e2e/custom/slave"
 	"rtu-test/e2e/display"
 	"rtu-test/e2e/modbus/master"
 	"rtu-test/e2e/modbus/slave"
+	"rtu-test/e2e/monitor"
+	"rtu-test/e2e/registers"
+	"rtu-test/e2e/replay"
 	"runtime"
-	"sync"
 )
 
-var instanceDevice *Device
-var onceDevice sync.Once
-
 const (
 	LogStdout = "stdout"
 	LogStderr = "stderr"
 )
 
-func Init() *Device {
-	onceDevice.Do(func() {
-		instanceDevice = &Device{}
-	})
-	return instanceDevice
-}
-
 type Device struct {
 	Version      string                `yaml:"version"`
 	Name         string                `yaml:"name"`
@@ -39,10 +33,21 @@ type Device struct {
 	LogLvl       string                `yaml:"logLvl"`
 	Description  string                `yaml:"description"`
 	ExitMessage  Message               `yaml:"exitMessage"`
+	Report       string                `yaml:"report"`
 	ModbusMaster *master.ModbusMaster  `yaml:"modbusMaster"`
 	ModbusSlave  *slave.ModbusSlave    `yaml:"modbusSlave"`
 	CustomSlave  *slave2.CustomSlave   `yaml:"slave"`
 	CustomMaster *master2.CustomMaster `yaml:"master"`
+	Replay       *replay.Replay        `yaml:"replay"`
+	Monitor      *monitor.Monitor      `yaml:"monitor"`
+	// Карта регистров: точки, на которые тесты ссылаются по имени
+	Registers registers.Map `yaml:"registers"`
+
+	// Файл конфигурации
+	file string
+	// Отчеты мастеров текущего запуска
+	modbusMasterReport *master.ReportGroups
+	customMasterReport *master.ReportGroups
 }
 
 // Load - загружает конфигурацию лога
@@ -56,10 +61,107 @@ func (d *Device) Load(s string) error {
 	if err := yaml.NewDecoder(file).Decode(d); err != nil {
 		return fmt.Errorf("parse yaml error: %s", err)
 	}
+	if errs := d.resolveRegisters(); len(errs) > 0 {
+		return common.NewConfigError("registers: %s", errs[0])
+	}
+	d.file = s
 	return nil
 }
 
-func (d *Device) RunTest(ctx context.Context) {
+// Validate - проверка ссылок тестов на карту регистров
+func (d *Device) Validate() []error {
+	return d.resolveRegisters()
+}
+
+// resolveRegisters - заменяет в тестах имена точек карты регистров адресами и значениями
+func (d *Device) resolveRegisters() (errs []error) {
+	if d.ModbusMaster != nil {
+		errs = append(errs, common.PrefixErrors("modbusMaster", d.ModbusMaster.ResolveRegisters(d.Registers))...)
+	}
+	if d.ModbusSlave != nil {
+		errs = append(errs, common.PrefixErrors("modbusSlave", d.ModbusSlave.ResolveRegisters(d.Registers))...)
+	}
+	return errs
+}
+
+// RunTest - запускает тесты и возвращает код завершения программы
+func (d *Device) RunTest(ctx context.Context) int {
+	return Run(ctx, d)
+}
+
+// Run - запускает все режимы всех устройств одновременно, каждый на своем порту.
+// Настройки лога и консоли общие для процесса и берутся из первого устройства, отличающиеся настройки
+// остальных устройств не применяются.
+// Возвращает общий код завершения после выполнения тестов всеми мастерами
+func Run(ctx context.Context, devices ...*Device) int {
+	if len(devices) == 0 {
+		fmt.Println("configuration file not found")
+		return ExitConfig
+	}
+	closeLog, err := devices[0].setupLog()
+	if err != nil {
+		fmt.Printf("Open log: %s\n", err)
+		return ExitConfig
+	}
+	defer closeLog()
+	for _, d := range devices[1:] {
+		if d.Log != devices[0].Log || d.Console != devices[0].Console || d.LogLvl != devices[0].LogLvl {
+			logrus.Warnf("%s: log settings are ignored, settings of %s are used", d.title(), devices[0].title())
+		}
+	}
+
+	var roles []*role
+	for _, d := range devices {
+		deviceRoles := d.roles()
+		if len(deviceRoles) == 0 {
+			fmt.Println("configuration file not found")
+			return ExitConfig
+		}
+		roles = append(roles, deviceRoles...)
+	}
+	if err := checkPorts(roles); err != nil {
+		fmt.Printf("Open port: %s\n", err)
+		return ExitConfig
+	}
+
+	several := len(roles) > 1
+	for _, r := range roles {
+		r.log = logrus.WithFields(logrus.Fields{"device": r.device.title(), "role": r.name})
+		if several {
+			r.prefix = fmt.Sprintf("[%s] ", r.title())
+		}
+	}
+
+	// Виртуальные порты назначаются до запуска, чтобы концы не зависели от порядка запуска горутин
+	for _, r := range roles {
+		if err := r.resolvePort(); err != nil {
+			fmt.Printf("%sOpen port: %s\n", r.prefix, err)
+			return ExitPort
+		}
+	}
+
+	// Вывод отчетов в конце выполнения программы
+	for _, d := range devices {
+		d.registerExitHandler()
+	}
+
+	code := runRoles(ctx, roles)
+	if several {
+		printSummary(roles)
+	}
+	return code
+}
+
+// setupLog - настраивает вывод пользовательских сообщений и технической информации.
+// Возвращает функцию закрытия открытых файлов
+func (d *Device) setupLog() (func(), error) {
+	var files []*os.File
+	closeLog := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
 	format := &logrus.TextFormatter{}
 
 	logrus.SetFormatter(format)
@@ -92,9 +194,9 @@ func (d *Device) RunTest(ctx context.Context) {
 	default:
 		file, err := os.OpenFile(d.Log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
 		if err != nil {
-			logrus.Fatal(err)
+			return nil, err
 		}
-		defer file.Close()
+		files = append(files, file)
 		display.Console().SetOutput(file)
 	}
 
@@ -120,47 +222,37 @@ func (d *Device) RunTest(ctx context.Context) {
 	default:
 		file, err := os.OpenFile(d.Log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
 		if err != nil {
-			logrus.Fatal(err)
+			closeLog()
+			return nil, err
 		}
-		defer file.Close()
+		files = append(files, file)
 		logrus.SetOutput(file)
 	}
-	switch {
-	case d.ModbusMaster != nil:
-		report := master.ReportGroups{
-			Name:        d.Name,
-			Description: d.Description,
-		}
-		// Вывод отчета в конце выполнения программы
-		logrus.RegisterExitHandler(func() { d.ExitMessage.PrintReportMasterGroups(report) })
-
-		fmt.Printf("Open port: %s\n", d.ModbusMaster.Port)
-		// Запуск тестов
-		if err := d.ModbusMaster.Run(&report); err != nil {
-			logrus.Fatalf("Exit app modbus master: %s", err)
-		}
-	case d.ModbusSlave != nil:
-		// TODO Добавить групповой отчет
-
-		fmt.Printf("Open port: %s\n", d.ModbusSlave.Port)
+	return closeLog, nil
+}
 
-		if err := d.ModbusSlave.Run(); err != nil {
-			logrus.Fatalf("Exit app modbus slave: %s", err)
+// registerExitHandler - вывод сообщения и сохранение отчетов при выходе из программы
+func (d *Device) registerExitHandler() {
+	logrus.RegisterExitHandler(func() {
+		switch {
+		case d.modbusMasterReport != nil:
+			d.ExitMessage.PrintReportMasterGroups(*d.modbusMasterReport)
+		case d.customMasterReport != nil:
+			d.ExitMessage.PrintReportMasterGroups(*d.customMasterReport)
+		case d.CustomSlave != nil:
+			display.Console().Print(&d.ExitMessage, nil)
 		}
-	case d.CustomSlave != nil:
-		// Вывод отчета в конце выполнения программы
-		logrus.RegisterExitHandler(func() { display.Console().Print(&d.ExitMessage, nil) })
-
-		fmt.Printf("Open port: %s\n", d.CustomSlave.Port)
+		d.WriteReports(d.NewReport())
+	})
+}
 
-		if err := d.CustomSlave.Run(); err != nil {
-			logrus.Fatalf("Exit app slave: %s", err)
-		}
-	case d.CustomMaster != nil:
-		// TODO
-		logrus.Fatal("not support")
-	default:
-		logrus.Fatal("configuration file not found")
+// title - имя устройства в логе и сводке: файл конфигурации или имя из конфигурации
+func (d *Device) title() string {
+	if d.file != "" {
+		return filepath.Base(d.file)
 	}
-
+	if d.Name != "" {
+		return d.Name
+	}
+	return "device"
 }
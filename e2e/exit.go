@@ -0,0 +1,50 @@
+package e2e
+
+import (
+	"errors"
+	"fmt"
+	"rtu-test/e2e/common"
+)
+
+// Коды завершения программы
+const (
+	// Все тесты прошли
+	ExitPass = 0
+	// Есть проваленные тесты
+	ExitFail = 1
+	// Ошибка конфигурации
+	ExitConfig = 2
+	// Ошибка порта
+	ExitPort = 3
+)
+
+// exitCode - код завершения по ошибке исполнителя тестов
+func exitCode(err error) int {
+	var configError *common.ConfigError
+	if errors.As(err, &configError) {
+		return ExitConfig
+	}
+	return ExitPort
+}
+
+func passCode(pass bool) int {
+	if pass {
+		return ExitPass
+	}
+	return ExitFail
+}
+
+// codeName - код завершения в сводке
+func codeName(code int) string {
+	switch code {
+	case ExitPass:
+		return "PASS"
+	case ExitFail:
+		return "FAIL"
+	case ExitConfig:
+		return "CONFIG ERROR"
+	case ExitPort:
+		return "PORT ERROR"
+	}
+	return fmt.Sprintf("EXIT %d", code)
+}
@@ -0,0 +1,174 @@
+package fault
+
+import (
+	"fmt"
+	"math/rand"
+	"rtu-test/e2e/common"
+	"sync"
+	"time"
+)
+
+// Типы ошибок в ответе слейва
+const (
+	// Неверная контрольная сумма
+	CorruptCrc = "corruptCrc"
+	// Ответ не отправляется
+	Drop = "drop"
+	// Ответ обрезается на count байт
+	Truncate = "truncate"
+	// В конец ответа добавляется count случайных байт
+	Garbage = "garbage"
+	// Ответ отправляется count + 1 раз
+	Duplicate = "duplicate"
+	// Ответ задерживается на delay
+	Delay = "delay"
+	// Ответ от другого устройства
+	WrongId = "wrongId"
+	// Инвертируются count случайных бит ответа
+	BitFlip = "bitFlip"
+)
+
+var (
+	mu     sync.Mutex
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Fault - ошибка, вносимая в ответ слейва
+type Fault struct {
+	Type string `yaml:"type"`
+	// Вероятность ошибки в ответе от 0 до 1. По умолчанию 1
+	Probability *float64 `yaml:"probability"`
+	// Для delay - задержка ответа
+	Delay string `yaml:"delay"`
+	// Для truncate, garbage, duplicate и bitFlip. По умолчанию 1
+	Count int `yaml:"count"`
+	// Для wrongId - адрес в ответе. По умолчанию адрес слейва + 1
+	SlaveId *uint8 `yaml:"slaveId"`
+	// Для wrongId в режиме slave - константа с адресом устройства
+	Const string `yaml:"const"`
+}
+
+// Validate - проверка настроек после загрузки конфигурации
+func (f *Fault) Validate() (errs []error) {
+	switch f.Type {
+	case CorruptCrc, Drop, Truncate, Garbage, Duplicate, WrongId, BitFlip:
+	case Delay:
+		if common.ParseDuration(f.Delay) <= 0 {
+			errs = append(errs, common.NewFieldError("delay", "invalid delay %q", f.Delay))
+		}
+	default:
+		errs = append(errs, common.NewFieldError("type", "unknown fault %q", f.Type))
+	}
+	if f.Probability != nil && (*f.Probability < 0 || *f.Probability > 1) {
+		errs = append(errs, common.NewFieldError("probability", "probability must be between 0 and 1"))
+	}
+	if f.Count < 0 {
+		errs = append(errs, common.NewFieldError("count", "count must not be negative"))
+	}
+	return errs
+}
+
+// String - описание ошибки для отчета
+func (f *Fault) String() string {
+	switch f.Type {
+	case Delay:
+		return fmt.Sprintf("%s %s", f.Type, f.Delay)
+	case Truncate, Garbage, Duplicate, BitFlip:
+		return fmt.Sprintf("%s %d", f.Type, f.count())
+	case WrongId:
+		if f.SlaveId != nil {
+			return fmt.Sprintf("%s %d", f.Type, *f.SlaveId)
+		}
+	}
+	return f.Type
+}
+
+// WrongSlaveId - адрес для ответа от другого устройства
+func (f *Fault) WrongSlaveId(slaveId uint8) uint8 {
+	if f.SlaveId != nil {
+		return *f.SlaveId
+	}
+	return slaveId + 1
+}
+
+func (f *Fault) count() int {
+	if f.Count == 0 {
+		return 1
+	}
+	return f.Count
+}
+
+// Plan - ошибки, выбранные для одного ответа
+type Plan []*Fault
+
+// Choose - выбирает ошибки для ответа с учетом вероятности
+func Choose(faults []*Fault) (plan Plan) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, f := range faults {
+		if f.Probability == nil || random.Float64() < *f.Probability {
+			plan = append(plan, f)
+		}
+	}
+	return plan
+}
+
+// Get - ошибка типа faultType или nil если она не выбрана
+func (p Plan) Get(faultType string) *Fault {
+	for _, f := range p {
+		if f.Type == faultType {
+			return f
+		}
+	}
+	return nil
+}
+
+// Names - описание выбранных ошибок для отчета
+func (p Plan) Names() (names []string) {
+	for _, f := range p {
+		names = append(names, f.String())
+	}
+	return names
+}
+
+// Apply - вносит в собранный кадр ошибки, не зависящие от протокола: bitFlip, truncate, garbage.
+// Возвращает кадры для отправки (нет кадров при drop, повтор при duplicate) и задержку ответа.
+// Ошибки corruptCrc и wrongId вносятся при сборке кадра
+func (p Plan) Apply(frame []byte) (frames [][]byte, delay time.Duration) {
+	if p.Get(Drop) != nil {
+		return nil, 0
+	}
+	frame = append([]byte{}, frame...)
+
+	mu.Lock()
+	if f := p.Get(BitFlip); f != nil && len(frame) > 0 {
+		for i := 0; i < f.count(); i++ {
+			bit := random.Intn(len(frame) * 8)
+			frame[bit/8] ^= 1 << (bit % 8)
+		}
+	}
+	if f := p.Get(Truncate); f != nil {
+		if f.count() < len(frame) {
+			frame = frame[:len(frame)-f.count()]
+		} else {
+			frame = frame[:0]
+		}
+	}
+	if f := p.Get(Garbage); f != nil {
+		for i := 0; i < f.count(); i++ {
+			frame = append(frame, byte(random.Intn(256)))
+		}
+	}
+	mu.Unlock()
+
+	frames = append(frames, frame)
+	if f := p.Get(Duplicate); f != nil {
+		for i := 0; i < f.count(); i++ {
+			frames = append(frames, frame)
+		}
+	}
+	if f := p.Get(Delay); f != nil {
+		delay = common.ParseDuration(f.Delay)
+	}
+	return frames, delay
+}
@@ -0,0 +1,90 @@
+package fault
+
+import (
+	"github.com/schnack/gotest"
+	"testing"
+	"time"
+)
+
+func TestChoose(t *testing.T) {
+	never := 0.0
+	always := 1.0
+	plan := Choose([]*Fault{
+		{Type: Drop, Probability: &never},
+		{Type: Truncate, Probability: &always},
+		{Type: Garbage},
+	})
+	if err := gotest.Expect(plan.Names()).Eq([]string{"truncate 1", "garbage 1"}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(plan.Get(Drop) == nil).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlan_Apply(t *testing.T) {
+	frame := []byte{0x01, 0x03, 0x02, 0x01, 0x02}
+
+	frames, delay := Plan{{Type: Truncate, Count: 2}, {Type: Duplicate}, {Type: Delay, Delay: "10ms"}}.Apply(frame)
+	if err := gotest.Expect(frames).Eq([][]byte{{0x01, 0x03, 0x02}, {0x01, 0x03, 0x02}}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(delay).Eq(10 * time.Millisecond); err != nil {
+		t.Error(err)
+	}
+
+	frames, _ = Plan{{Type: Garbage, Count: 3}}.Apply(frame)
+	if err := gotest.Expect(len(frames[0])).Eq(8); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(frames[0][:5]).Eq(frame); err != nil {
+		t.Error(err)
+	}
+
+	frames, _ = Plan{{Type: BitFlip}}.Apply(frame)
+	diff := 0
+	for i := range frame {
+		for b := frames[0][i] ^ frame[i]; b != 0; b &= b - 1 {
+			diff++
+		}
+	}
+	if err := gotest.Expect(diff).Eq(1); err != nil {
+		t.Error(err)
+	}
+
+	frames, _ = Plan{{Type: Drop}, {Type: Duplicate}}.Apply(frame)
+	if err := gotest.Expect(len(frames)).Eq(0); err != nil {
+		t.Error(err)
+	}
+
+	// Исходный кадр не меняется
+	if err := gotest.Expect(frame).Eq([]byte{0x01, 0x03, 0x02, 0x01, 0x02}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFault_Validate(t *testing.T) {
+	probability := 1.5
+	if err := gotest.Expect(len((&Fault{Type: Drop}).Validate())).Eq(0); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len((&Fault{Type: "noise"}).Validate())).Eq(1); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len((&Fault{Type: Delay}).Validate())).Eq(1); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len((&Fault{Type: Drop, Probability: &probability, Count: -1}).Validate())).Eq(2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFault_WrongSlaveId(t *testing.T) {
+	id := uint8(7)
+	if err := gotest.Expect((&Fault{Type: WrongId}).WrongSlaveId(1)).Eq(uint8(2)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect((&Fault{Type: WrongId, SlaveId: &id}).WrongSlaveId(1)).Eq(uint8(7)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,30 @@
+package e2e
+
+import (
+	"context"
+	"rtu-test/e2e/transport"
+)
+
+// Виртуальный порт по умолчанию для запуска нескольких конфигураций в одном процессе
+const LoopbackPort = transport.VirtualPrefix + "loopback"
+
+// RunLoopback - запускает несколько конфигураций в одном процессе, соединяя их виртуальным портом.
+// Порты не заданные как virtual:// заменяются на LoopbackPort.
+// Возвращает код завершения после выполнения тестов всеми мастерами
+func RunLoopback(ctx context.Context, devices ...*Device) int {
+	for _, d := range devices {
+		for _, r := range d.roles() {
+			if !r.tcp && !transport.IsVirtual(*r.port) {
+				*r.port = LoopbackPort
+			}
+		}
+	}
+	return Run(ctx, devices...)
+}
+
+// SetPort - заменяет порт первого режима устройства
+func (d *Device) SetPort(port string) {
+	if roles := d.roles(); len(roles) > 0 {
+		*roles[0].port = port
+	}
+}
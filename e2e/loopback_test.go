@@ -0,0 +1,40 @@
+package e2e
+
+import (
+	"context"
+	"github.com/schnack/gotest"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/modbus/slave"
+	"rtu-test/e2e/transport"
+	"testing"
+)
+
+func TestRunLoopback(t *testing.T) {
+	if _, err := transport.ResolvePort("virtual://check"); err != nil {
+		t.Skipf("pty is not available: %s", err)
+	}
+	defer transport.CloseVirtual()
+
+	var register uint16 = 0x0102
+	var address uint16 = 0
+	slaveDevice := &Device{ModbusSlave: &slave.ModbusSlave{
+		SlaveId: 1, Port: "/dev/ttyUSB0", BoundRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, SilentInterval: "5ms",
+		HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &register}},
+	}}
+	masterDevice := &Device{ModbusMaster: &master.ModbusMaster{
+		SlaveId: 1, Port: "/dev/ttyUSB1", BoundRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, Timeout: "1s",
+		Tests: map[string][]*master.ModbusMasterTest{"Default": {{
+			Name: "read", Function: "ReadHoldingRegisters", Address: &address,
+			Expected: []*common.Value{{Name: "param1", Uint16: &register}},
+		}}},
+	}}
+
+	if err := gotest.Expect(RunLoopback(context.Background(), slaveDevice, masterDevice)).Eq(ExitPass); err != nil {
+		t.Error(err)
+	}
+	// Порты без virtual:// соединяются общим виртуальным портом
+	if err := gotest.Expect(masterDevice.ModbusMaster.Port != "/dev/ttyUSB1").True(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,31 @@
+package master
+
+import (
+	"rtu-test/e2e/capture"
+	"strings"
+)
+
+// CaptureLink - тип кадров pcap для режима modbus
+func CaptureLink(mode string) uint16 {
+	switch strings.ToLower(mode) {
+	case ModeTCP:
+		return capture.LinkTCP
+	case ModeASCII:
+		return capture.LinkASCII
+	default:
+		return capture.LinkRTU
+	}
+}
+
+// captureHandler - записывает adu запросов и ответов обработчика
+type captureHandler struct {
+	clientHandler
+	capture capture.Capture
+}
+
+func (ch *captureHandler) Send(aduRequest []byte) ([]byte, error) {
+	ch.capture.Frame(capture.TX, aduRequest)
+	aduResponse, err := ch.clientHandler.Send(aduRequest)
+	ch.capture.Frame(capture.RX, aduResponse)
+	return aduResponse, err
+}
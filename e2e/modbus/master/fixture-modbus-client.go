@@ -10,8 +10,16 @@ type FixtureModBusClient struct {
 	Address     uint16
 	Quantity    uint16
 	SingleValue uint16
-	Value       []byte
-	Sleep       time.Duration
+	// ReadWriteMultipleRegisters
+	WriteAddress  uint16
+	WriteQuantity uint16
+	// SendPdu
+	FunctionCode byte
+	// MaskWriteRegister
+	AndMask uint16
+	OrMask  uint16
+	Value   []byte
+	Sleep   time.Duration
 
 	Results []byte
 	Error   error
@@ -78,6 +86,8 @@ func (f *FixtureModBusClient) WriteMultipleRegisters(address, quantity uint16, v
 func (f *FixtureModBusClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (results []byte, err error) {
 	f.Address = readAddress
 	f.Quantity = readQuantity
+	f.WriteAddress = writeAddress
+	f.WriteQuantity = writeQuantity
 	f.Value = value
 	time.Sleep(f.Sleep)
 	return f.Results, f.Error
@@ -85,6 +95,8 @@ func (f *FixtureModBusClient) ReadWriteMultipleRegisters(readAddress, readQuanti
 
 func (f *FixtureModBusClient) MaskWriteRegister(address, andMask, orMask uint16) (results []byte, err error) {
 	f.Address = address
+	f.AndMask = andMask
+	f.OrMask = orMask
 	time.Sleep(f.Sleep)
 	return f.Results, f.Error
 }
@@ -94,3 +106,10 @@ func (f *FixtureModBusClient) ReadFIFOQueue(address uint16) (results []byte, err
 	time.Sleep(f.Sleep)
 	return f.Results, f.Error
 }
+
+func (f *FixtureModBusClient) SendPdu(functionCode byte, data []byte) (results []byte, err error) {
+	f.FunctionCode = functionCode
+	f.Value = data
+	time.Sleep(f.Sleep)
+	return f.Results, f.Error
+}
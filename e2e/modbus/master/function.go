@@ -0,0 +1,94 @@
+package master
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// String - название функции как в конфигурации
+func (f ModbusFunction) String() string {
+	switch f {
+	case ReadCoils:
+		return "read coils"
+	case ReadDiscreteInputs:
+		return "read discrete inputs"
+	case ReadHoldingRegisters:
+		return "read holding registers"
+	case ReadInputRegisters:
+		return "read input registers"
+	case WriteSingleCoil:
+		return "write single coil"
+	case WriteSingleRegister:
+		return "write single register"
+	case WriteMultipleCoils:
+		return "write multiple coils"
+	case WriteMultipleRegisters:
+		return "write multiple registers"
+	case MaskWriteRegister:
+		return "mask write register"
+	case ReadWriteMultipleRegisters:
+		return "read write multiple registers"
+	case ReadFIFOQueue:
+		return "read fifo queue"
+	case RawFunction:
+		return "raw"
+	}
+	return fmt.Sprintf("function 0x%02x", int(f))
+}
+
+// ExceptionName - название исключения modbus как в конфигурации
+func ExceptionName(code byte) string {
+	switch code {
+	case 1:
+		return "illegal function"
+	case 2:
+		return "illegal data address"
+	case 3:
+		return "illegal data value"
+	case 4:
+		return "server device failure"
+	case 5:
+		return "acknowledge"
+	case 6:
+		return "server device busy"
+	case 8:
+		return "memory parity error"
+	case 10:
+		return "gateway path unavailable"
+	case 11:
+		return "gateway target device failed to respond"
+	}
+	return fmt.Sprintf("exception 0x%02x", code)
+}
+
+// ExceptionCode - код исключения modbus по названию ("illegal data address") или номеру ("2", "0x02")
+func ExceptionCode(name string) (byte, bool) {
+	exception := strings.ReplaceAll(strings.ToLower(name), " ", "")
+	if strings.HasPrefix(exception, "0x") {
+		if a, err := strconv.ParseInt(strings.TrimPrefix(exception, "0x"), 16, 8); err == nil {
+			exception = strconv.Itoa(int(a))
+		}
+	}
+	switch exception {
+	case "illegalfunction", "1":
+		return 1, true
+	case "illegaldataaddress", "2":
+		return 2, true
+	case "illegaldatavalue", "3":
+		return 3, true
+	case "serverdevicefailure", "4":
+		return 4, true
+	case "acknowledge", "5":
+		return 5, true
+	case "serverdevicebusy", "6":
+		return 6, true
+	case "memoryparityerror", "8":
+		return 8, true
+	case "gatewaypathunavailable", "10":
+		return 10, true
+	case "gatewaytargetdevicefailedtorespond", "11":
+		return 11, true
+	}
+	return 0, false
+}
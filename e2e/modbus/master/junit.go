@@ -0,0 +1,116 @@
+package master
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Details string `xml:",chardata"`
+}
+
+// WriteJUnit - записывает отчет в формате JUnit XML. Каждая группа тестов - отдельный testsuite
+func (rg *ReportGroups) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: rg.Name}
+	var total time.Duration
+	for _, group := range rg.ReportGroup {
+		suite := junitTestSuite{Name: group.Name}
+		var groupTime time.Duration
+		for _, test := range group.Tests {
+			testCase := junitTestCase{Name: test.Name, ClassName: group.Name, Time: junitTime(test.GotTime)}
+			switch {
+			case test.Skip != "":
+				testCase.Skipped = &junitSkipped{Message: test.Skip}
+				suite.Skipped++
+			case !test.Pass:
+				testCase.Failure = test.junitFailure()
+				suite.Failures++
+			}
+			groupTime += test.GotTime
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = junitTime(groupTime)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		total += groupTime
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitFailure - описание не прошедших проверок
+func (rt *ReportMasterTest) junitFailure() *junitFailure {
+	var names []string
+	var details []string
+	for _, e := range rt.Expected {
+		if e.Pass {
+			continue
+		}
+		names = append(names, e.Name)
+		details = append(details, fmt.Sprintf("%s (%s): expected %s got %s", e.Name, e.Type, e.Expected, e.Got))
+	}
+	if rt.GotError != "" {
+		details = append(details, fmt.Sprintf("error: %s", rt.GotError))
+	}
+	if len(rt.GotByte) > 0 {
+		details = append(details, fmt.Sprintf("got bytes: [% x]", rt.GotByte))
+	}
+	message := "failed: " + strings.Join(names, ", ")
+	if len(names) == 0 {
+		message = "failed"
+	}
+	return &junitFailure{Message: message, Details: strings.Join(details, "\n")}
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
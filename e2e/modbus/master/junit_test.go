@@ -0,0 +1,70 @@
+package master
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/schnack/gotest"
+	"rtu-test/e2e/common"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportGroups_WriteJUnit(t *testing.T) {
+	report := ReportGroups{
+		Name: "bench",
+		ReportGroup: []ReportGroup{
+			{Name: "Default", Tests: []ReportMasterTest{
+				{Name: "ok", Pass: true, GotTime: 1500 * time.Millisecond},
+				{Name: "skip", Pass: true, Skip: "not ready"},
+				{Name: "fail", GotTime: 500 * time.Millisecond, GotError: "timeout", Expected: []common.ReportExpected{
+					{Name: "param1", Pass: true, Type: "uint16", Expected: "1", Got: "1"},
+					{Name: "param2", Pass: false, Type: "uint16", Expected: "2", Got: "3"},
+				}},
+			}},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gotest.Expect(report.WriteJUnit(buf)).Nil(); err != nil {
+		t.Fatal(err)
+	}
+
+	result := junitTestSuites{}
+	if err := gotest.Expect(xml.Unmarshal(buf.Bytes(), &result)).Nil(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gotest.Expect(result.Tests).Eq(3); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(result.Failures).Eq(1); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(result.Skipped).Eq(1); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(result.Time).Eq("2.000"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len(result.Suites)).Eq(1); err != nil {
+		t.Fatal(err)
+	}
+
+	suite := result.Suites[0]
+	if err := gotest.Expect(suite.Cases[0].Time).Eq("1.500"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(suite.Cases[1].Skipped.Message).Eq("not ready"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(suite.Cases[2].Failure.Message).Eq("failed: param2"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(strings.Contains(suite.Cases[2].Failure.Details, "param2 (uint16): expected 2 got 3")).True(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(strings.Contains(suite.Cases[2].Failure.Details, "error: timeout")).True(); err != nil {
+		t.Error(err)
+	}
+}
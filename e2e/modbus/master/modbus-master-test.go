@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"github.com/goburrow/modbus"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/registers"
 	"rtu-test/e2e/template"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,15 +18,20 @@ import (
 type ModbusFunction int
 
 const (
-	NilFunction            = ModbusFunction(0)
-	ReadCoils              = ModbusFunction(modbus.FuncCodeReadCoils)
-	ReadDiscreteInputs     = ModbusFunction(modbus.FuncCodeReadDiscreteInputs)
-	ReadHoldingRegisters   = ModbusFunction(modbus.FuncCodeReadHoldingRegisters)
-	ReadInputRegisters     = ModbusFunction(modbus.FuncCodeReadInputRegisters)
-	WriteSingleCoil        = ModbusFunction(modbus.FuncCodeWriteSingleCoil)
-	WriteSingleRegister    = ModbusFunction(modbus.FuncCodeWriteSingleRegister)
-	WriteMultipleCoils     = ModbusFunction(modbus.FuncCodeWriteMultipleCoils)
-	WriteMultipleRegisters = ModbusFunction(modbus.FuncCodeWriteMultipleRegisters)
+	NilFunction                = ModbusFunction(0)
+	ReadCoils                  = ModbusFunction(modbus.FuncCodeReadCoils)
+	ReadDiscreteInputs         = ModbusFunction(modbus.FuncCodeReadDiscreteInputs)
+	ReadHoldingRegisters       = ModbusFunction(modbus.FuncCodeReadHoldingRegisters)
+	ReadInputRegisters         = ModbusFunction(modbus.FuncCodeReadInputRegisters)
+	WriteSingleCoil            = ModbusFunction(modbus.FuncCodeWriteSingleCoil)
+	WriteSingleRegister        = ModbusFunction(modbus.FuncCodeWriteSingleRegister)
+	WriteMultipleCoils         = ModbusFunction(modbus.FuncCodeWriteMultipleCoils)
+	WriteMultipleRegisters     = ModbusFunction(modbus.FuncCodeWriteMultipleRegisters)
+	MaskWriteRegister          = ModbusFunction(modbus.FuncCodeMaskWriteRegister)
+	ReadWriteMultipleRegisters = ModbusFunction(modbus.FuncCodeReadWriteMultipleRegisters)
+	ReadFIFOQueue              = ModbusFunction(modbus.FuncCodeReadFIFOQueue)
+	// Произвольный pdu, код функции задается в functionCode
+	RawFunction = ModbusFunction(-1)
 )
 
 type ModbusMasterTest struct {
@@ -31,10 +39,18 @@ type ModbusMasterTest struct {
 	Skip   string  `yaml:"skip"`
 	Before Message `yaml:"before"`
 	// Переопределить адрес устройства, если на одной шине несколько блоков
-	SlaveId    uint8           `yaml:"slaveId"`
-	Function   string          `yaml:"function"`
-	Address    *uint16         `yaml:"address"`
-	Quantity   *uint16         `yaml:"quantity"`
+	SlaveId  uint8  `yaml:"slaveId"`
+	Function string `yaml:"function"`
+	// Код функции для function: raw
+	FunctionCode uint8   `yaml:"functionCode"`
+	Address      *uint16 `yaml:"address"`
+	Quantity     *uint16 `yaml:"quantity"`
+	// Адрес и количество записываемых регистров для ReadWriteMultipleRegisters
+	WriteAddress  *uint16 `yaml:"writeAddress"`
+	WriteQuantity *uint16 `yaml:"writeQuantity"`
+	// Маски для MaskWriteRegister
+	AndMask    *uint16         `yaml:"andMask"`
+	OrMask     *uint16         `yaml:"orMask"`
 	Write      []*common.Value `yaml:"write"`
 	Expected   []*common.Value `yaml:"expected"`
 	Success    Message         `yaml:"success"`
@@ -42,30 +58,74 @@ type ModbusMasterTest struct {
 	After      Message         `yaml:"after"`
 	Fatal      string          `yaml:"fatal"`
 	Disconnect bool            `yaml:"disconnect"`
+	// Точки карты регистров для чтения. Функция, адрес и количество вычисляются,
+	// точки, идущие подряд, читаются одним запросом
+	Read []string `yaml:"read"`
+	// Ожидаемые значения точек по имени. Задаются в expected в виде map вместо списка
+	ExpectedPoints map[string]*registers.Expected `yaml:"-"`
+
+	// Лог исполнителя. Задается мастером перед запуском теста
+	Log logrus.FieldLogger `yaml:"-"`
+	// Порядок слов устройства. Задается мастером перед запуском теста
+	WordOrder common.WordOrder `yaml:"-"`
+
+	// Точки, прочитанные без ожидаемого значения. Выводятся в отчет без проверки
+	unchecked map[*common.Value]bool
 }
 
-func (mt *ModbusMasterTest) Run(client modbus.Client) ReportMasterTest {
-	if err := mt.Validation(); err != nil {
-		logrus.Fatal(err)
+// UnmarshalYAML - expected в виде map задает ожидаемые значения точек карты регистров
+func (mt *ModbusMasterTest) UnmarshalYAML(node *yaml.Node) error {
+	type modbusMasterTest ModbusMasterTest
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != "expected" || node.Content[i+1].Kind != yaml.MappingNode {
+				continue
+			}
+			if err := node.Content[i+1].Decode(&mt.ExpectedPoints); err != nil {
+				return err
+			}
+			rest := *node
+			rest.Content = append(append([]*yaml.Node{}, node.Content[:i]...), node.Content[i+2:]...)
+			return rest.Decode((*modbusMasterTest)(mt))
+		}
+	}
+	return node.Decode((*modbusMasterTest)(mt))
+}
+
+func (mt *ModbusMasterTest) logger() logrus.FieldLogger {
+	if mt.Log == nil {
+		return logrus.StandardLogger()
 	}
+	return mt.Log
+}
 
+func (mt *ModbusMasterTest) Run(client modbus.Client) ReportMasterTest {
 	report := ReportMasterTest{Name: mt.Name, Pass: true, Skip: mt.Skip}
-	logrus.Warn(common.Render(template.TestMasterModBusRUN, report))
+	mt.logger().Warn(common.Render(template.TestMasterModBusRUN, report))
 	if report.Skip != "" {
-		logrus.Warn(common.Render(template.TestMasterModBusSKIP, report))
+		mt.logger().Warn(common.Render(template.TestMasterModBusSKIP, report))
+		return report
+	}
+	// Тест с ошибкой в конфигурации не выполняется
+	if err := mt.Validation(); err != nil {
+		report.Pass = false
+		report.GotError = err.Error()
+		mt.logger().Error(common.Render(template.TestMasterModBusFAIL, report))
 		return report
 	}
 	mt.Before.PrintReportMasterTest(report)
 	mt.Exec(client, &report)
 	mt.Check(&report)
 	if report.Pass {
-		logrus.Warn(common.Render(template.TestMasterModBusPASS, report))
+		mt.logger().Warn(common.Render(template.TestMasterModBusPASS, report))
 		mt.Success.PrintReportMasterTest(report)
 	} else {
-		logrus.Error(common.Render(template.TestMasterModBusFAIL, report))
+		mt.logger().Error(common.Render(template.TestMasterModBusFAIL, report))
 		mt.Error.PrintReportMasterTest(report)
+		// Дальнейшие тесты не выполняются
 		if mt.Fatal != "" {
-			logrus.Fatal(mt.Fatal)
+			mt.logger().Error(mt.Fatal)
+			return report
 		}
 	}
 	mt.After.PrintReportMasterTest(report)
@@ -78,10 +138,15 @@ func (mt *ModbusMasterTest) Check(report *ReportMasterTest) {
 	for _, v := range mt.Expected {
 		bitSize := 8
 		switch mt.getFunction() {
-		case ReadHoldingRegisters, ReadInputRegisters, WriteSingleRegister, WriteMultipleRegisters:
+		case ReadHoldingRegisters, ReadInputRegisters, WriteSingleRegister, WriteMultipleRegisters,
+			MaskWriteRegister, ReadWriteMultipleRegisters, ReadFIFOQueue:
 			bitSize = 16
 		}
-		countBit, expected = v.Check(report.GotByte, report.GotTime, report.GotError, countBit, bitSize, binary.BigEndian)
+		countBit, expected = v.CheckOrder(report.GotByte, report.GotTime, report.GotError, countBit, bitSize, binary.BigEndian, mt.WordOrder)
+		if mt.unchecked[v] && report.GotError == "" {
+			expected.Pass = true
+			expected.Expected, expected.ExpectedHex, expected.ExpectedBin = "", "", ""
+		}
 		if !expected.Pass {
 			report.Pass = false
 		}
@@ -142,36 +207,111 @@ func (mt *ModbusMasterTest) Exec(client modbus.Client, report *ReportMasterTest)
 		report.GotTime = time.Since(startTime)
 	case WriteSingleRegister:
 		for _, w := range mt.Write {
-			report.Write = append(report.Write, w.ReportWrite(binary.BigEndian))
+			report.Write = append(report.Write, w.ReportWriteOrder(binary.BigEndian, mt.WordOrder))
 		}
 		startTime := time.Now()
-		if report.GotByte, err = client.WriteSingleRegister(*mt.Address, binary.BigEndian.Uint16(common.ValueToByte16(mt.Write))); err != nil {
+		if report.GotByte, err = client.WriteSingleRegister(*mt.Address, binary.BigEndian.Uint16(common.ValueToByte16(mt.Write, mt.WordOrder))); err != nil {
 			report.GotError = err.Error()
 		}
 		report.GotTime = time.Since(startTime)
 	case WriteMultipleRegisters:
+		for _, w := range mt.Write {
+			report.Write = append(report.Write, w.ReportWriteOrder(binary.BigEndian, mt.WordOrder))
+		}
+		startTime := time.Now()
+		if report.GotByte, err = client.WriteMultipleRegisters(*mt.Address, mt.getQuantity(), common.ValueToByte16(mt.Write, mt.WordOrder)); err != nil {
+			report.GotError = err.Error()
+		}
+		report.GotTime = time.Since(startTime)
+	case MaskWriteRegister:
+		andMask, orMask := mt.getMasks()
+		report.Write = append(report.Write,
+			(&common.Value{Name: "andMask", Uint16: &andMask}).ReportWrite(binary.BigEndian),
+			(&common.Value{Name: "orMask", Uint16: &orMask}).ReportWrite(binary.BigEndian))
+		startTime := time.Now()
+		if report.GotByte, err = client.MaskWriteRegister(*mt.Address, andMask, orMask); err != nil {
+			report.GotError = err.Error()
+		}
+		report.GotTime = time.Since(startTime)
+	case ReadWriteMultipleRegisters:
+		for _, w := range mt.Write {
+			report.Write = append(report.Write, w.ReportWriteOrder(binary.BigEndian, mt.WordOrder))
+		}
+		startTime := time.Now()
+		if report.GotByte, err = client.ReadWriteMultipleRegisters(*mt.Address, mt.getQuantity(), *mt.WriteAddress, mt.getWriteQuantity(), common.ValueToByte16(mt.Write, mt.WordOrder)); err != nil {
+			report.GotError = err.Error()
+		}
+		report.GotTime = time.Since(startTime)
+	case ReadFIFOQueue:
+		startTime := time.Now()
+		if report.GotByte, err = client.ReadFIFOQueue(*mt.Address); err != nil {
+			report.GotError = err.Error()
+		}
+		report.GotTime = time.Since(startTime)
+	case RawFunction:
 		for _, w := range mt.Write {
 			report.Write = append(report.Write, w.ReportWrite(binary.BigEndian))
 		}
+		rawClient, ok := client.(RawClient)
+		if !ok {
+			report.GotError = "raw pdu is not supported by client"
+			return
+		}
 		startTime := time.Now()
-		if report.GotByte, err = client.WriteMultipleRegisters(*mt.Address, mt.getQuantity(), common.ValueToByte16(mt.Write)); err != nil {
+		if report.GotByte, err = rawClient.SendPdu(mt.FunctionCode, common.ValueToByte(mt.Write)); err != nil {
 			report.GotError = err.Error()
 		}
 		report.GotTime = time.Since(startTime)
 	}
 }
 
+// Validate - проверка теста после загрузки конфигурации
+func (mt *ModbusMasterTest) Validate() []error {
+	// Тест по карте регистров проверяется при разборе точек
+	if mt.byRegisters() {
+		return nil
+	}
+	if mt.getFunction() == NilFunction {
+		return []error{common.NewFieldError("function", "unknown function %q", mt.Function)}
+	}
+	if mt.Address == nil && mt.getFunction() != RawFunction {
+		return []error{common.NewFieldError("", "test %s: address is nil", mt.Name)}
+	}
+	if err := mt.Validation(); err != nil {
+		return []error{common.NewFieldError("", "test %s: %s", mt.Name, err)}
+	}
+	return nil
+}
+
 // TODO
 func (mt *ModbusMasterTest) Validation() error {
-	if mt.Address == nil {
+	if mt.getFunction() == NilFunction {
+		return fmt.Errorf("unknown function %q", mt.Function)
+	}
+	if mt.Address == nil && mt.getFunction() != RawFunction {
 		return fmt.Errorf("address is nil")
 	}
 	switch mt.getFunction() {
-	case ReadDiscreteInputs, WriteMultipleRegisters, ReadCoils, ReadHoldingRegisters, ReadInputRegisters, WriteMultipleCoils:
+	case RawFunction:
+		if mt.FunctionCode == 0 {
+			return fmt.Errorf("functionCode is not set")
+		}
+	case ReadDiscreteInputs, WriteMultipleRegisters, ReadCoils, ReadHoldingRegisters, ReadInputRegisters, WriteMultipleCoils, ReadFIFOQueue:
 	case WriteSingleCoil, WriteSingleRegister:
 		if len(mt.Write) <= 0 {
 			return fmt.Errorf("there is no data to write")
 		}
+	case MaskWriteRegister:
+		if mt.AndMask == nil && mt.OrMask == nil {
+			return fmt.Errorf("andMask and orMask is nil")
+		}
+	case ReadWriteMultipleRegisters:
+		if mt.WriteAddress == nil {
+			return fmt.Errorf("writeAddress is nil")
+		}
+		if len(mt.Write) <= 0 {
+			return fmt.Errorf("there is no data to write")
+		}
 	}
 
 	// переделываем формат ошибки
@@ -183,6 +323,95 @@ func (mt *ModbusMasterTest) Validation() error {
 	return nil
 }
 
+// byRegisters - тест задан точками карты регистров
+func (mt *ModbusMasterTest) byRegisters() bool {
+	return len(mt.Read) > 0 || mt.ExpectedPoints != nil
+}
+
+// resolve - заменяет тест по карте регистров на обычные тесты, по одному на запрос чтения.
+// Без read читаются точки из expected
+func (mt *ModbusMasterTest) resolve(m registers.Map) (tests []*ModbusMasterTest, errs []error) {
+	if mt.Function != "" || mt.Address != nil || mt.Quantity != nil {
+		errs = append(errs, common.NewFieldError("read", "function, address and quantity are computed from registers"))
+	}
+	if len(mt.Expected) > 0 {
+		errs = append(errs, common.NewFieldError("expected", "expected must be a map of registers"))
+	}
+
+	names := mt.Read
+	for i, name := range mt.Read {
+		if _, err := m.Get(name); err != nil {
+			errs = append(errs, common.NewFieldError(fmt.Sprintf("read.%d", i), "%s", err))
+		}
+	}
+	points := make([]string, 0, len(mt.ExpectedPoints))
+	for name := range mt.ExpectedPoints {
+		points = append(points, name)
+	}
+	sort.Strings(points)
+	for _, name := range points {
+		r, err := m.Get(name)
+		if err != nil {
+			errs = append(errs, common.NewFieldError("expected."+name, "%s", err))
+			continue
+		}
+		if len(mt.Read) == 0 {
+			names = append(names, name)
+		} else if !contains(mt.Read, name) {
+			errs = append(errs, common.NewFieldError("expected."+name, "register %s is not read", name))
+		}
+		if _, err := r.Value(name, mt.ExpectedPoints[name]); err != nil {
+			errs = append(errs, common.NewFieldError("expected."+name, "%s", err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	groups, err := m.Groups(names)
+	if err != nil {
+		return nil, []error{common.NewFieldError("read", "%s", err)}
+	}
+	for _, group := range groups {
+		test := *mt
+		test.Read, test.ExpectedPoints, test.Expected = nil, nil, nil
+		test.unchecked = make(map[*common.Value]bool)
+		test.Function = readFunction[group.Table]
+		address, quantity := group.Address, group.Quantity
+		test.Address, test.Quantity = &address, &quantity
+		if len(groups) > 1 {
+			test.Name = fmt.Sprintf("%s [%s]", mt.Name, strings.Join(group.Names, ", "))
+		}
+		for _, name := range group.Names {
+			expected := mt.ExpectedPoints[name]
+			v, _ := m[name].Value(name, expected)
+			if expected == nil {
+				test.unchecked[v] = true
+			}
+			test.Expected = append(test.Expected, v)
+		}
+		tests = append(tests, &test)
+	}
+	return tests, nil
+}
+
+// readFunction - функция чтения таблицы
+var readFunction = map[string]string{
+	registers.Coils:            "readCoils",
+	registers.DiscreteInput:    "readDiscreteInputs",
+	registers.HoldingRegisters: "readHoldingRegisters",
+	registers.InputRegisters:   "readInputRegisters",
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (mt *ModbusMasterTest) getFunction() ModbusFunction {
 	mFunc := strings.ReplaceAll(strings.ToLower(mt.Function), " ", "")
 	if strings.HasPrefix(mFunc, "0x") {
@@ -207,6 +436,14 @@ func (mt *ModbusMasterTest) getFunction() ModbusFunction {
 		return WriteSingleRegister
 	case "writemultipleregisters", "16":
 		return WriteMultipleRegisters
+	case "maskwriteregister", "22":
+		return MaskWriteRegister
+	case "readwritemultipleregisters", "23":
+		return ReadWriteMultipleRegisters
+	case "readfifoqueue", "24":
+		return ReadFIFOQueue
+	case "raw":
+		return RawFunction
 	default:
 		return NilFunction
 	}
@@ -224,7 +461,7 @@ func (mt *ModbusMasterTest) getQuantity() uint16 {
 		return common.CountBit(mt.Expected, false)
 	case WriteMultipleCoils:
 		return common.CountBit(mt.Write, false)
-	case ReadInputRegisters, ReadHoldingRegisters:
+	case ReadInputRegisters, ReadHoldingRegisters, ReadWriteMultipleRegisters:
 		return common.CountBit(mt.Expected, true)
 	case WriteMultipleRegisters:
 		return common.CountBit(mt.Write, true)
@@ -232,33 +469,38 @@ func (mt *ModbusMasterTest) getQuantity() uint16 {
 	return 0
 }
 
+// getWriteQuantity - количество записываемых регистров для ReadWriteMultipleRegisters
+func (mt *ModbusMasterTest) getWriteQuantity() uint16 {
+	if mt.WriteQuantity != nil {
+		return *mt.WriteQuantity
+	}
+	return common.CountBit(mt.Write, true)
+}
+
+// getMasks - маски для MaskWriteRegister. Не заданная маска не изменяет регистр
+func (mt *ModbusMasterTest) getMasks() (andMask uint16, orMask uint16) {
+	andMask = 0xFFFF
+	if mt.AndMask != nil {
+		andMask = *mt.AndMask
+	}
+	if mt.OrMask != nil {
+		orMask = *mt.OrMask
+	}
+	return
+}
+
+// getFunctionCode - код функции передаваемый устройству
+func (mt *ModbusMasterTest) getFunctionCode() byte {
+	if mt.getFunction() == RawFunction {
+		return mt.FunctionCode
+	}
+	return byte(mt.getFunction())
+}
+
 func (mt *ModbusMasterTest) getError(expected string) *string {
 	if mt.getFunction() != NilFunction {
-		modbusError := strings.ReplaceAll(strings.ToLower(expected), " ", "")
-		if strings.HasPrefix(modbusError, "0x") {
-			if a, err := strconv.ParseInt(strings.TrimPrefix(modbusError, "0x"), 16, 8); err == nil {
-				modbusError = strconv.Itoa(int(a))
-			}
-		}
-		switch modbusError {
-		case "illegalfunction", "1":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 1}).Error()
-		case "illegaldataaddress", "2":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 2}).Error()
-		case "illegaldatavalue", "3":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 3}).Error()
-		case "serverdevicefailure", "4":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 4}).Error()
-		case "acknowledge", "5":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 5}).Error()
-		case "serverdevicebusy", "6":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 6}).Error()
-		case "memoryparityerror", "8":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 8}).Error()
-		case "gatewaypathunavailable", "10":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 10}).Error()
-		case "gatewaytargetdevicefailedtorespond", "11":
-			expected = (&modbus.ModbusError{FunctionCode: byte(mt.getFunction()) | 1<<7, ExceptionCode: 11}).Error()
+		if code, ok := ExceptionCode(expected); ok {
+			expected = (&modbus.ModbusError{FunctionCode: mt.getFunctionCode() | 1<<7, ExceptionCode: code}).Error()
 		}
 	}
 	return &expected
@@ -88,6 +88,29 @@ func TestModbusTest_Check(t *testing.T) {
 	}
 }
 
+func TestModbusTest_CheckWordOrder(t *testing.T) {
+	var voltage float32 = 230.5
+	var energy uint32 = 0x0a0b0c0d
+	modbus := &ModbusMasterTest{
+		Name:      "Test",
+		Function:  "ReadHoldingRegisters",
+		WordOrder: common.CDAB,
+		Expected: []*common.Value{
+			{Name: "voltage", Float32: &voltage},
+			{Name: "energy", Uint32: &energy, WordOrder: common.DCBA},
+		},
+	}
+	got := common.CDAB.Reorder([]byte{0x43, 0x66, 0x80, 0x00})
+	report := ReportMasterTest{GotByte: append(got, 0x0d, 0x0c, 0x0b, 0x0a)}
+	modbus.Check(&report)
+
+	for _, expected := range report.Expected {
+		if err := gotest.Expect(expected.Pass).True(); err != nil {
+			t.Error(expected.Name, err)
+		}
+	}
+}
+
 func TestModbusTest_ExecReadCoils(t *testing.T) {
 	var Address uint16 = 0
 	var Quantity uint16 = 2
@@ -402,6 +425,118 @@ func TestModbusTest_ExecWriteMultipleRegisters(t *testing.T) {
 	}
 }
 
+func TestModbusTest_ExecMaskWriteRegister(t *testing.T) {
+	var Address uint16 = 4
+	var OrMask uint16 = 0x0025
+	modbus := &ModbusMasterTest{
+		Name:     "Test",
+		Function: "Mask Write Register",
+		Address:  &Address,
+		OrMask:   &OrMask,
+	}
+
+	client := NewFixtureModBusClient([]byte{0xff, 0xff, 0x00, 0x25}, nil)
+	report := ReportMasterTest{}
+	modbus.Exec(client, &report)
+
+	if err := gotest.Expect(report.GotByte).Eq([]byte{0xff, 0xff, 0x00, 0x25}); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(client.Address).Eq(Address); err != nil {
+		t.Error(err)
+	}
+
+	// Не заданная маска and не изменяет регистр
+	if err := gotest.Expect(client.AndMask).Eq(uint16(0xffff)); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(client.OrMask).Eq(OrMask); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(len(report.Write)).Eq(2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusTest_ExecReadWriteMultipleRegisters(t *testing.T) {
+	var param1 uint16 = 0x0102
+	var param2 uint32 = 0x03040506
+	var Address uint16 = 1
+	var WriteAddress uint16 = 10
+	modbus := &ModbusMasterTest{
+		Name:         "Test",
+		Function:     "0x17",
+		Address:      &Address,
+		WriteAddress: &WriteAddress,
+		Write: []*common.Value{
+			{Name: "param1", Uint16: &param1},
+		},
+		Expected: []*common.Value{
+			{Name: "param2", Uint32: &param2},
+		},
+	}
+
+	client := NewFixtureModBusClient([]byte{0x03, 0x04, 0x05, 0x06}, nil)
+	report := ReportMasterTest{Pass: true}
+	modbus.Exec(client, &report)
+	modbus.Check(&report)
+
+	if err := gotest.Expect(client.Address).Eq(Address); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(client.Quantity).Eq(uint16(2)); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(client.WriteAddress).Eq(WriteAddress); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(client.WriteQuantity).Eq(uint16(1)); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(client.Value).Eq([]byte{0x01, 0x02}); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(report.Pass && report.Expected[0].Pass).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusTest_ExecReadFIFOQueue(t *testing.T) {
+	var param1 uint16 = 0x01b8
+	var param2 uint16 = 0x1284
+	var Address uint16 = 0x04de
+	modbus := &ModbusMasterTest{
+		Name:     "Test",
+		Function: "ReadFIFOQueue",
+		Address:  &Address,
+		Expected: []*common.Value{
+			{Name: "param1", Uint16: &param1},
+			{Name: "param2", Uint16: &param2},
+		},
+	}
+
+	client := NewFixtureModBusClient([]byte{0x01, 0xb8, 0x12, 0x84}, nil)
+	report := ReportMasterTest{Pass: true}
+	modbus.Exec(client, &report)
+	modbus.Check(&report)
+
+	if err := gotest.Expect(client.Address).Eq(Address); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(report.Pass).True(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestModbusTest_getFunction(t *testing.T) {
 
 	if err := gotest.Expect((&ModbusMasterTest{Function: "0x01"}).getFunction()).Eq(ReadCoils); err != nil {
@@ -447,6 +582,18 @@ func TestModbusTest_getFunction(t *testing.T) {
 	if err := gotest.Expect((&ModbusMasterTest{Function: "WriteMultipleRegisters"}).getFunction()).Eq(WriteMultipleRegisters); err != nil {
 		t.Error(err)
 	}
+
+	if err := gotest.Expect((&ModbusMasterTest{Function: "MaskWriteRegister"}).getFunction()).Eq(MaskWriteRegister); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect((&ModbusMasterTest{Function: "0x17"}).getFunction()).Eq(ReadWriteMultipleRegisters); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect((&ModbusMasterTest{Function: "24"}).getFunction()).Eq(ReadFIFOQueue); err != nil {
+		t.Error(err)
+	}
 }
 
 func TestModbusTest_getQuantity(t *testing.T) {
@@ -478,3 +625,18 @@ func TestModbusTest_getError(t *testing.T) {
 	}
 
 }
+
+func TestExceptionCode(t *testing.T) {
+	for name, code := range map[string]byte{"Server Device Busy": 6, "0x0B": 11, "2": 2} {
+		got, ok := ExceptionCode(name)
+		if err := gotest.Expect(ok).True(); err != nil {
+			t.Error(err)
+		}
+		if err := gotest.Expect(got).Eq(code); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, ok := ExceptionCode("busy"); ok {
+		t.Error("expected unknown exception")
+	}
+}
@@ -5,53 +5,226 @@ import (
 	"github.com/goburrow/modbus"
 	"github.com/sirupsen/logrus"
 	"log"
+	"rtu-test/e2e/capture"
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/registers"
 	"rtu-test/e2e/template"
+	"sort"
 	"strings"
 )
 
+const (
+	ModeRTU   = "rtu"
+	ModeTCP   = "tcp"
+	ModeASCII = "ascii"
+)
+
 type ModbusMaster struct {
-	SlaveId   uint8                          `yaml:"slaveId"`
-	Port      string                         `yaml:"port"`
-	BoundRate int                            `yaml:"boundRate"`
-	DataBits  int                            `yaml:"dataBits"`
-	Parity    string                         `yaml:"parity"`
-	StopBits  int                            `yaml:"stopBits"`
-	Timeout   string                         `yaml:"timeout"`
-	Filter    string                         `yaml:"filter"`
+	// rtu (по умолчанию), tcp или ascii
+	Mode    string `yaml:"mode"`
+	SlaveId uint8  `yaml:"slaveId"`
+	// Для rtu и ascii последовательный порт, для tcp host:port
+	Port           string `yaml:"port"`
+	BoundRate      int    `yaml:"boundRate"`
+	DataBits       int    `yaml:"dataBits"`
+	Parity         string `yaml:"parity"`
+	StopBits       int    `yaml:"stopBits"`
+	Timeout        string `yaml:"timeout"`
+	ConnectTimeout string `yaml:"connectTimeout"`
+	Filter         string `yaml:"filter"`
+	// Запись трафика: pcap=path или hex=path
+	Capture string `yaml:"capture"`
+	// Порядок слов 32 и 64 битных значений: ABCD (по умолчанию), CDAB, BADC или DCBA
+	WordOrder common.WordOrder `yaml:"wordOrder"`
+	// Группы выполняются первыми в заданном порядке, остальные в порядке файла
+	Order []string `yaml:"order"`
+	// Группа пропускается, если не прошла группа от которой она зависит
+	DependsOn map[string][]string            `yaml:"dependsOn"`
 	Tests     map[string][]*ModbusMasterTest `yaml:"tests"`
+
+	// Лог исполнителя. По умолчанию стандартный лог logrus
+	Log logrus.FieldLogger `yaml:"-"`
+
+	// Порядок групп в файле конфигурации
+	groupOrder []string
+}
+
+// clientHandler - общий интерфейс обработчиков rtu, tcp и ascii
+type clientHandler interface {
+	modbus.ClientHandler
+	Connect() error
+	Close() error
 }
 
 type loger struct {
+	log logrus.FieldLogger
 }
 
 func (l *loger) Write(p []byte) (n int, err error) {
-	logrus.Debug(strings.TrimPrefix(string(p), "modbus: "))
+	l.log.Debug(strings.TrimPrefix(string(p), "modbus: "))
 	return len(p), nil
 }
 
 // TODO test
-func (mc *ModbusMaster) getHandler() *modbus.RTUClientHandler {
-	handler := modbus.NewRTUClientHandler(mc.Port)
-	handler.BaudRate = mc.BoundRate
-	handler.DataBits = mc.DataBits
-	handler.Parity = mc.Parity
-	handler.StopBits = mc.StopBits
-	handler.SlaveId = mc.SlaveId
-	handler.Timeout = common.ParseDuration(mc.Timeout)
-	handler.IdleTimeout = common.ParseDuration(mc.Timeout)
-	handler.Logger = log.New(&loger{}, "", 0)
-	return handler
+func (mc *ModbusMaster) getHandler() clientHandler {
+	switch strings.ToLower(mc.Mode) {
+	case ModeTCP:
+		handler := modbus.NewTCPClientHandler(mc.Port)
+		handler.SlaveId = mc.SlaveId
+		handler.Timeout = common.ParseDuration(mc.Timeout)
+		handler.IdleTimeout = common.ParseDuration(mc.Timeout)
+		handler.Logger = log.New(&loger{log: mc.logger()}, "", 0)
+		return handler
+	case ModeASCII:
+		handler := modbus.NewASCIIClientHandler(mc.Port)
+		handler.BaudRate = mc.BoundRate
+		handler.DataBits = mc.DataBits
+		handler.Parity = mc.Parity
+		handler.StopBits = mc.StopBits
+		handler.SlaveId = mc.SlaveId
+		handler.Timeout = common.ParseDuration(mc.Timeout)
+		handler.IdleTimeout = common.ParseDuration(mc.Timeout)
+		handler.Logger = log.New(&loger{log: mc.logger()}, "", 0)
+		return handler
+	default:
+		handler := modbus.NewRTUClientHandler(mc.Port)
+		handler.BaudRate = mc.BoundRate
+		handler.DataBits = mc.DataBits
+		handler.Parity = mc.Parity
+		handler.StopBits = mc.StopBits
+		handler.SlaveId = mc.SlaveId
+		handler.Timeout = common.ParseDuration(mc.Timeout)
+		handler.IdleTimeout = common.ParseDuration(mc.Timeout)
+		handler.Logger = log.New(&loger{log: mc.logger()}, "", 0)
+		return handler
+	}
+}
+
+// newClient - клиент обработчика с записью кадров
+func (mc *ModbusMaster) newClient(handler clientHandler, traffic capture.Capture) RawClient {
+	client := &rawClient{handler: handler}
+	if traffic != nil {
+		client.handler = &captureHandler{clientHandler: handler, capture: traffic}
+	}
+	client.Client = modbus.NewClient(client.handler)
+	return client
+}
+
+// connect - открывает соединение. Для tcp учитывается таймаут подключения
+func (mc *ModbusMaster) connect(handler clientHandler) error {
+	tcp, ok := handler.(*modbus.TCPClientHandler)
+	if !ok || mc.ConnectTimeout == "" {
+		return handler.Connect()
+	}
+	timeout := tcp.Timeout
+	tcp.Timeout = common.ParseDuration(mc.ConnectTimeout)
+	defer func() { tcp.Timeout = timeout }()
+	return tcp.Connect()
+}
+
+func (mc *ModbusMaster) logger() logrus.FieldLogger {
+	if mc.Log == nil {
+		return logrus.StandardLogger()
+	}
+	return mc.Log
+}
+
+// setSlaveId - подменяет адрес устройства в обработчике
+func setSlaveId(handler clientHandler, slaveId uint8) {
+	switch h := handler.(type) {
+	case *modbus.RTUClientHandler:
+		h.SlaveId = slaveId
+	case *modbus.TCPClientHandler:
+		h.SlaveId = slaveId
+	case *modbus.ASCIIClientHandler:
+		h.SlaveId = slaveId
+	case *serialHandler:
+		setSlaveId(h.clientHandler, slaveId)
+	}
+}
+
+// Validate - проверка настроек после загрузки конфигурации. Тесты проверяются отдельно
+func (mc *ModbusMaster) Validate() (errs []error) {
+	switch strings.ToLower(mc.Mode) {
+	case "", ModeRTU, ModeTCP, ModeASCII:
+	default:
+		errs = append(errs, common.NewFieldError("mode", "unknown mode %q", mc.Mode))
+	}
+	if _, err := mc.Groups(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := capture.Validate(mc.Capture); err != nil {
+		errs = append(errs, common.NewFieldError("capture", "%s", err))
+	}
+	if err := mc.WordOrder.Validate(); err != nil {
+		errs = append(errs, common.NewFieldError("wordOrder", "%s", err))
+	}
+	return errs
+}
+
+// Validation - проверяет тесты до подключения к устройству
+func (mc *ModbusMaster) Validation() error {
+	if _, err := mc.Groups(); err != nil {
+		return err
+	}
+	for group, tests := range mc.Tests {
+		for _, test := range tests {
+			if test.Skip != "" {
+				continue
+			}
+			if err := test.Validation(); err != nil {
+				return common.NewConfigError("test %s:%s: %s", group, test.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ResolveRegisters - заменяет тесты по карте регистров на запросы чтения с вычисленными
+// функцией, адресом и количеством
+func (mc *ModbusMaster) ResolveRegisters(m registers.Map) (errs []error) {
+	groups := make([]string, 0, len(mc.Tests))
+	for group := range mc.Tests {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		var resolved []*ModbusMasterTest
+		for i, test := range mc.Tests[group] {
+			if test == nil || !test.byRegisters() {
+				resolved = append(resolved, test)
+				continue
+			}
+			tests, testErrs := test.resolve(m)
+			errs = append(errs, common.PrefixErrors(fmt.Sprintf("tests.%s.%d", group, i), testErrs)...)
+			resolved = append(resolved, tests...)
+		}
+		mc.Tests[group] = resolved
+	}
+	return errs
 }
 
 // TODO Test
 func (mc *ModbusMaster) Run(reports *ReportGroups) error {
-	handler := mc.getHandler()
-	if err := handler.Connect(); err != nil {
-		return fmt.Errorf("open %s: %s", handler.Address, err)
+	if err := mc.Validation(); err != nil {
+		return err
+	}
+	// Порт rtu и ascii открывается один раз на все запросы, включая raw
+	handler := ownSerialPort(mc.getHandler())
+	if err := mc.connect(handler); err != nil {
+		return fmt.Errorf("open %s: %s", mc.Port, err)
 	}
 	defer handler.Close()
-	client := modbus.NewClient(handler)
+	var traffic capture.Capture
+	if mc.Capture != "" {
+		c, err := capture.Open(mc.Capture, CaptureLink(mc.Mode))
+		if err != nil {
+			return common.NewConfigError("open capture: %s", err)
+		}
+		defer c.Close()
+		traffic = c
+	}
+	client := mc.newClient(handler, traffic)
 
 	filterGroup := ""
 	filterTest := ""
@@ -63,12 +236,27 @@ func (mc *ModbusMaster) Run(reports *ReportGroups) error {
 		filterGroup = filter[0]
 	}
 
-	for group, tests := range mc.Tests {
+	groups, err := mc.Groups()
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
 		if filterGroup != "" && filterGroup != "all" && filterGroup != group {
 			continue
 		}
+		tests := mc.Tests[group]
 		report := ReportGroup{Name: group}
-		logrus.Warnf(common.Render(template.TestMasterModBusGROUP, report))
+		mc.logger().Warnf(common.Render(template.TestMasterModBusGROUP, report))
+		if report.Skip = mc.skipReason(group, reports); report.Skip != "" {
+			for _, test := range tests {
+				testReport := ReportMasterTest{Name: test.Name, Pass: true, Skip: report.Skip}
+				mc.logger().Warn(common.Render(template.TestMasterModBusSKIP, testReport))
+				report.Tests = append(report.Tests, testReport)
+			}
+			reports.ReportGroup = append(reports.ReportGroup, report)
+			continue
+		}
 		for _, test := range tests {
 			if filterTest != "" && filterTest != "all" && filterTest != test.Name {
 				continue
@@ -76,16 +264,25 @@ func (mc *ModbusMaster) Run(reports *ReportGroups) error {
 			// Подменяем адрес, если он переопределен в тесте
 			// Необходимо для управления несколькими устройствами на 1 шине
 			if test.SlaveId != 0 {
-				handler.SlaveId = test.SlaveId
+				setSlaveId(handler, test.SlaveId)
 			}
-			report.Tests = append(report.Tests, test.Run(client))
+			test.Log = mc.logger()
+			test.WordOrder = mc.WordOrder
+			testReport := test.Run(client)
+			report.Tests = append(report.Tests, testReport)
 			// Возвращаем адрес по умолчанию
-			handler.SlaveId = mc.SlaveId
+			setSlaveId(handler, mc.SlaveId)
 
 			// При необходимости закрываем порт
 			if test.Disconnect {
 				handler.Close()
 			}
+
+			// Фатальная ошибка прекращает выполнение тестов
+			if !testReport.Pass && test.Fatal != "" {
+				reports.ReportGroup = append(reports.ReportGroup, report)
+				return nil
+			}
 		}
 		reports.ReportGroup = append(reports.ReportGroup, report)
 	}
@@ -0,0 +1,270 @@
+package master
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/goburrow/modbus"
+	"github.com/schnack/gotest"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/registers"
+	"strings"
+	"testing"
+)
+
+// serveTCP - простой modbus tcp сервер отвечающий на чтение holding регистров значением register
+func serveTCP(t *testing.T, register uint16) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header := make([]byte, 7)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			pdu := make([]byte, binary.BigEndian.Uint16(header[4:6])-1)
+			if _, err := io.ReadFull(conn, pdu); err != nil {
+				return
+			}
+			quantity := binary.BigEndian.Uint16(pdu[3:5])
+			response := []byte{pdu[0], byte(quantity * 2)}
+			for i := uint16(0); i < quantity; i++ {
+				response = append(response, byte(register>>8), byte(register))
+			}
+			binary.BigEndian.PutUint16(header[4:6], uint16(len(response)+1))
+			if _, err := conn.Write(append(header, response...)); err != nil {
+				return
+			}
+		}
+	}()
+	return listener
+}
+
+func TestModbusMaster_RunTCP(t *testing.T) {
+	listener := serveTCP(t, 0x0102)
+	defer listener.Close()
+
+	var address uint16 = 0
+	var param uint16 = 0x0102
+	var errorString = ""
+	mc := &ModbusMaster{
+		Mode:           ModeTCP,
+		SlaveId:        1,
+		Port:           listener.Addr().String(),
+		Timeout:        "1s",
+		ConnectTimeout: "1s",
+		Tests: map[string][]*ModbusMasterTest{
+			"Default": {
+				{
+					Name:     "Test",
+					Function: "ReadHoldingRegisters",
+					Address:  &address,
+					Expected: []*common.Value{
+						{Name: "error", Error: &errorString},
+						{Name: "param", Uint16: &param},
+					},
+				},
+			},
+		},
+	}
+
+	reports := ReportGroups{}
+	if err := gotest.Expect(mc.Run(&reports)).NotError(); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(len(reports.ReportGroup)).Eq(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gotest.Expect(reports.ReportGroup[0].Tests[0].GotByte).Eq([]byte{0x01, 0x02}); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(reports.ReportGroup[0].Tests[0].Pass).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusMaster_RunTCPConnectError(t *testing.T) {
+	listener := serveTCP(t, 0)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	mc := &ModbusMaster{Mode: ModeTCP, Port: addr, ConnectTimeout: "100ms"}
+	if err := gotest.Expect(mc.Run(&ReportGroups{})).NotNil(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusMaster_getHandler(t *testing.T) {
+	mc := ModbusMaster{Mode: "ASCII", Port: "/dev/ttyUSB0", SlaveId: 5}
+	handler, ok := mc.getHandler().(*modbus.ASCIIClientHandler)
+	if err := gotest.Expect(ok).True(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(handler.SlaveId).Eq(uint8(5)); err != nil {
+		t.Error(err)
+	}
+
+	setSlaveId(handler, 7)
+	if err := gotest.Expect(handler.SlaveId).Eq(uint8(7)); err != nil {
+		t.Error(err)
+	}
+
+	mc.Mode = ModeTCP
+	if _, ok := mc.getHandler().(*modbus.TCPClientHandler); !ok {
+		t.Error("expected tcp handler")
+	}
+
+	mc.Mode = ""
+	if _, ok := mc.getHandler().(*modbus.RTUClientHandler); !ok {
+		t.Error("expected rtu handler")
+	}
+}
+
+func TestModbusMaster_RunValidation(t *testing.T) {
+	mc := &ModbusMaster{Mode: ModeTCP, Port: "127.0.0.1:0", Tests: map[string][]*ModbusMasterTest{
+		"Default": {{Name: "no address", Function: "ReadHoldingRegisters"}},
+	}}
+
+	err := mc.Run(&ReportGroups{})
+	var configError *common.ConfigError
+	if err := gotest.Expect(errors.As(err, &configError)).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusMaster_RunFatal(t *testing.T) {
+	listener := serveTCP(t, 0x0001)
+	defer listener.Close()
+
+	var expected uint16 = 0x0002
+	var address uint16 = 0
+	mc := &ModbusMaster{Mode: ModeTCP, SlaveId: 1, Port: listener.Addr().String(), Timeout: "1s", Tests: map[string][]*ModbusMasterTest{
+		"Default": {
+			{Name: "fatal", Function: "ReadHoldingRegisters", Address: &address, Fatal: "stop", Expected: []*common.Value{{Name: "param", Uint16: &expected}}},
+			{Name: "next", Function: "ReadHoldingRegisters", Address: &address, Expected: []*common.Value{{Name: "param", Uint16: &expected}}},
+		},
+	}}
+
+	reports := ReportGroups{}
+	if err := gotest.Expect(mc.Run(&reports)).Nil(); err != nil {
+		t.Error(err)
+	}
+	// После фатальной ошибки тесты не выполняются
+	if err := gotest.Expect(len(reports.ReportGroup[0].Tests)).Eq(1); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(reports.Pass()).False(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusMaster_RunCapture(t *testing.T) {
+	listener := serveTCP(t, 0x0102)
+	defer listener.Close()
+
+	var address uint16 = 0
+	var param uint16 = 0x0102
+	path := filepath.Join(t.TempDir(), "traffic.log")
+	mc := &ModbusMaster{Mode: ModeTCP, SlaveId: 1, Port: listener.Addr().String(), Timeout: "1s", Capture: "hex=" + path,
+		Tests: map[string][]*ModbusMasterTest{
+			"Default": {{Name: "Test", Function: "ReadHoldingRegisters", Address: &address, Expected: []*common.Value{{Name: "param", Uint16: &param}}}},
+		}}
+	if err := gotest.Expect(mc.Run(&ReportGroups{})).Nil(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Запрос и ответ записываются вместе с заголовком mbap
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if err := gotest.Expect(len(lines)).Eq(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(strings.HasSuffix(lines[0], "TX 00 01 00 00 00 06 01 03 00 00 00 01")).True(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(strings.HasSuffix(lines[1], "RX 00 01 00 00 00 05 01 03 02 01 02")).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusMaster_ResolveRegisters(t *testing.T) {
+	var m registers.Map
+	if err := yaml.Unmarshal([]byte(`
+voltage: {address: 0x0010, type: float32, unit: V}
+current: {address: 0x0012, type: float32}
+status: {address: 0x0020}
+`), &m); err != nil {
+		t.Fatal(err)
+	}
+	var mc ModbusMaster
+	if err := yaml.Unmarshal([]byte(`
+tests:
+  Default:
+    - name: measure
+      read: [status, voltage, current]
+      expected:
+        voltage: {min: 220, max: 240}
+    - name: status
+      expected: {status: 1}
+`), &mc); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(len(mc.ResolveRegisters(m))).Eq(0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := mc.Tests["Default"]
+	var names []string
+	for _, test := range tests {
+		names = append(names, test.Name)
+	}
+	if err := gotest.Expect(names).Eq([]string{"measure [voltage, current]", "measure [status]", "status"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect([]interface{}{tests[0].getFunction(), *tests[0].Address, tests[0].getQuantity()}).
+		Eq([]interface{}{ReadHoldingRegisters, uint16(0x0010), uint16(4)}); err != nil {
+		t.Error(err)
+	}
+
+	// Точка без ожидаемого значения выводится в отчет без проверки
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data, math.Float32bits(230))
+	binary.BigEndian.PutUint32(data[4:], math.Float32bits(1.5))
+	report := tests[0].Run(NewFixtureModBusClient(data, nil))
+	if err := gotest.Expect(report.Pass).True(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect([]string{report.Expected[1].Name, report.Expected[1].Got, report.Expected[1].Expected}).
+		Eq([]string{"current", "1.500000", ""}); err != nil {
+		t.Error(err)
+	}
+	report = tests[2].Run(NewFixtureModBusClient([]byte{0x00, 0x02}, nil))
+	if err := gotest.Expect(report.Pass).False(); err != nil {
+		t.Error(err)
+	}
+
+	mc = ModbusMaster{Tests: map[string][]*ModbusMasterTest{
+		"Default": {{Name: "bad", Read: []string{"frequency"}, Function: "readCoils"}},
+	}}
+	if err := gotest.Expect(len(mc.ResolveRegisters(m))).Eq(2); err != nil {
+		t.Error(err)
+	}
+}
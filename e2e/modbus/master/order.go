@@ -0,0 +1,115 @@
+package master
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"rtu-test/e2e/common"
+	"sort"
+)
+
+// UnmarshalYAML - дополнительно запоминает порядок групп тестов в файле
+func (mc *ModbusMaster) UnmarshalYAML(value *yaml.Node) error {
+	type modbusMaster ModbusMaster
+	if err := value.Decode((*modbusMaster)(mc)); err != nil {
+		return err
+	}
+	mc.groupOrder = YamlKeys(value, "tests")
+	return nil
+}
+
+// YamlKeys - ключи вложенного словаря name в порядке следования в файле
+func YamlKeys(value *yaml.Node, name string) (keys []string) {
+	if value.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value != name || value.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		tests := value.Content[i+1]
+		for j := 0; j < len(tests.Content); j += 2 {
+			keys = append(keys, tests.Content[j].Value)
+		}
+	}
+	return keys
+}
+
+// Groups - порядок выполнения групп тестов.
+// Сначала группы из order, затем в порядке файла. Зависимости из dependsOn выполняются раньше зависимой группы
+func (mc *ModbusMaster) Groups() ([]string, error) {
+	var names []string
+	added := make(map[string]bool)
+	add := func(list []string) {
+		for _, name := range list {
+			if _, ok := mc.Tests[name]; ok && !added[name] {
+				added[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range mc.Order {
+		if _, ok := mc.Tests[name]; !ok {
+			return nil, common.NewConfigError("order: group %s not found", name)
+		}
+	}
+	add(mc.Order)
+	add(mc.groupOrder)
+	// Группы заданные без файла конфигурации
+	var other []string
+	for name := range mc.Tests {
+		other = append(other, name)
+	}
+	sort.Strings(other)
+	add(other)
+
+	for group, depends := range mc.DependsOn {
+		if _, ok := mc.Tests[group]; !ok {
+			return nil, common.NewConfigError("dependsOn: group %s not found", group)
+		}
+		for _, name := range depends {
+			if _, ok := mc.Tests[name]; !ok {
+				return nil, common.NewConfigError("dependsOn: group %s not found", name)
+			}
+		}
+	}
+
+	// Переставляем зависимости перед зависимыми группами
+	var result []string
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return common.NewConfigError("dependsOn: cycle %v", append(path, name))
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, depend := range mc.DependsOn[name] {
+			if err := visit(depend, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		result = append(result, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// skipReason - причина пропуска группы, если группа от которой она зависит не прошла
+func (mc *ModbusMaster) skipReason(group string, reports *ReportGroups) string {
+	for _, depend := range mc.DependsOn[group] {
+		for _, report := range reports.ReportGroup {
+			if report.Name == depend && !report.Pass() {
+				return fmt.Sprintf("group %s depends on failed group %s", group, depend)
+			}
+		}
+	}
+	return ""
+}
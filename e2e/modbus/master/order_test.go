@@ -0,0 +1,91 @@
+package master
+
+import (
+	"errors"
+	"github.com/schnack/gotest"
+	"gopkg.in/yaml.v3"
+	"rtu-test/e2e/common"
+	"testing"
+)
+
+func TestModbusMaster_Groups(t *testing.T) {
+	config := `
+order: [Init]
+dependsOn:
+  Measure: [Calibrate]
+tests:
+  Measure:
+    - name: m
+  Finish:
+    - name: f
+  Calibrate:
+    - name: c
+  Init:
+    - name: i
+`
+	mc := ModbusMaster{}
+	if err := gotest.Expect(yaml.Unmarshal([]byte(config), &mc)).Nil(); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := mc.Groups()
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(groups).Eq([]string{"Init", "Calibrate", "Measure", "Finish"}); err != nil {
+		t.Error(err)
+	}
+
+	// Циклическая зависимость
+	mc.DependsOn["Calibrate"] = []string{"Measure"}
+	_, err = mc.Groups()
+	var configError *common.ConfigError
+	if err := gotest.Expect(errors.As(err, &configError)).True(); err != nil {
+		t.Error(err)
+	}
+
+	// Неизвестная группа
+	mc.DependsOn = map[string][]string{"Measure": {"Unknown"}}
+	_, err = mc.Groups()
+	if err := gotest.Expect(err).NotNil(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusMaster_RunDependsOn(t *testing.T) {
+	listener := serveTCP(t, 0x0001)
+	defer listener.Close()
+
+	var ok uint16 = 0x0001
+	var fail uint16 = 0x0002
+	var address uint16 = 0
+	mc := &ModbusMaster{Mode: ModeTCP, SlaveId: 1, Port: listener.Addr().String(), Timeout: "1s",
+		DependsOn: map[string][]string{"Measure": {"Init"}, "Report": {"Measure"}},
+		Tests: map[string][]*ModbusMasterTest{
+			"Report":  {{Name: "report", Function: "ReadHoldingRegisters", Address: &address, Expected: []*common.Value{{Name: "param", Uint16: &ok}}}},
+			"Measure": {{Name: "measure", Function: "ReadHoldingRegisters", Address: &address, Expected: []*common.Value{{Name: "param", Uint16: &ok}}}},
+			"Init":    {{Name: "init", Function: "ReadHoldingRegisters", Address: &address, Expected: []*common.Value{{Name: "param", Uint16: &fail}}}},
+		},
+	}
+
+	reports := ReportGroups{}
+	if err := gotest.Expect(mc.Run(&reports)).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(len(reports.ReportGroup)).Eq(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(reports.ReportGroup[0].Name).Eq("Init"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(reports.ReportGroup[1].Skip).Eq("group Measure depends on failed group Init"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(reports.ReportGroup[1].Tests[0].Skip).Eq(reports.ReportGroup[1].Skip); err != nil {
+		t.Error(err)
+	}
+	// Пропуск распространяется по цепочке зависимостей
+	if err := gotest.Expect(reports.ReportGroup[2].Skip).Eq("group Report depends on failed group Measure"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,50 @@
+package master
+
+import (
+	"fmt"
+	"github.com/goburrow/modbus"
+)
+
+// RawClient - modbus клиент с возможностью отправить произвольный pdu.
+// Нужен для функций производителя и диагностики, которых нет в modbus.Client
+type RawClient interface {
+	modbus.Client
+	// SendPdu - отправляет pdu и возвращает данные ответа без кода функции
+	SendPdu(functionCode byte, data []byte) ([]byte, error)
+}
+
+func NewRawClient(handler modbus.ClientHandler) RawClient {
+	return &rawClient{Client: modbus.NewClient(handler), handler: handler}
+}
+
+type rawClient struct {
+	modbus.Client
+	handler modbus.ClientHandler
+}
+
+func (rc *rawClient) SendPdu(functionCode byte, data []byte) ([]byte, error) {
+	request := &modbus.ProtocolDataUnit{FunctionCode: functionCode, Data: data}
+	aduRequest, err := rc.handler.Encode(request)
+	if err != nil {
+		return nil, err
+	}
+	aduResponse, err := rc.handler.Send(aduRequest)
+	if err != nil {
+		return nil, err
+	}
+	if err := rc.handler.Verify(aduRequest, aduResponse); err != nil {
+		return nil, err
+	}
+	response, err := rc.handler.Decode(aduResponse)
+	if err != nil {
+		return nil, err
+	}
+	if response.FunctionCode != functionCode {
+		// Ответ с исключением
+		if response.FunctionCode == functionCode|0x80 && len(response.Data) > 0 {
+			return nil, &modbus.ModbusError{FunctionCode: response.FunctionCode, ExceptionCode: response.Data[0]}
+		}
+		return nil, fmt.Errorf("modbus: response function code '%v' does not match request '%v'", response.FunctionCode, functionCode)
+	}
+	return response.Data, nil
+}
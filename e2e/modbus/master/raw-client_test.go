@@ -0,0 +1,164 @@
+package master
+
+import (
+	"encoding/binary"
+	"github.com/goburrow/modbus"
+	"github.com/schnack/gotest"
+	"github.com/schnack/mbslave"
+	"os"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/transport"
+	"testing"
+	"time"
+)
+
+// stubHandler - tcp обработчик отвечающий заранее заданным pdu
+type stubHandler struct {
+	*modbus.TCPClientHandler
+	response []byte
+}
+
+func (sh *stubHandler) Send(aduRequest []byte) ([]byte, error) {
+	adu := append([]byte{}, aduRequest[:7]...)
+	binary.BigEndian.PutUint16(adu[4:], uint16(len(sh.response)+1))
+	return append(adu, sh.response...), nil
+}
+
+func TestRawClient_SendPdu(t *testing.T) {
+	handler := &stubHandler{TCPClientHandler: modbus.NewTCPClientHandler("127.0.0.1:0"), response: []byte{0x41, 0xaa, 0xbb}}
+	client := NewRawClient(handler)
+
+	data, err := client.SendPdu(0x41, []byte{0x01})
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(data).Eq([]byte{0xaa, 0xbb}); err != nil {
+		t.Error(err)
+	}
+
+	// Исключение сопоставляется с ошибкой из getError
+	handler.response = []byte{0xc1, 0x01}
+	_, err = client.SendPdu(0x41, []byte{0x01})
+	if err := gotest.Expect(err).NotNil(); err != nil {
+		t.Fatal(err)
+	}
+	test := &ModbusMasterTest{Function: "raw", FunctionCode: 0x41}
+	if err := gotest.Expect(err.Error()).Eq(*test.getError("illegal function")); err != nil {
+		t.Error(err)
+	}
+}
+
+// rtuFrame - adu rtu с crc
+func rtuFrame(pdu ...byte) []byte {
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, mbslave.CalcCRC(pdu))
+	return append(pdu, crc...)
+}
+
+func TestRawClient_SendPduRtu(t *testing.T) {
+	path, err := transport.ResolvePort("virtual://raw-rtu")
+	if err != nil {
+		t.Skipf("pty is not available: %s", err)
+	}
+	defer transport.CloseVirtual()
+	peerPath, err := transport.ResolvePort("virtual://raw-rtu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := os.OpenFile(peerPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	// Устройство отвечает на функцию производителя 0x41 и на диагностику 0x08 кадрами,
+	// длина которых неизвестна goburrow
+	responses := [][]byte{
+		rtuFrame(0x01, 0x41, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19),
+		rtuFrame(0x01, 0x08, 0x00, 0x00, 0xa5, 0x37),
+		append(rtuFrame(0x01, 0x41, 0xaa, 0xbb, 0xcc)[:5], 0x00, 0x00),
+	}
+	go func() {
+		buf := make([]byte, 256)
+		for _, response := range responses {
+			if _, err := peer.Read(buf); err != nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+			_, _ = peer.Write(response)
+		}
+	}()
+
+	mc := &ModbusMaster{SlaveId: 1, Port: path, BoundRate: 115200, DataBits: 8, Parity: "N", StopBits: 1, Timeout: "1s"}
+	handler := ownSerialPort(mc.getHandler())
+	if err := handler.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	client := mc.newClient(handler, nil)
+
+	data, err := client.SendPdu(0x41, []byte{0x01})
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(data).Eq([]byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19}); err != nil {
+		t.Error(err)
+	}
+
+	data, err = client.SendPdu(0x08, []byte{0x00, 0x00, 0xa5, 0x37})
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(data).Eq([]byte{0x00, 0x00, 0xa5, 0x37}); err != nil {
+		t.Error(err)
+	}
+
+	// Ответ с неверной crc не принимается
+	if _, err := client.SendPdu(0x41, []byte{0x01}); err == nil {
+		t.Error("response with wrong crc is accepted")
+	}
+}
+
+func TestModbusTest_ExecRaw(t *testing.T) {
+	var meiType uint8 = 0x0e
+	var readDeviceId uint8 = 0x01
+	var objectId uint8 = 0x00
+	var conformity uint8 = 0x81
+	modbus := &ModbusMasterTest{
+		Name:         "Test",
+		Function:     "raw",
+		FunctionCode: 0x2b,
+		Write: []*common.Value{
+			{Name: "meiType", Uint8: &meiType},
+			{Name: "readDeviceId", Uint8: &readDeviceId},
+			{Name: "objectId", Uint8: &objectId},
+		},
+		Expected: []*common.Value{
+			{Name: "meiType", Uint8: &meiType},
+			{Name: "readDeviceId", Uint8: &readDeviceId},
+			{Name: "conformity", Uint8: &conformity},
+		},
+	}
+	if err := gotest.Expect(modbus.Validation()).Nil(); err != nil {
+		t.Error(err)
+	}
+
+	client := NewFixtureModBusClient([]byte{0x0e, 0x01, 0x81, 0x00, 0x00, 0x00}, nil)
+	report := ReportMasterTest{Pass: true}
+	modbus.Exec(client, &report)
+	modbus.Check(&report)
+
+	if err := gotest.Expect(client.FunctionCode).Eq(byte(0x2b)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(client.Value).Eq([]byte{0x0e, 0x01, 0x00}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(report.Pass).True(); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect((&ModbusMasterTest{Function: "raw"}).Validation()).NotNil(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,8 @@
 package master
 
 import (
+	"encoding/json"
+	"fmt"
 	"rtu-test/e2e/common"
 	"time"
 )
@@ -18,15 +20,54 @@ type ReportMasterTest struct {
 	GotError string
 }
 
+// MarshalJSON - сырые данные и время выводятся в читаемом виде
+func (rt ReportMasterTest) MarshalJSON() ([]byte, error) {
+	type report ReportMasterTest
+	return json.Marshal(struct {
+		report
+		GotByte string
+		GotTime string
+	}{
+		report:  report(rt),
+		GotByte: fmt.Sprintf("% x", rt.GotByte),
+		GotTime: rt.GotTime.String(),
+	})
+}
+
 type ReportGroup struct {
 	Name  string
 	Pause string
+	// Причина пропуска группы
+	Skip  string
 	Tests []ReportMasterTest
 }
 
+// Pass - все выполненные тесты группы прошли успешно. Пропущенная группа не считается успешной
+func (rg *ReportGroup) Pass() bool {
+	if rg.Skip != "" {
+		return false
+	}
+	for _, test := range rg.Tests {
+		if test.Skip == "" && !test.Pass {
+			return false
+		}
+	}
+	return true
+}
+
 type ReportGroups struct {
 	Name        string
 	Description string
 	Pause       string
 	ReportGroup []ReportGroup
 }
+
+// Pass - все выполненные тесты прошли успешно
+func (rg *ReportGroups) Pass() bool {
+	for _, group := range rg.ReportGroup {
+		if group.Skip == "" && !group.Pass() {
+			return false
+		}
+	}
+	return true
+}
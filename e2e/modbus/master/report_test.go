@@ -0,0 +1,73 @@
+package master
+
+import (
+	"encoding/json"
+	"github.com/schnack/gotest"
+	"rtu-test/e2e/common"
+	"testing"
+	"time"
+)
+
+func TestReportMasterTest_MarshalJSON(t *testing.T) {
+	report := ReportGroups{Name: "bench", ReportGroup: []ReportGroup{{Name: "Default", Tests: []ReportMasterTest{{
+		Name:     "test",
+		Pass:     true,
+		GotByte:  []byte{0x01, 0x0a},
+		GotTime:  1500 * time.Millisecond,
+		Write:    []common.ReportWrite{{Name: "param1", Type: "uint8", Data: "1", DataHex: "01", DataBin: "00000001"}},
+		Expected: []common.ReportExpected{{Name: "param2", Pass: true, Type: "uint8", Expected: "10", ExpectedHex: "0a", Got: "10", GotHex: "0a"}},
+	}}}}}
+
+	data, err := json.Marshal(report)
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+
+	var result struct {
+		ReportGroup []struct {
+			Tests []struct {
+				Name     string
+				Pass     bool
+				GotByte  string
+				GotTime  string
+				Write    []common.ReportWrite
+				Expected []common.ReportExpected
+			}
+		}
+	}
+	if err := gotest.Expect(json.Unmarshal(data, &result)).Nil(); err != nil {
+		t.Fatal(err)
+	}
+
+	test := result.ReportGroup[0].Tests[0]
+	if err := gotest.Expect(test.Name).Eq("test"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(test.GotByte).Eq("01 0a"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(test.GotTime).Eq("1.5s"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(test.Write[0].DataBin).Eq("00000001"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(test.Expected[0].GotHex).Eq("0a"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReportGroups_Pass(t *testing.T) {
+	report := ReportGroups{ReportGroup: []ReportGroup{{Tests: []ReportMasterTest{
+		{Name: "ok", Pass: true},
+		{Name: "skip", Skip: "not ready"},
+	}}}}
+	if err := gotest.Expect(report.Pass()).True(); err != nil {
+		t.Error(err)
+	}
+
+	report.ReportGroup = append(report.ReportGroup, ReportGroup{Tests: []ReportMasterTest{{Name: "fail"}}})
+	if err := gotest.Expect(report.Pass()).False(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,132 @@
+package master
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/goburrow/modbus"
+	"github.com/goburrow/serial"
+	"io"
+	"sync"
+	"time"
+)
+
+// Минимальная пауза конца кадра rtu. Меньшие паузы не выдерживаются планировщиком и usb адаптерами
+const minSilentInterval = 10 * time.Millisecond
+
+// serialHandler - обработчик rtu и ascii, который сам владеет портом на все время сеанса. Кадры собирает
+// и проверяет обработчик goburrow, но его транспорт не используется: goburrow вычисляет длину ответа только
+// для стандартных функций, поэтому конец ответа определяется по паузе (rtu) или концу строки (ascii)
+type serialHandler struct {
+	// Обработчик goburrow, используется для Encode, Decode и Verify
+	clientHandler
+	config serial.Config
+	ascii  bool
+	// Пауза конца кадра rtu
+	silentInterval time.Duration
+	// Ожидание начала ответа
+	timeout time.Duration
+
+	mu   sync.Mutex
+	port io.ReadWriteCloser
+}
+
+// ownSerialPort - для rtu и ascii возвращает обработчик с собственным портом, tcp обработчик не меняется
+func ownSerialPort(handler clientHandler) clientHandler {
+	switch h := handler.(type) {
+	case *modbus.RTUClientHandler:
+		return newSerialHandler(h, h.Config, false, h.Timeout)
+	case *modbus.ASCIIClientHandler:
+		return newSerialHandler(h, h.Config, true, h.Timeout)
+	}
+	return handler
+}
+
+func newSerialHandler(handler clientHandler, config serial.Config, ascii bool, timeout time.Duration) *serialHandler {
+	silentInterval := minSilentInterval
+	// 3.5 символа по 11 бит
+	if config.BaudRate > 0 {
+		if interval := time.Duration(38500000/config.BaudRate) * time.Microsecond; interval > silentInterval {
+			silentInterval = interval
+		}
+	}
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	// Чтение порта завершается после паузы silentInterval
+	config.Timeout = silentInterval
+	return &serialHandler{clientHandler: handler, config: config, ascii: ascii, silentInterval: silentInterval, timeout: timeout}
+}
+
+// Connect - открывает порт, если он закрыт
+func (sh *serialHandler) Connect() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.connect()
+}
+
+func (sh *serialHandler) connect() error {
+	if sh.port != nil {
+		return nil
+	}
+	port, err := serial.Open(&sh.config)
+	if err != nil {
+		return err
+	}
+	sh.port = port
+	return nil
+}
+
+// Close - закрывает порт. Следующий запрос откроет его снова
+func (sh *serialHandler) Close() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.port == nil {
+		return nil
+	}
+	err := sh.port.Close()
+	sh.port = nil
+	return err
+}
+
+// Send - отправляет adu и читает ответ до паузы или конца строки
+func (sh *serialHandler) Send(aduRequest []byte) ([]byte, error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if err := sh.connect(); err != nil {
+		return nil, err
+	}
+	if _, err := sh.port.Write(aduRequest); err != nil {
+		return nil, err
+	}
+	return sh.read(sh.port)
+}
+
+// read - читает кадр ответа. Порт возвращает serial.ErrTimeout после паузы silentInterval
+func (sh *serialHandler) read(port io.Reader) ([]byte, error) {
+	deadline := time.Now().Add(sh.timeout)
+	var response []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := port.Read(buf)
+		if n > 0 {
+			response = append(response, buf[:n]...)
+			if sh.ascii && bytes.HasSuffix(response, []byte("\r\n")) {
+				return response, nil
+			}
+			continue
+		}
+		if err != nil && err != serial.ErrTimeout {
+			return response, err
+		}
+		// Пауза после данных - конец кадра rtu
+		if len(response) > 0 && !sh.ascii {
+			return response, nil
+		}
+		if time.Now().After(deadline) {
+			if len(response) > 0 {
+				return response, fmt.Errorf("modbus: incomplete response % x", response)
+			}
+			return nil, fmt.Errorf("modbus: no response within %s", sh.timeout)
+		}
+	}
+}
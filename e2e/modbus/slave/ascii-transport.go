@@ -0,0 +1,138 @@
+package slave
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/schnack/mbslave"
+	"github.com/sirupsen/logrus"
+	"go.bug.st/serial"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/fault"
+	"strings"
+	"time"
+)
+
+const (
+	asciiStart = ':'
+	asciiEnd   = "\r\n"
+)
+
+// AsciiTransport - modbus ascii транспорт для mbslave.Server.
+// Кадр передается в hex виде между ':' и CRLF и защищен LRC
+type AsciiTransport struct {
+	pduHandler
+	*mbslave.Config
+	Port serial.Port
+	Log  logrus.FieldLogger
+	// Запись кадров, если задана
+	Capture capture.Capture
+	// Вызывается после открытия порта
+	OnReady func()
+}
+
+func NewAsciiTransport(config *mbslave.Config) *AsciiTransport {
+	return &AsciiTransport{
+		Config: config,
+		Log:    logrus.StandardLogger(),
+	}
+}
+
+func (at *AsciiTransport) Listen() error {
+	var err error
+	if at.Port, err = mbslave.OpenSerialPort(at.Config); err != nil {
+		return err
+	}
+	defer at.Port.Close()
+	at.Log.Debugf("start listing %s %d %d", at.Config.Port, at.BaudRate, at.DataBits)
+	if at.OnReady != nil {
+		at.OnReady()
+	}
+
+	reader := bufio.NewReader(at.Port)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		at.Log.Debugf("<- in  raw(%03d): %q", len(line), line)
+		if at.Capture != nil {
+			at.Capture.Frame(capture.RX, line)
+		}
+
+		answer, plan, err := at.newFrame(line)
+		if err != nil {
+			at.Log.Debugf("no answer: %s", err)
+			continue
+		}
+		frames, delay := plan.Apply(answer)
+		time.Sleep(delay)
+		for _, out := range frames {
+			if _, err := at.Port.Write(out); err != nil {
+				return err
+			}
+			at.Log.Debugf("-> out raw(%03d): %q", len(out), out)
+			if at.Capture != nil {
+				at.Capture.Frame(capture.TX, out)
+			}
+		}
+	}
+}
+
+// newFrame - разбирает ascii кадр и формирует ascii ответ.
+// Ошибки wrongId и corruptCrc вносятся при сборке ответа, остальные возвращаются для отправки
+func (at *AsciiTransport) newFrame(line []byte) ([]byte, fault.Plan, error) {
+	// Все что до последнего ':' считаем мусором
+	start := bytes.LastIndexByte(line, asciiStart)
+	if start < 0 || !bytes.HasSuffix(line, []byte(asciiEnd)) {
+		return nil, nil, fmt.Errorf("frame damaged: %q", line)
+	}
+	frame := line[start+1 : len(line)-len(asciiEnd)]
+	raw := make([]byte, hex.DecodedLen(len(frame)))
+	if _, err := hex.Decode(raw, frame); err != nil {
+		return nil, nil, err
+	}
+	// Адрес, функция и lrc
+	if len(raw) < 3 {
+		return nil, nil, fmt.Errorf("frame damaged: %q", line)
+	}
+	if lrc := CalcLRC(raw[:len(raw)-1]); lrc != raw[len(raw)-1] {
+		return nil, nil, fmt.Errorf("lrc error: expected %02x got %02x", lrc, raw[len(raw)-1])
+	}
+
+	pdu, plan, err := at.handle(raw[0], raw[1:len(raw)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	slaveId := raw[0]
+	if f := plan.Get(fault.WrongId); f != nil {
+		slaveId = f.WrongSlaveId(slaveId)
+	}
+	return encodeAscii(slaveId, pdu, plan.Get(fault.CorruptCrc) != nil), plan, nil
+}
+
+// EncodeAscii - собирает modbus ascii кадр
+func EncodeAscii(slaveId uint8, pdu []byte) []byte {
+	return encodeAscii(slaveId, pdu, false)
+}
+
+// encodeAscii - собирает modbus ascii кадр, при corruptLrc с неверной контрольной суммой
+func encodeAscii(slaveId uint8, pdu []byte, corruptLrc bool) []byte {
+	raw := append([]byte{slaveId}, pdu...)
+	lrc := CalcLRC(raw)
+	if corruptLrc {
+		lrc ^= 0xff
+	}
+	raw = append(raw, lrc)
+	return []byte(string(asciiStart) + strings.ToUpper(hex.EncodeToString(raw)) + asciiEnd)
+}
+
+// CalcLRC - контрольная сумма modbus ascii (дополнение до двух суммы байт)
+func CalcLRC(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
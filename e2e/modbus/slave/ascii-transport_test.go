@@ -0,0 +1,53 @@
+package slave
+
+import (
+	"github.com/schnack/gotest"
+	"github.com/schnack/mbslave"
+	"io"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/modbus/master"
+	"testing"
+)
+
+func TestAsciiTransport_Listen(t *testing.T) {
+	mbslave.InoutSerialPort.Load()
+	defer mbslave.InoutSerialPort.Unload()
+
+	var param1 uint16 = 0x0102
+	ms := &ModbusSlave{
+		Mode:             master.ModeASCII,
+		SlaveId:          1,
+		Port:             "ascii",
+		HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &param1}},
+	}
+	server, err := ms.getServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Transport.(*AsciiTransport); !ok {
+		t.Fatal("expected ascii transport")
+	}
+
+	in := mbslave.InoutSerialPort.GetOut("ascii")
+	// Мусор перед началом кадра
+	in.WriteString("\x00\x00:010300000001FB\r\n")
+	// Неверный lrc
+	in.WriteString(":010300000001FA\r\n")
+
+	if err := gotest.Expect(server.Listen()).Eq(io.EOF); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(mbslave.InoutSerialPort.GetIn("ascii").String()).Eq(":0103020102F7\r\n"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCalcLRC(t *testing.T) {
+	if err := gotest.Expect(CalcLRC([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01})).Eq(byte(0xFB)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(string(EncodeAscii(0x11, []byte{0x03, 0x00, 0x6B, 0x00, 0x03}))).Eq(":1103006B00037E\r\n"); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,9 +2,14 @@ package slave
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/schnack/mbslave"
+	"gopkg.in/yaml.v3"
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/fault"
 	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/registers"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -35,10 +40,158 @@ type ModbusSlaveTest struct {
 	Success     Message                    `yaml:"success"`
 	Error       Message                    `yaml:"error"`
 	After       Message                    `yaml:"after"`
+	// Ответ исключением вместо данных: название ("illegal data address") или код ("0x02").
+	// writeError и exception равнозначны
+	WriteError string `yaml:"writeError"`
+	Exception  string `yaml:"exception"`
+	// Ошибки в ответе на запрос теста. Заменяют общие ошибки слейва
+	Faults []*fault.Fault `yaml:"faults"`
+	// Точка карты регистров, адрес которой ожидается в запросе, вместо address
+	Register string `yaml:"register"`
+	// Значения точек карты регистров по имени. Задаются в expected, beforeWrite и afterWrite
+	// рядом с таблицами
+	ExpectedPoints    map[string]*registers.Expected `yaml:"-"`
+	BeforeWritePoints map[string]string              `yaml:"-"`
+	AfterWritePoints  map[string]string              `yaml:"-"`
+}
+
+// UnmarshalYAML - ключи expected, beforeWrite и afterWrite, не совпадающие с таблицами,
+// считаются именами точек карты регистров
+func (ms *ModbusSlaveTest) UnmarshalYAML(node *yaml.Node) error {
+	type modbusSlaveTest ModbusSlaveTest
+	if node.Kind != yaml.MappingNode {
+		return node.Decode((*modbusSlaveTest)(ms))
+	}
+	rest := *node
+	rest.Content = append([]*yaml.Node{}, node.Content...)
+	for i := 0; i+1 < len(rest.Content); i += 2 {
+		var points interface{}
+		switch rest.Content[i].Value {
+		case "expected":
+			points = &ms.ExpectedPoints
+		case "beforeWrite":
+			points = &ms.BeforeWritePoints
+		case "afterWrite":
+			points = &ms.AfterWritePoints
+		default:
+			continue
+		}
+		tables, names := splitTables(rest.Content[i+1])
+		if names == nil {
+			continue
+		}
+		if err := names.Decode(points); err != nil {
+			return err
+		}
+		rest.Content[i+1] = tables
+	}
+	return rest.Decode((*modbusSlaveTest)(ms))
+}
+
+// splitTables - делит map на таблицы и точки карты регистров. nil если точек нет
+func splitTables(node *yaml.Node) (tables *yaml.Node, points *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return node, nil
+	}
+	t, p := *node, *node
+	t.Content, p.Content = nil, nil
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case CoilsTable, DiscreteInputTable, HoldingRegistersTable, InputRegistersTable:
+			t.Content = append(t.Content, node.Content[i], node.Content[i+1])
+		default:
+			p.Content = append(p.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	if len(p.Content) == 0 {
+		return node, nil
+	}
+	return &t, &p
+}
+
+// byRegisters - тест использует точки карты регистров
+func (ms *ModbusSlaveTest) byRegisters() bool {
+	return ms.Register != "" || ms.ExpectedPoints != nil || ms.BeforeWritePoints != nil || ms.AfterWritePoints != nil
+}
+
+// resolve - переносит точки карты регистров в адрес и таблицы теста
+func (ms *ModbusSlaveTest) resolve(m registers.Map) (errs []error) {
+	if ms.Register != "" {
+		if r, err := m.Get(ms.Register); err != nil {
+			errs = append(errs, common.NewFieldError("register", "%s", err))
+		} else if ms.Address != nil {
+			errs = append(errs, common.NewFieldError("register", "address and register are both set"))
+		} else {
+			address := *r.Address
+			ms.Address = &address
+		}
+	}
+
+	for _, name := range sortedKeys(ms.ExpectedPoints) {
+		r, err := m.Get(name)
+		if err != nil {
+			errs = append(errs, common.NewFieldError("expected."+name, "%s", err))
+			continue
+		}
+		v, err := r.Value(name, ms.ExpectedPoints[name])
+		if err != nil {
+			errs = append(errs, common.NewFieldError("expected."+name, "%s", err))
+			continue
+		}
+		v.Address = fmt.Sprintf("0x%04x", *r.Address)
+		if ms.Expected == nil {
+			ms.Expected = make(map[string][]*common.Value)
+		}
+		ms.Expected[r.TableName()] = append(ms.Expected[r.TableName()], v)
+	}
+
+	for _, write := range []struct {
+		field  string
+		points map[string]string
+		tables *map[string][]*common.Value
+	}{
+		{"beforeWrite", ms.BeforeWritePoints, &ms.BeforeWrite},
+		{"afterWrite", ms.AfterWritePoints, &ms.AfterWrite},
+	} {
+		field := write.field
+		names := make([]string, 0, len(write.points))
+		for name := range write.points {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			r, err := m.Get(name)
+			if err != nil {
+				errs = append(errs, common.NewFieldError(field+"."+name, "%s", err))
+				continue
+			}
+			v, err := r.WriteValue(name, write.points[name])
+			if err != nil {
+				errs = append(errs, common.NewFieldError(field+"."+name, "%s", err))
+				continue
+			}
+			if *write.tables == nil {
+				*write.tables = make(map[string][]*common.Value)
+			}
+			(*write.tables)[r.TableName()] = append((*write.tables)[r.TableName()], v)
+		}
+	}
+	ms.Register, ms.ExpectedPoints, ms.BeforeWritePoints, ms.AfterWritePoints = "", nil, nil, nil
+	return errs
+}
+
+func sortedKeys(points map[string]*registers.Expected) []string {
+	names := make([]string, 0, len(points))
+	for name := range points {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // Для поиска нужного теста
-func (ms *ModbusSlaveTest) Check(request mbslave.Request, nexts []string) (points int) {
+// wordOrder - порядок слов устройства для проверки записываемых данных
+func (ms *ModbusSlaveTest) Check(request mbslave.Request, nexts []string, wordOrder common.WordOrder) (points int) {
 
 	if (ms.Lifetime != nil && *ms.Lifetime <= 0) || ms.Skip != "" {
 		return
@@ -104,7 +257,7 @@ func (ms *ModbusSlaveTest) Check(request mbslave.Request, nexts []string) (point
 				if ms.getFunction() == master.WriteMultipleRegisters {
 					bitSize = 16
 				}
-				countBit, expected = v.Check(request.GetData(), 0, "", countBit, bitSize, binary.BigEndian)
+				countBit, expected = v.CheckOrder(request.GetData(), 0, "", countBit, bitSize, binary.BigEndian, wordOrder)
 				if !expected.Pass {
 					return 0
 				}
@@ -155,3 +308,15 @@ func (ms *ModbusSlaveTest) getFunction() master.ModbusFunction {
 		return master.NilFunction
 	}
 }
+
+// exception - код исключения для ответа. false если тест отвечает данными
+func (ms *ModbusSlaveTest) exception() (byte, bool) {
+	name := ms.Exception
+	if name == "" {
+		name = ms.WriteError
+	}
+	if name == "" {
+		return 0, false
+	}
+	return master.ExceptionCode(name)
+}
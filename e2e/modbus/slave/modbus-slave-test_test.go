@@ -3,7 +3,11 @@ package slave
 import (
 	"github.com/schnack/gotest"
 	"github.com/schnack/mbslave"
+	"gopkg.in/yaml.v3"
+	"math"
+	"rtu-test/e2e/common"
 	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/registers"
 	"testing"
 )
 
@@ -23,7 +27,7 @@ func TestModbusSlaveTest_Check(t *testing.T) {
 		t.Error(err)
 	}
 
-	if err := gotest.Expect(mt.Check(request, []string{})).Eq(111); err != nil {
+	if err := gotest.Expect(mt.Check(request, []string{}, common.ABCD)).Eq(111); err != nil {
 		t.Error(err)
 	}
 }
@@ -37,3 +41,80 @@ func TestModbusSlaveTest_getFunction(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestModbusSlave_ResolveRegisters(t *testing.T) {
+	var m registers.Map
+	if err := yaml.Unmarshal([]byte(`
+setpoint: {address: 0x0010, type: float32}
+mode: {address: 0x0020}
+alarm: {table: coils, address: 3}
+`), &m); err != nil {
+		t.Fatal(err)
+	}
+	var ms ModbusSlave
+	if err := yaml.Unmarshal([]byte(`
+tests:
+  - name: write setpoint
+    function: writeMultipleRegisters
+    register: setpoint
+    expected:
+      setpoint: {min: 10, max: 20}
+      holdingRegisters:
+        - address: 0x0000
+          uint16: 1
+    afterWrite:
+      mode: 2
+      alarm: true
+`), &ms); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(len(ms.ResolveRegisters(m))).Eq(0); err != nil {
+		t.Fatal(err)
+	}
+
+	test := ms.Tests[0]
+	if err := gotest.Expect(*test.Address).Eq(uint16(0x0010)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len(test.Expected[HoldingRegistersTable])).Eq(2); err != nil {
+		t.Error(err)
+	}
+
+	ms.DataModel = mbslave.NewDefaultDataModel(&mbslave.Config{
+		SlaveId:              0x01,
+		SizeCoils:            math.MaxUint16,
+		SizeHoldingRegisters: math.MaxUint16,
+		SizeInputRegisters:   math.MaxUint16,
+		SizeDiscreteInputs:   math.MaxUint16,
+	})
+	if err := ms.Write16Bit(HoldingRegistersTable, test.AfterWrite[HoldingRegistersTable]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.Write1Bit(CoilsTable, test.AfterWrite[CoilsTable]); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(ms.DataModel.GetHoldingRegisters(0x0020)).Eq(uint16(2)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(ms.DataModel.GetCoils(3)).True(); err != nil {
+		t.Error(err)
+	}
+
+	bits := math.Float32bits(15)
+	_ = ms.DataModel.SetHoldingRegisters(0x0000, 1)
+	_ = ms.DataModel.SetHoldingRegisters(0x0010, uint16(bits>>16))
+	_ = ms.DataModel.SetHoldingRegisters(0x0011, uint16(bits))
+	_, pass, err := ms.Expect16Bit(HoldingRegistersTable, test.Expected[HoldingRegistersTable])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(pass).True(); err != nil {
+		t.Error(err)
+	}
+
+	// Опечатка в имени таблицы считается неизвестной точкой
+	ms = ModbusSlave{Tests: []*ModbusSlaveTest{{Name: "bad", Register: "speed", ExpectedPoints: map[string]*registers.Expected{"holdingRegister": {Value: "1"}}}}}
+	if err := gotest.Expect(len(ms.ResolveRegisters(m))).Eq(2); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,16 +2,23 @@ package slave
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/schnack/mbslave"
 	"github.com/sirupsen/logrus"
 	"math"
+	"rtu-test/e2e/capture"
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/fault"
 	"rtu-test/e2e/modbus/master"
 	"rtu-test/e2e/template"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Адрес широковещательных команд
+const BroadcastId = 0
+
 const (
 	CoilsTable            = "coils"
 	DiscreteInputTable    = "discreteInput"
@@ -20,13 +27,30 @@ const (
 )
 
 type ModbusSlave struct {
-	SlaveId        uint8  `yaml:"slaveId"`
+	// rtu (по умолчанию), tcp или ascii
+	Mode    string `yaml:"mode"`
+	SlaveId uint8  `yaml:"slaveId"`
+	// Для rtu последовательный порт, для tcp адрес для прослушивания host:port
 	Port           string `yaml:"port"`
 	BoundRate      int    `yaml:"boundRate"`
 	DataBits       int    `yaml:"dataBits"`
 	Parity         string `yaml:"parity"`
 	StopBits       int    `yaml:"stopBits"`
 	SilentInterval string `yaml:"silentInterval"`
+	// Запись трафика: pcap=path или hex=path
+	Capture string `yaml:"capture"`
+	// Ошибки в ответах на все запросы. Ошибки теста заменяют общие
+	Faults []*fault.Fault `yaml:"faults"`
+	// Порядок слов 32 и 64 битных значений: ABCD (по умолчанию), CDAB, BADC или DCBA.
+	// Устройства шины без своего порядка используют порядок шины
+	WordOrder common.WordOrder `yaml:"wordOrder"`
+	// Задержка ответа устройства
+	Latency string `yaml:"latency"`
+	// Устройство выполняет широковещательные (адрес 0) команды записи
+	Broadcast bool `yaml:"broadcast"`
+	// Другие устройства на той же шине со своими адресом, таблицами, тестами и задержкой.
+	// Параметры порта задаются только у шины
+	Units []*ModbusSlave `yaml:"units"`
 
 	Coils            []*common.Value `yaml:"coils"`
 	DiscreteInput    []*common.Value `yaml:"discreteInput"`
@@ -37,10 +61,82 @@ type ModbusSlave struct {
 
 	DataModel *mbslave.DefaultDataModel `yaml:"-"`
 
+	// Лог исполнителя. По умолчанию стандартный лог logrus
+	Log logrus.FieldLogger `yaml:"-"`
+	// Вызывается, когда порт открыт и слейв готов принимать запросы
+	OnReady func() `yaml:"-"`
+
 	currentTest *ModbusSlaveTest `yaml:"-"`
+	traffic     capture.Capture
+
+	// Ошибки, выбранные для ответа на текущий запрос
+	plan   fault.Plan
+	muPlan sync.Mutex
+
+	// Отчеты выполненных тестов
+	reports   []ReportSlaveTest
+	muReports sync.Mutex
+
+	// Причина остановки шины: ошибка таблиц в тесте или nil после провала теста с fatal
+	stopped chan error
+}
+
+// Reports - отчеты выполненных тестов всех устройств шины
+func (ms *ModbusSlave) Reports() []ReportSlaveTest {
+	ms.muReports.Lock()
+	reports := append([]ReportSlaveTest{}, ms.reports...)
+	ms.muReports.Unlock()
+	for _, unit := range ms.Units {
+		reports = append(reports, unit.Reports()...)
+	}
+	return reports
+}
+
+func (ms *ModbusSlave) addReport(report ReportSlaveTest) {
+	report.SlaveId = ms.SlaveId
+	ms.muReports.Lock()
+	ms.reports = append(ms.reports, report)
+	ms.muReports.Unlock()
+}
+
+// setPlan - запоминает ошибки для ответа на текущий запрос
+func (ms *ModbusSlave) setPlan(plan fault.Plan) {
+	ms.muPlan.Lock()
+	ms.plan = plan
+	ms.muPlan.Unlock()
+}
+
+// takePlan - возвращает ошибки для ответа на текущий запрос. Транспорт вызывает его после обработчика
+func (ms *ModbusSlave) takePlan() fault.Plan {
+	ms.muPlan.Lock()
+	defer ms.muPlan.Unlock()
+	plan := ms.plan
+	ms.plan = nil
+	return plan
+}
+
+// stop - останавливает шину. Сохраняется первая причина, без запущенного Run ошибка только выводится в лог
+func (ms *ModbusSlave) stop(err error) {
+	if ms.stopped == nil {
+		if err != nil {
+			ms.logger().Error(err)
+		}
+		return
+	}
+	select {
+	case ms.stopped <- err:
+	default:
+	}
+}
+
+func (ms *ModbusSlave) logger() logrus.FieldLogger {
+	if ms.Log == nil {
+		return logrus.StandardLogger()
+	}
+	return ms.Log
 }
 
-func (ms *ModbusSlave) getServer() *mbslave.Server {
+func (ms *ModbusSlave) getServer() (*mbslave.Server, error) {
 	//# Parity: N - None, E - Even, O - Odd (default E)
 	parity := mbslave.EvenParity
 	switch strings.ToLower(ms.Parity) {
@@ -74,14 +170,60 @@ func (ms *ModbusSlave) getServer() *mbslave.Server {
 		SizeInputRegisters:   math.MaxUint16,
 		SizeHoldingRegisters: math.MaxUint16,
 	}
-	ms.DataModel = mbslave.NewDefaultDataModel(config)
-	transport := mbslave.NewRtuTransport(config)
+	for _, unit := range ms.bus() {
+		if unit != ms {
+			unit.Log = ms.logger().WithField("slaveId", unit.SlaveId)
+			unit.WordOrder = unit.WordOrder.Or(ms.WordOrder)
+			unit.stopped = ms.stopped
+		}
+		unitConfig := *config
+		unitConfig.SlaveId = unit.SlaveId
+		if err := unit.initDataModel(&unitConfig); err != nil {
+			return nil, common.NewConfigError("slave %d: %s", unit.SlaveId, err)
+		}
+	}
+
+	var transport mbslave.Transport
+	switch strings.ToLower(ms.Mode) {
+	case master.ModeTCP:
+		tcp := NewTcpTransport(ms.Port)
+		tcp.Log = ms.logger()
+		tcp.OnReady = ms.OnReady
+		tcp.Capture = ms.traffic
+		tcp.SetFaults(ms.takePlan)
+		transport = tcp
+	case master.ModeASCII:
+		ascii := NewAsciiTransport(config)
+		ascii.Log = ms.logger()
+		ascii.OnReady = ms.OnReady
+		ascii.Capture = ms.traffic
+		ascii.SetFaults(ms.takePlan)
+		transport = ascii
+	default:
+		rtu := mbslave.NewRtuTransport(config)
+		rtu.Log = ms.logger()
+		transport = rtu
+	}
 	s := mbslave.NewServer(transport, ms.DataModel)
+	if rtu, ok := transport.(*mbslave.RtuTransport); ok {
+		rtu.SetHandler(ms.rtuHandler(rtu, ms.busHandler))
+	} else {
+		transport.SetHandler(ms.busHandler)
+	}
+	return s, nil
+}
 
-	ms.Write1Bit(CoilsTable, ms.Coils)
-	ms.Write1Bit(DiscreteInputTable, ms.DiscreteInput)
-	ms.Write16Bit(HoldingRegistersTable, ms.HoldingRegisters)
-	ms.Write16Bit(InputRegistersTable, ms.InputRegisters)
+// initDataModel - создает таблицы устройства и заполняет их начальными значениями
+func (ms *ModbusSlave) initDataModel(config *mbslave.Config) error {
+	ms.DataModel = mbslave.NewDefaultDataModel(config)
+	if err := ms.writeTables(map[string][]*common.Value{
+		CoilsTable:            ms.Coils,
+		DiscreteInputTable:    ms.DiscreteInput,
+		HoldingRegistersTable: ms.HoldingRegisters,
+		InputRegistersTable:   ms.InputRegisters,
+	}); err != nil {
+		return err
+	}
 	ms.DataModel.SetFunction(mbslave.FuncReadCoils, ms.ActionHandler)
 	ms.DataModel.SetFunction(mbslave.FuncReadDiscreteInputs, ms.ActionHandler)
 	ms.DataModel.SetFunction(mbslave.FuncReadHoldingRegisters, ms.ActionHandler)
@@ -90,13 +232,122 @@ func (ms *ModbusSlave) getServer() *mbslave.Server {
 	ms.DataModel.SetFunction(mbslave.FuncWriteSingleRegister, ms.ActionHandler)
 	ms.DataModel.SetFunction(mbslave.FuncWriteMultipleCoils, ms.ActionHandler)
 	ms.DataModel.SetFunction(mbslave.FuncWriteMultipleRegisters, ms.ActionHandler)
-	return s
+	return nil
+}
+
+// writeTables - записывает значения в таблицы устройства
+func (ms *ModbusSlave) writeTables(tables map[string][]*common.Value) error {
+	for _, table := range []string{CoilsTable, DiscreteInputTable, HoldingRegistersTable, InputRegistersTable} {
+		v, ok := tables[table]
+		if !ok {
+			continue
+		}
+		var err error
+		switch table {
+		case CoilsTable, DiscreteInputTable:
+			err = ms.Write1Bit(table, v)
+		default:
+			err = ms.Write16Bit(table, v)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", table, err)
+		}
+	}
+	return nil
+}
+
+// bus - все устройства на шине: основное и units
+func (ms *ModbusSlave) bus() []*ModbusSlave {
+	return append([]*ModbusSlave{ms}, ms.Units...)
+}
+
+// busHandler - передает запрос устройству с адресом запроса. Широковещательные (адрес 0) команды записи
+// выполняют устройства с broadcast, ответ на них не отправляется. Устройство с адресом 0 отвечает как обычно
+func (ms *ModbusSlave) busHandler(request mbslave.Request, response mbslave.Response) {
+	slaveId := request.GetSlaveId()
+	for _, unit := range ms.bus() {
+		if unit.SlaveId != slaveId && slaveId != 255 {
+			continue
+		}
+		unit.DataModel.Handler(request, response)
+		// Ошибки ответа забирает транспорт шины
+		if unit != ms {
+			ms.setPlan(unit.takePlan())
+		}
+		time.Sleep(common.ParseDuration(unit.Latency))
+		return
+	}
+
+	response.Unanswered(true)
+	if slaveId == BroadcastId {
+		if err := request.Parse(); err != nil {
+			return
+		}
+		switch master.ModbusFunction(request.GetFunction()) {
+		case master.WriteSingleCoil, master.WriteSingleRegister, master.WriteMultipleCoils, master.WriteMultipleRegisters:
+		default:
+			return
+		}
+		for _, unit := range ms.bus() {
+			if unit.Broadcast {
+				unit.ActionHandler(request, mbslave.NewRtuResponse(request))
+				unit.takePlan()
+			}
+		}
+	}
 }
 
 func (ms *ModbusSlave) Run() error {
-	s := ms.getServer()
-	ms.autorun()
-	return s.Listen()
+	if ms.Capture != "" {
+		traffic, err := capture.Open(ms.Capture, master.CaptureLink(ms.Mode))
+		if err != nil {
+			return common.NewConfigError("open capture: %s", err)
+		}
+		defer traffic.Close()
+		ms.traffic = traffic
+	}
+	ms.stopped = make(chan error, 1)
+	s, err := ms.getServer()
+	if err != nil {
+		return err
+	}
+	for _, unit := range ms.bus() {
+		unit.autorun()
+	}
+	// Транспорт rtu открывает порт внутри mbslave без уведомления. Порт открывается сразу
+	// при запуске, а данные виртуального порта буферизуются, поэтому готовность сообщается заранее
+	if _, ok := s.Transport.(*mbslave.RtuTransport); ok && ms.OnReady != nil {
+		ms.OnReady()
+	}
+
+	listen := make(chan error, 1)
+	go func() {
+		listen <- s.Listen()
+	}()
+	select {
+	case err := <-listen:
+		return err
+	case err := <-ms.stopped:
+		// Ждем закрытия порта, чтобы запись трафика не продолжалась после возврата
+		if closeTransport(s.Transport) {
+			<-listen
+		}
+		return err
+	}
+}
+
+// closeTransport - закрывает порт или слушающий сокет, после чего Listen возвращается.
+// Возвращает false, если порт еще не открыт
+func closeTransport(transport mbslave.Transport) bool {
+	switch t := transport.(type) {
+	case *TcpTransport:
+		return t.Close() == nil
+	case *AsciiTransport:
+		return t.Port != nil && t.Port.Close() == nil
+	case *mbslave.RtuTransport:
+		return t.Port != nil && t.Port.Close() == nil
+	}
+	return false
 }
 
 func (ms *ModbusSlave) autorun() {
@@ -138,20 +389,8 @@ func (ms *ModbusSlave) before(test *ModbusSlaveTest, reports ReportSlaveTest) {
 
 	test.Before.PrintReportSlaveTest(reports)
 
-	if test.BeforeWrite == nil {
-		return
-	}
-	if v, ok := test.BeforeWrite[CoilsTable]; ok {
-		ms.Write1Bit(CoilsTable, v)
-	}
-	if v, ok := test.BeforeWrite[DiscreteInputTable]; ok {
-		ms.Write1Bit(DiscreteInputTable, v)
-	}
-	if v, ok := test.BeforeWrite[HoldingRegistersTable]; ok {
-		ms.Write16Bit(HoldingRegistersTable, v)
-	}
-	if v, ok := test.BeforeWrite[InputRegistersTable]; ok {
-		ms.Write16Bit(InputRegistersTable, v)
+	if err := ms.writeTables(test.BeforeWrite); err != nil {
+		ms.stop(common.NewConfigError("test %s: beforeWrite: %s", test.Name, err))
 	}
 }
 
@@ -160,29 +399,38 @@ func (ms *ModbusSlave) expected(test *ModbusSlaveTest, reports ReportSlaveTest)
 		return
 	}
 
-	logrus.Warn(common.Render(template.TestSlaveModBusRUN, reports))
+	ms.logger().Warn(common.Render(template.TestSlaveModBusRUN, reports))
 
-	if v, ok := test.Expected[CoilsTable]; ok {
-		reports.ExpectedCoils, reports.Pass = ms.Expect1Bit(CoilsTable, v)
+	var err error
+	if v, ok := test.Expected[CoilsTable]; ok && err == nil {
+		reports.ExpectedCoils, reports.Pass, err = ms.Expect1Bit(CoilsTable, v)
 	}
-	if v, ok := test.Expected[DiscreteInputTable]; ok {
-		reports.ExpectedDiscreteInput, reports.Pass = ms.Expect1Bit(DiscreteInputTable, v)
+	if v, ok := test.Expected[DiscreteInputTable]; ok && err == nil {
+		reports.ExpectedDiscreteInput, reports.Pass, err = ms.Expect1Bit(DiscreteInputTable, v)
 	}
-	if v, ok := test.Expected[HoldingRegistersTable]; ok {
-		reports.ExpectedHoldingRegisters, reports.Pass = ms.Expect16Bit(HoldingRegistersTable, v)
+	if v, ok := test.Expected[HoldingRegistersTable]; ok && err == nil {
+		reports.ExpectedHoldingRegisters, reports.Pass, err = ms.Expect16Bit(HoldingRegistersTable, v)
 	}
-	if v, ok := test.Expected[InputRegistersTable]; ok {
-		reports.ExpectedInputRegisters, reports.Pass = ms.Expect16Bit(InputRegistersTable, v)
+	if v, ok := test.Expected[InputRegistersTable]; ok && err == nil {
+		reports.ExpectedInputRegisters, reports.Pass, err = ms.Expect16Bit(InputRegistersTable, v)
+	}
+	if err != nil {
+		ms.stop(common.NewConfigError("test %s: expected: %s", test.Name, err))
+		return
 	}
 
 	if reports.Pass {
-		logrus.Warn(common.Render(template.TestSlaveModBusPASS, reports))
+		ms.logger().Warn(common.Render(template.TestSlaveModBusPASS, reports))
 		test.Success.PrintReportSlaveTest(reports)
+		ms.addReport(reports)
 	} else {
-		logrus.Error(common.Render(template.TestSlaveModBusFAIL, reports))
+		ms.logger().Error(common.Render(template.TestSlaveModBusFAIL, reports))
 		test.Error.PrintReportSlaveTest(reports)
+		ms.addReport(reports)
+		// Фатальная ошибка прекращает работу слейва
 		if test.Fatal != "" {
-			logrus.Fatal(test.Fatal)
+			ms.logger().Error(test.Fatal)
+			ms.stop(nil)
 		}
 	}
 }
@@ -192,19 +440,8 @@ func (ms *ModbusSlave) after(test *ModbusSlaveTest, reports ReportSlaveTest) {
 		return
 	}
 
-	if test.AfterWrite != nil {
-		if v, ok := test.AfterWrite[CoilsTable]; ok {
-			ms.Write1Bit(CoilsTable, v)
-		}
-		if v, ok := test.AfterWrite[DiscreteInputTable]; ok {
-			ms.Write1Bit(DiscreteInputTable, v)
-		}
-		if v, ok := test.AfterWrite[HoldingRegistersTable]; ok {
-			ms.Write16Bit(HoldingRegistersTable, v)
-		}
-		if v, ok := test.AfterWrite[InputRegistersTable]; ok {
-			ms.Write16Bit(InputRegistersTable, v)
-		}
+	if err := ms.writeTables(test.AfterWrite); err != nil {
+		ms.stop(common.NewConfigError("test %s: afterWrite: %s", test.Name, err))
 	}
 
 	test.After.PrintReportSlaveTest(reports)
@@ -222,7 +459,7 @@ func (ms *ModbusSlave) ActionHandler(request mbslave.Request, response mbslave.R
 	}
 
 	for i := range ms.Tests {
-		ball := ms.Tests[i].Check(request, next)
+		ball := ms.Tests[i].Check(request, next, ms.WordOrder)
 
 		if ball != 0 && ball > max {
 			test = ms.Tests[i]
@@ -230,10 +467,24 @@ func (ms *ModbusSlave) ActionHandler(request mbslave.Request, response mbslave.R
 		}
 	}
 
+	// Ошибки теста заменяют общие
+	faults := ms.Faults
+	if test != nil && test.Faults != nil {
+		faults = test.Faults
+	}
+	plan := fault.Choose(faults)
+	ms.setPlan(plan)
+	reports.Faults = plan.Names()
+	if len(plan) > 0 {
+		ms.logger().Warnf("Inject faults: %s", strings.Join(reports.Faults, ", "))
+	}
+
 	if test != nil {
 		reports.Name = test.Name
 		if test.Skip != "" {
-			logrus.Warn(common.Render(template.TestSlaveModBusSKIP, reports))
+			reports.Skip = test.Skip
+			ms.logger().Warn(common.Render(template.TestSlaveModBusSKIP, reports))
+			ms.addReport(reports)
 		} else {
 			if test.Lifetime != nil {
 				*test.Lifetime--
@@ -241,38 +492,59 @@ func (ms *ModbusSlave) ActionHandler(request mbslave.Request, response mbslave.R
 		}
 	}
 
+	// Тест может ответить исключением вместо обращения к таблицам
+	var exception byte
+	if test != nil && test.Skip == "" {
+		if code, ok := test.exception(); ok {
+			exception = code
+			reports.Exception = master.ExceptionName(code)
+		}
+	}
+
 	ms.before(test, reports)
 
-	switch master.ModbusFunction(request.GetFunction()) {
-	case master.ReadCoils:
-		ms.DataModel.ReadCoils(request, response)
-	case master.ReadDiscreteInputs:
-		ms.DataModel.ReadDiscreteInputs(request, response)
-	case master.ReadHoldingRegisters:
-		ms.DataModel.ReadHoldingRegisters(request, response)
-	case master.ReadInputRegisters:
-		ms.DataModel.ReadInputRegisters(request, response)
-	case master.WriteSingleCoil:
-		ms.DataModel.WriteSingleCoil(request, response)
-	case master.WriteSingleRegister:
-		ms.DataModel.WriteSingleRegister(request, response)
-	case master.WriteMultipleCoils:
-		ms.DataModel.WriteMultipleCoils(request, response)
-	case master.WriteMultipleRegisters:
-		ms.DataModel.WriteMultipleRegisters(request, response)
+	if exception != 0 {
+		response.SetError(exception)
+	} else {
+		switch master.ModbusFunction(request.GetFunction()) {
+		case master.ReadCoils:
+			ms.DataModel.ReadCoils(request, response)
+		case master.ReadDiscreteInputs:
+			ms.DataModel.ReadDiscreteInputs(request, response)
+		case master.ReadHoldingRegisters:
+			ms.DataModel.ReadHoldingRegisters(request, response)
+		case master.ReadInputRegisters:
+			ms.DataModel.ReadInputRegisters(request, response)
+		case master.WriteSingleCoil:
+			ms.DataModel.WriteSingleCoil(request, response)
+		case master.WriteSingleRegister:
+			ms.DataModel.WriteSingleRegister(request, response)
+		case master.WriteMultipleCoils:
+			ms.DataModel.WriteMultipleCoils(request, response)
+		case master.WriteMultipleRegisters:
+			ms.DataModel.WriteMultipleRegisters(request, response)
+		}
 	}
 
 	ms.expected(test, reports)
 	ms.after(test, reports)
 
+	// Ответ с ошибками или исключением попадает в отчет, даже если тест ничего не проверяет
+	if (len(plan) > 0 || reports.Exception != "") && (test == nil || (test.Skip == "" && test.Expected == nil)) {
+		if test == nil {
+			reports.Name = fmt.Sprintf("%s 0x%04x", master.ModbusFunction(request.GetFunction()), request.GetAddress())
+		}
+		reports.Pass = true
+		ms.addReport(reports)
+	}
+
 	if test != nil && test.Skip == "" {
 		ms.currentTest = test
 		time.Sleep(common.ParseDuration(test.TimeOut))
 	}
-	return
 }
 
-func (ms *ModbusSlave) Expect1Bit(table string, v []*common.Value) (reports []common.ReportExpected, pass bool) {
+func (ms *ModbusSlave) Expect1Bit(table string, v []*common.Value) (reports []common.ReportExpected, pass bool, err error) {
 	pass = true
 	var address uint16 = 0
 
@@ -287,15 +559,14 @@ func (ms *ModbusSlave) Expect1Bit(table string, v []*common.Value) (reports []co
 		countRegisters = ms.DataModel.LengthDiscreteInputs()
 		getFunc = ms.DataModel.GetDiscreteInputs
 	default:
-		logrus.Fatalf("%s table is not supported", table)
-		return
+		return nil, false, fmt.Errorf("%s table is not supported", table)
 	}
 
 	for i := range v {
 		if v[i].Address != "" {
 			rawAddress, err := common.ParseStringByte(v[i].Address)
 			if err != nil {
-				logrus.Fatalf("parse address %s", err)
+				return nil, false, fmt.Errorf("parse address %s", err)
 			}
 			address = binary.BigEndian.Uint16(rawAddress)
 		}
@@ -309,7 +580,7 @@ func (ms *ModbusSlave) Expect1Bit(table string, v []*common.Value) (reports []co
 
 		for ii := 0; ii < v[i].LengthBit(); ii++ {
 			if countRegisters <= int(address) {
-				logrus.Fatal("ModBus tables overflow")
+				return nil, false, fmt.Errorf("ModBus tables overflow")
 			}
 			if getFunc(address) {
 				buf[ii/8] |= 1 << (ii % 8)
@@ -326,7 +597,7 @@ func (ms *ModbusSlave) Expect1Bit(table string, v []*common.Value) (reports []co
 	return
 }
 
-func (ms *ModbusSlave) Expect16Bit(table string, v []*common.Value) (reports []common.ReportExpected, pass bool) {
+func (ms *ModbusSlave) Expect16Bit(table string, v []*common.Value) (reports []common.ReportExpected, pass bool, err error) {
 	pass = true
 	var address uint16 = 0
 
@@ -338,8 +609,7 @@ func (ms *ModbusSlave) Expect16Bit(table string, v []*common.Value) (reports []c
 	case InputRegistersTable:
 		getFunc = ms.DataModel.GetInputRegisters
 	default:
-		logrus.Fatalf("%s table is not supported", table)
-		return
+		return nil, false, fmt.Errorf("%s table is not supported", table)
 	}
 
 	countBit := 0
@@ -366,7 +636,7 @@ func (ms *ModbusSlave) Expect16Bit(table string, v []*common.Value) (reports []c
 		if v[i].Address != "" {
 			rawAddress, err := common.ParseStringByte(v[i].Address)
 			if err != nil {
-				logrus.Fatalf("parse address %s", err)
+				return nil, false, fmt.Errorf("parse address %s", err)
 			}
 			address = binary.BigEndian.Uint16(rawAddress)
 			countBit = 0
@@ -396,7 +666,7 @@ func (ms *ModbusSlave) Expect16Bit(table string, v []*common.Value) (reports []c
 			}
 		}
 
-		_, report := v[i].Check(buf, 0, "", countBit, 16, binary.BigEndian)
+		_, report := v[i].CheckOrder(buf, 0, "", countBit, 16, binary.BigEndian, ms.WordOrder)
 
 		switch v[i].Type() {
 		case common.Bool:
@@ -441,7 +711,7 @@ func (ms *ModbusSlave) Expect16Bit(table string, v []*common.Value) (reports []c
 	return
 }
 
-func (ms *ModbusSlave) Write1Bit(table string, v []*common.Value) {
+func (ms *ModbusSlave) Write1Bit(table string, v []*common.Value) error {
 	var address uint16 = 0
 
 	var setFunc func(address uint16, value bool) error
@@ -455,15 +725,14 @@ func (ms *ModbusSlave) Write1Bit(table string, v []*common.Value) {
 		countRegisters = ms.DataModel.LengthDiscreteInputs()
 		setFunc = ms.DataModel.SetDiscreteInputs
 	default:
-		logrus.Fatalf("%s table is not supported", table)
-		return
+		return fmt.Errorf("%s table is not supported", table)
 	}
 
 	for i := range v {
 		if v[i].Address != "" {
 			rawAddress, err := common.ParseStringByte(v[i].Address)
 			if err != nil {
-				logrus.Fatalf("parse address %s", err)
+				return fmt.Errorf("parse address %s", err)
 			}
 			address = binary.BigEndian.Uint16(rawAddress)
 		}
@@ -471,29 +740,30 @@ func (ms *ModbusSlave) Write1Bit(table string, v []*common.Value) {
 		data := v[i].Write(binary.BigEndian)
 		for _, b := range data {
 			if countRegisters <= int(address) {
-				logrus.Fatal("ModBus tables overflow")
+				return fmt.Errorf("ModBus tables overflow")
 			}
 			if v[i].Type() == common.Bool {
 				if err := setFunc(address, b != 0); err != nil {
-					logrus.Fatalf("%s", err)
+					return err
 				}
 				address++
 			} else {
 				for ii := 0; ii < 8; ii++ {
 					if countRegisters <= int(address) {
-						logrus.Fatal("ModBus tables overflow")
+						return fmt.Errorf("ModBus tables overflow")
 					}
 					if err := setFunc(address, (b&(1<<ii)) != 0); err != nil {
-						logrus.Fatalf("%s", err)
+						return err
 					}
 					address++
 				}
 			}
 		}
 	}
+	return nil
 }
 
-func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
+func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) error {
 	var address uint16 = 0
 
 	var setFunc func(address uint16, value uint16) error
@@ -507,8 +777,7 @@ func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
 		countRegisters = ms.DataModel.LengthInputRegisters()
 		setFunc = ms.DataModel.SetInputRegisters
 	default:
-		logrus.Fatalf("%s table is not supported", table)
-		return
+		return fmt.Errorf("%s table is not supported", table)
 	}
 
 	var vBytes uint16 = 0
@@ -519,10 +788,10 @@ func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
 			// Сбрасываем счетчик бит
 			if current != 0 {
 				if countRegisters <= int(address) {
-					logrus.Fatal("ModBus tables overflow")
+					return fmt.Errorf("ModBus tables overflow")
 				}
 				if err := setFunc(address, vBytes); err != nil {
-					logrus.Fatalf("%s", err)
+					return err
 				}
 				address++
 				vBytes = 0
@@ -531,16 +800,16 @@ func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
 
 			rawAddress, err := common.ParseStringByte(v[i].Address)
 			if err != nil {
-				logrus.Fatalf("parse address %s", err)
+				return fmt.Errorf("parse address %s", err)
 			}
 			address = binary.BigEndian.Uint16(rawAddress)
 
 		} else if current >= 16 {
 			if countRegisters <= int(address) {
-				logrus.Fatal("ModBus tables overflow")
+				return fmt.Errorf("ModBus tables overflow")
 			}
 			if err := setFunc(address, vBytes); err != nil {
-				logrus.Fatalf("%s", err)
+				return err
 			}
 			address++
 			vBytes = 0
@@ -552,17 +821,17 @@ func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
 			vBytes |= 1 << current
 			current++
 		default:
-			data := v[i].Write(binary.BigEndian)
+			data := v[i].WriteOrder(binary.BigEndian, ms.WordOrder)
 			if current < 8 && current != 0 {
 				current += 8 - (current % 8)
 			}
 
 			if current < 16 && current != 0 && !(len(data) == 1 && current == 8) {
 				if countRegisters <= int(address) {
-					logrus.Fatal("ModBus tables overflow")
+					return fmt.Errorf("ModBus tables overflow")
 				}
 				if err := setFunc(address, vBytes); err != nil {
-					logrus.Fatalf("%s", err)
+					return err
 				}
 				address++
 				vBytes = 0
@@ -572,10 +841,10 @@ func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
 			for _, b := range data {
 				if current >= 16 {
 					if countRegisters <= int(address) {
-						logrus.Fatal("ModBus tables overflow")
+						return fmt.Errorf("ModBus tables overflow")
 					}
 					if err := setFunc(address, vBytes); err != nil {
-						logrus.Fatalf("%s", err)
+						return err
 					}
 					address++
 					vBytes = 0
@@ -592,10 +861,11 @@ func (ms *ModbusSlave) Write16Bit(table string, v []*common.Value) {
 	}
 	if current != 0 {
 		if countRegisters <= int(address) {
-			logrus.Fatal("ModBus tables overflow")
+			return fmt.Errorf("ModBus tables overflow")
 		}
 		if err := setFunc(address, vBytes); err != nil {
-			logrus.Fatalf("%s", err)
+			return err
 		}
 	}
+	return nil
 }
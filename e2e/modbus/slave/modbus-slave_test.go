@@ -1,11 +1,18 @@
 package slave
 
 import (
+	"encoding/binary"
+	"errors"
+	"github.com/goburrow/modbus"
 	"github.com/schnack/gotest"
 	"github.com/schnack/mbslave"
+	"io"
 	"math"
+	"net"
 	"rtu-test/e2e/common"
+	"rtu-test/e2e/modbus/master"
 	"testing"
+	"time"
 )
 
 func TestModbusSlave_Expect1Bit(t *testing.T) {
@@ -49,7 +56,10 @@ func TestModbusSlave_Expect1Bit(t *testing.T) {
 
 	slave := ModbusSlave{DataModel: dataModel}
 
-	reports, pass := slave.Expect1Bit(CoilsTable, values)
+	reports, pass, err := slave.Expect1Bit(CoilsTable, values)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if err := gotest.Expect(len(reports)).Eq(6); err != nil {
 		t.Error(err)
@@ -155,7 +165,10 @@ func TestModbusSlave_Expect16Bit(t *testing.T) {
 		_ = dataModel.SetHoldingRegisters(uint16(i), v)
 	}
 
-	reports, pass := slave.Expect16Bit(HoldingRegistersTable, values)
+	reports, pass, err := slave.Expect16Bit(HoldingRegistersTable, values)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if err := gotest.Expect(len(reports)).Eq(7); err != nil {
 		t.Error(err)
@@ -248,7 +261,9 @@ func TestModbusSlave_Write1Bit(t *testing.T) {
 		}),
 	}
 
-	slave.Write1Bit(CoilsTable, slave.Coils)
+	if err := slave.Write1Bit(CoilsTable, slave.Coils); err != nil {
+		t.Fatal(err)
+	}
 
 	for i, v := range []byte{
 		1,
@@ -294,7 +309,9 @@ func TestModbusSlave_Write16Bit(t *testing.T) {
 			SizeDiscreteInputs:   math.MaxUint16,
 		}),
 	}
-	slave.Write16Bit(HoldingRegistersTable, slave.HoldingRegisters)
+	if err := slave.Write16Bit(HoldingRegistersTable, slave.HoldingRegisters); err != nil {
+		t.Fatal(err)
+	}
 
 	for i, v := range []uint16{
 		0x0001,
@@ -316,3 +333,200 @@ func TestModbusSlave_Write16Bit(t *testing.T) {
 		}
 	}
 }
+
+func TestModbusSlave_WordOrder(t *testing.T) {
+	var voltage float32 = 230.5
+	var energy uint32 = 0x0a0b0c0d
+	slave := ModbusSlave{
+		WordOrder: common.CDAB,
+		DataModel: mbslave.NewDefaultDataModel(&mbslave.Config{
+			SlaveId:              0x01,
+			SizeCoils:            math.MaxUint16,
+			SizeHoldingRegisters: math.MaxUint16,
+			SizeInputRegisters:   math.MaxUint16,
+			SizeDiscreteInputs:   math.MaxUint16,
+		}),
+	}
+	values := []*common.Value{
+		{Name: "voltage", Address: "0x0000", Float32: &voltage},
+		{Name: "energy", Address: "0x0002", Uint32: &energy, WordOrder: common.BADC},
+	}
+	if err := slave.Write16Bit(HoldingRegistersTable, values); err != nil {
+		t.Fatal(err)
+	}
+
+	bits := math.Float32bits(voltage)
+	for i, v := range []uint16{uint16(bits), uint16(bits >> 16), 0x0b0a, 0x0d0c} {
+		if err := gotest.Expect(slave.DataModel.GetHoldingRegisters(uint16(i))).Eq(v); err != nil {
+			t.Error(err)
+		}
+	}
+
+	reports, pass, err := slave.Expect16Bit(HoldingRegistersTable, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(pass).True(); err != nil {
+		t.Error(err, reports)
+	}
+}
+
+func TestModbusSlave_ActionHandlerException(t *testing.T) {
+	mbslave.InoutSerialPort.Load()
+	defer mbslave.InoutSerialPort.Unload()
+
+	var address uint16 = 0x0000
+	ms := &ModbusSlave{
+		SlaveId:        1,
+		Port:           "rtu",
+		SilentInterval: "1ms",
+		Tests: []*ModbusSlaveTest{
+			{Name: "busy", Function: "read holding registers", Address: &address, Exception: "illegal data address"},
+		},
+	}
+	server, err := ms.getServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mbslave.InoutSerialPort.GetOut("rtu").Write([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01, 0x84, 0x0a})
+	if err := gotest.Expect(server.Listen()).Eq(io.EOF); err != nil {
+		t.Error(err)
+	}
+
+	if err := gotest.Expect(mbslave.InoutSerialPort.GetIn("rtu").Bytes()).Eq([]byte{0x01, 0x83, 0x02, 0xc0, 0xf1}); err != nil {
+		t.Error(err)
+	}
+	reports := ms.Reports()
+	if err := gotest.Expect(len(reports)).Eq(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(reports[0].Exception).Eq("illegal data address"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusSlave_Units(t *testing.T) {
+	mbslave.InoutSerialPort.Load()
+	defer mbslave.InoutSerialPort.Unload()
+
+	var param1 uint16 = 0x0101
+	var param2 uint16 = 0x0202
+	ms := &ModbusSlave{
+		SlaveId:          1,
+		Port:             "rtu",
+		SilentInterval:   "1ms",
+		HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &param1}},
+		Units: []*ModbusSlave{
+			{SlaveId: 2, Broadcast: true, HoldingRegisters: []*common.Value{{Name: "param2", Address: "0x0000", Uint16: &param2}}},
+		},
+	}
+	server, err := ms.getServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Кадр с контрольной суммой
+	adu := func(data ...byte) []byte {
+		crc := make([]byte, 2)
+		binary.LittleEndian.PutUint16(crc, mbslave.CalcCRC(data))
+		return append(data, crc...)
+	}
+	request := func(request []byte) []byte {
+		mbslave.InoutSerialPort.GetIn("rtu").Reset()
+		mbslave.InoutSerialPort.GetOut("rtu").Write(request)
+		if err := gotest.Expect(server.Listen()).Eq(io.EOF); err != nil {
+			t.Error(err)
+		}
+		return mbslave.InoutSerialPort.GetIn("rtu").Bytes()
+	}
+
+	// Каждое устройство отвечает своими таблицами
+	if err := gotest.Expect(request(adu(0x02, 0x03, 0x00, 0x00, 0x00, 0x01))).Eq(adu(0x02, 0x03, 0x02, 0x02, 0x02)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(request(adu(0x01, 0x03, 0x00, 0x00, 0x00, 0x01))).Eq(adu(0x01, 0x03, 0x02, 0x01, 0x01)); err != nil {
+		t.Error(err)
+	}
+	// Адреса нет на шине
+	if err := gotest.Expect(len(request(adu(0x03, 0x03, 0x00, 0x00, 0x00, 0x01)))).Eq(0); err != nil {
+		t.Error(err)
+	}
+
+	// Широковещательная запись выполняется только устройствами с broadcast и остается без ответа
+	if err := gotest.Expect(len(request(adu(0x00, 0x06, 0x00, 0x00, 0x03, 0x03)))).Eq(0); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(ms.Units[0].DataModel.GetHoldingRegisters(0)).Eq(uint16(0x0303)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(ms.DataModel.GetHoldingRegisters(0)).Eq(uint16(0x0101)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusSlave_RunConfigError(t *testing.T) {
+	var param uint32 = 1
+	ms := &ModbusSlave{
+		Mode:             master.ModeTCP,
+		SlaveId:          1,
+		Port:             "127.0.0.1:0",
+		HoldingRegisters: []*common.Value{{Name: "param", Address: "0xFFFF", Uint32: &param}},
+	}
+	var configError *common.ConfigError
+	if err := gotest.Expect(errors.As(ms.Run(), &configError)).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModbusSlave_RunFatal(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	var register uint16 = 1
+	var expected uint16 = 0x0304
+	ms := &ModbusSlave{
+		Mode:    master.ModeTCP,
+		SlaveId: 1,
+		Port:    address,
+		Tests: []*ModbusSlaveTest{{
+			Name:     "write",
+			Fatal:    "stop",
+			Function: "WriteSingleRegister",
+			Address:  &register,
+			Expected: map[string][]*common.Value{
+				HoldingRegistersTable: {{Name: "param", Address: "0x0001", Uint16: &expected}},
+			},
+		}},
+	}
+	ready := make(chan struct{})
+	ms.OnReady = func() { close(ready) }
+	result := make(chan error, 1)
+	go func() { result <- ms.Run() }()
+	<-ready
+
+	handler := modbus.NewTCPClientHandler(address)
+	handler.SlaveId = 1
+	handler.Timeout = time.Second
+	defer handler.Close()
+	if _, err := modbus.NewClient(handler).WriteSingleRegister(1, 0x0102); err != nil {
+		t.Fatal(err)
+	}
+
+	// Провал теста с fatal останавливает слейв без ошибки
+	select {
+	case err := <-result:
+		if err := gotest.Expect(err).Nil(); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("slave is not stopped")
+	}
+	if err := gotest.Expect(len(ms.Reports())).Eq(1); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,56 @@
+package slave
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/schnack/mbslave"
+	"rtu-test/e2e/fault"
+	"sync"
+)
+
+// pduHandler - вызывает обработчик mbslave для pdu. Pdu упаковывается в rtu adu,
+// чтобы использовать обработчики mbslave без изменений
+type pduHandler struct {
+	handler func(request mbslave.Request, response mbslave.Response)
+	// Ошибки, выбранные обработчиком для ответа
+	faults func() fault.Plan
+	// Обработчики не рассчитаны на параллельные запросы
+	mu sync.Mutex
+}
+
+func (ph *pduHandler) SetHandler(f func(request mbslave.Request, response mbslave.Response)) {
+	ph.handler = f
+}
+
+// SetFaults - источник ошибок для ответа, вызывается после обработчика
+func (ph *pduHandler) SetFaults(f func() fault.Plan) {
+	ph.faults = f
+}
+
+// handle - обрабатывает pdu и возвращает pdu ответа и ошибки, которые нужно в него внести
+func (ph *pduHandler) handle(slaveId uint8, pdu []byte) ([]byte, fault.Plan, error) {
+	adu := append([]byte{slaveId}, pdu...)
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, mbslave.CalcCRC(adu))
+	adu = append(adu, crc...)
+
+	request := mbslave.NewRtuRequest(adu)
+	response := mbslave.NewRtuResponse(request)
+	if ph.handler == nil {
+		return nil, nil, fmt.Errorf("handler is not set")
+	}
+	var plan fault.Plan
+	ph.mu.Lock()
+	ph.handler(request, response)
+	if ph.faults != nil {
+		plan = ph.faults()
+	}
+	ph.mu.Unlock()
+
+	answer, err := response.GetADU()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Убираем адрес и crc
+	return answer[1 : len(answer)-2], plan, nil
+}
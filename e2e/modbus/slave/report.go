@@ -5,10 +5,15 @@ import "rtu-test/e2e/common"
 // Отчет тестирования Modbus Slave
 type ReportSlaveTest struct {
 	Name                     string
+	SlaveId                  uint8
 	Pass                     bool
 	Skip                     string
 	ExpectedCoils            []common.ReportExpected
 	ExpectedDiscreteInput    []common.ReportExpected
 	ExpectedHoldingRegisters []common.ReportExpected
 	ExpectedInputRegisters   []common.ReportExpected
+	// Исключение, которым ответил тест
+	Exception string `json:",omitempty"`
+	// Ошибки, внесенные в ответ
+	Faults []string `json:",omitempty"`
 }
@@ -0,0 +1,58 @@
+package slave
+
+import (
+	"encoding/binary"
+	"github.com/schnack/mbslave"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/fault"
+	"time"
+)
+
+// rtuHandler - записывает adu запросов и ответов rtu транспорта и вносит ошибки в ответы.
+// Ответ с ошибками отправляется обработчиком, транспорт его не отправляет.
+// Транспорты tcp и ascii делают это сами
+func (ms *ModbusSlave) rtuHandler(rtu *mbslave.RtuTransport, handler func(mbslave.Request, mbslave.Response)) func(mbslave.Request, mbslave.Response) {
+	return func(request mbslave.Request, response mbslave.Response) {
+		if ms.traffic != nil {
+			ms.traffic.Frame(capture.RX, request.GetADU())
+		}
+		handler(request, response)
+		adu, err := response.GetADU()
+		if err != nil {
+			return
+		}
+		plan := ms.takePlan()
+		if len(plan) == 0 {
+			if ms.traffic != nil {
+				ms.traffic.Frame(capture.TX, adu)
+			}
+			return
+		}
+
+		response.Unanswered(true)
+		if f := plan.Get(fault.WrongId); f != nil {
+			adu[0] = f.WrongSlaveId(adu[0])
+			binary.LittleEndian.PutUint16(adu[len(adu)-2:], mbslave.CalcCRC(adu[:len(adu)-2]))
+		}
+		if plan.Get(fault.CorruptCrc) != nil {
+			adu[len(adu)-2] ^= 0xff
+			adu[len(adu)-1] ^= 0xff
+		}
+		frames, delay := plan.Apply(adu)
+		time.Sleep(delay)
+		for i, frame := range frames {
+			// Повторы разделены паузой, чтобы мастер принял их отдельными кадрами
+			if i > 0 {
+				time.Sleep(2 * rtu.SilentInterval())
+			}
+			if _, err := rtu.Port.Write(frame); err != nil {
+				ms.logger().Errorf("write answer: %s", err)
+				return
+			}
+			rtu.Log.Debugf("-> out raw(%03d): [% x]", len(frame), frame)
+			if ms.traffic != nil {
+				ms.traffic.Frame(capture.TX, frame)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package slave
+
+import (
+	"encoding/binary"
+	"github.com/schnack/gotest"
+	"github.com/schnack/mbslave"
+	"io"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/fault"
+	"testing"
+)
+
+func TestModbusSlave_rtuHandler(t *testing.T) {
+	mbslave.InoutSerialPort.Load()
+	defer mbslave.InoutSerialPort.Unload()
+
+	var param1 uint16 = 0x0102
+	id := uint8(5)
+	ms := &ModbusSlave{
+		SlaveId:          1,
+		Port:             "rtu",
+		SilentInterval:   "1ms",
+		HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &param1}},
+		Faults:           []*fault.Fault{{Type: fault.WrongId, SlaveId: &id}, {Type: fault.CorruptCrc}},
+	}
+	server, err := ms.getServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mbslave.InoutSerialPort.GetOut("rtu").Write([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01, 0x84, 0x0a})
+	if err := gotest.Expect(server.Listen()).Eq(io.EOF); err != nil {
+		t.Error(err)
+	}
+
+	// Ответ от устройства 5 с инвертированной контрольной суммой
+	expected := []byte{0x05, 0x03, 0x02, 0x01, 0x02}
+	crc := make([]byte, 2)
+	binary.LittleEndian.PutUint16(crc, mbslave.CalcCRC(expected)^0xffff)
+	if err := gotest.Expect(mbslave.InoutSerialPort.GetIn("rtu").Bytes()).Eq(append(expected, crc...)); err != nil {
+		t.Error(err)
+	}
+
+	// Запрос без теста попадает в отчет с внесенными ошибками
+	reports := ms.Reports()
+	if err := gotest.Expect(len(reports)).Eq(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(reports[0].Name).Eq("read holding registers 0x0000"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(reports[0].Faults).Eq([]string{"wrongId 5", "corruptCrc"}); err != nil {
+		t.Error(err)
+	}
+}
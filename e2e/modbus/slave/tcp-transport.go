@@ -0,0 +1,128 @@
+package slave
+
+import (
+	"encoding/binary"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/fault"
+	"sync"
+	"time"
+)
+
+const tcpHeaderSize = 7
+
+// TcpTransport - modbus tcp транспорт для mbslave.Server.
+// Кадры MBAP преобразуются в rtu adu, чтобы использовать обработчики mbslave без изменений
+type TcpTransport struct {
+	pduHandler
+	Address string
+	Log     logrus.FieldLogger
+	// Запись кадров, если задана
+	Capture capture.Capture
+	// Вызывается после открытия адреса
+	OnReady func()
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+func NewTcpTransport(address string) *TcpTransport {
+	return &TcpTransport{
+		Address: address,
+		Log:     logrus.StandardLogger(),
+	}
+}
+
+func (tt *TcpTransport) Listen() error {
+	listener, err := net.Listen("tcp", tt.Address)
+	if err != nil {
+		return err
+	}
+	tt.mu.Lock()
+	tt.listener = listener
+	tt.mu.Unlock()
+	defer listener.Close()
+	tt.Log.Debugf("start listing %s", listener.Addr())
+	if tt.OnReady != nil {
+		tt.OnReady()
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go tt.serve(conn)
+	}
+}
+
+// Addr - адрес на котором принимаются подключения
+func (tt *TcpTransport) Addr() net.Addr {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if tt.listener == nil {
+		return nil
+	}
+	return tt.listener.Addr()
+}
+
+// Close - прекращает прием подключений
+func (tt *TcpTransport) Close() error {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if tt.listener == nil {
+		return nil
+	}
+	return tt.listener.Close()
+}
+
+func (tt *TcpTransport) serve(conn net.Conn) {
+	defer conn.Close()
+	tt.Log.Debugf("connect %s", conn.RemoteAddr())
+	for {
+		header := make([]byte, tcpHeaderSize)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			tt.Log.Debugf("disconnect %s: %s", conn.RemoteAddr(), err)
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if length < 2 {
+			tt.Log.Debugf("frame damaged: [% x]", header)
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			tt.Log.Debugf("disconnect %s: %s", conn.RemoteAddr(), err)
+			return
+		}
+		tt.Log.Debugf("<- in  raw(%03d): [% x % x]", len(header)+len(pdu), header, pdu)
+		if tt.Capture != nil {
+			tt.Capture.Frame(capture.RX, append(append([]byte{}, header...), pdu...))
+		}
+
+		answer, plan, err := tt.handle(header[6], pdu)
+		if err != nil {
+			tt.Log.Debugf("no answer: %s", err)
+			continue
+		}
+		binary.BigEndian.PutUint16(header[4:6], uint16(len(answer)+1))
+		if f := plan.Get(fault.WrongId); f != nil {
+			header[6] = f.WrongSlaveId(header[6])
+		}
+		// В tcp нет контрольной суммы, corruptCrc не применяется
+		frames, delay := plan.Apply(append(header, answer...))
+		time.Sleep(delay)
+		for _, out := range frames {
+			if _, err := conn.Write(out); err != nil {
+				tt.Log.Debugf("disconnect %s: %s", conn.RemoteAddr(), err)
+				return
+			}
+			tt.Log.Debugf("-> out raw(%03d): [% x]", len(out), out)
+			if tt.Capture != nil {
+				tt.Capture.Frame(capture.TX, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package slave
+
+import (
+	"github.com/goburrow/modbus"
+	"github.com/schnack/gotest"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/modbus/master"
+	"testing"
+	"time"
+)
+
+func TestTcpTransport_Listen(t *testing.T) {
+	var param1 uint16 = 0x0102
+	var address uint16 = 0x0001
+	var expected uint16 = 0x0304
+	test := &ModbusSlaveTest{
+		Name:     "write",
+		Function: "WriteSingleRegister",
+		Address:  &address,
+		Expected: map[string][]*common.Value{
+			HoldingRegistersTable: {{Name: "param2", Address: "0x0001", Uint16: &expected}},
+		},
+	}
+	ms := &ModbusSlave{
+		Mode:             master.ModeTCP,
+		SlaveId:          1,
+		Port:             "127.0.0.1:0",
+		HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &param1}},
+		Tests:            []*ModbusSlaveTest{test},
+	}
+
+	server, err := ms.getServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := server.Transport.(*TcpTransport)
+	go func() { _ = server.Listen() }()
+	defer transport.Close()
+
+	for i := 0; transport.Addr() == nil && i < 100; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	handler := modbus.NewTCPClientHandler(transport.Addr().String())
+	handler.SlaveId = 1
+	handler.Timeout = time.Second
+	defer handler.Close()
+	client := modbus.NewClient(handler)
+
+	results, err := client.ReadHoldingRegisters(0, 1)
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(results).Eq([]byte{0x01, 0x02}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := client.WriteSingleRegister(1, 0x0304); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(ms.DataModel.GetHoldingRegisters(1)).Eq(uint16(0x0304)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(ms.currentTest).Eq(test); err != nil {
+		t.Error(err)
+	}
+
+	// Чужой адрес устройства остается без ответа
+	handler.SlaveId = 2
+	handler.Timeout = 100 * time.Millisecond
+	if _, err := client.ReadHoldingRegisters(0, 1); err == nil {
+		t.Error("expected timeout")
+	}
+}
@@ -0,0 +1,145 @@
+package slave
+
+import (
+	"fmt"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/fault"
+	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/registers"
+	"strings"
+)
+
+// Validate - проверка настроек после загрузки конфигурации
+func (ms *ModbusSlave) Validate() (errs []error) {
+	switch strings.ToLower(ms.Mode) {
+	case "", master.ModeRTU, master.ModeTCP, master.ModeASCII:
+	default:
+		errs = append(errs, common.NewFieldError("mode", "unknown mode %q", ms.Mode))
+	}
+	if err := capture.Validate(ms.Capture); err != nil {
+		errs = append(errs, common.NewFieldError("capture", "%s", err))
+	}
+
+	// В tcp нет контрольной суммы
+	if strings.ToLower(ms.Mode) == master.ModeTCP {
+		errs = append(errs, tcpFaults("faults", ms.Faults)...)
+		for i, test := range ms.Tests {
+			errs = append(errs, tcpFaults(fmt.Sprintf("tests.%d.faults", i), test.Faults)...)
+		}
+	}
+
+	if err := ms.WordOrder.Validate(); err != nil {
+		errs = append(errs, common.NewFieldError("wordOrder", "%s", err))
+	}
+	if common.ParseDuration(ms.Latency) < 0 {
+		errs = append(errs, common.NewFieldError("latency", "invalid latency %q", ms.Latency))
+	}
+	errs = append(errs, ms.validateUnits()...)
+
+	names := make(map[string]bool)
+	for _, test := range ms.Tests {
+		names[test.Name] = true
+	}
+	for i, test := range ms.Tests {
+		for j, next := range test.Next {
+			if !names[next] {
+				errs = append(errs, common.NewFieldError(fmt.Sprintf("tests.%d.next.%d", i, j), "test %s not found", next))
+			}
+		}
+	}
+	return errs
+}
+
+// Validate - проверка теста после загрузки конфигурации
+func (ms *ModbusSlaveTest) Validate() (errs []error) {
+	// Тест без функции выполняется только по autorun
+	if ms.Function != "" {
+		if ms.getFunction() == master.NilFunction {
+			errs = append(errs, common.NewFieldError("function", "unknown function %q", ms.Function))
+		} else if ms.Address == nil && ms.Register == "" {
+			errs = append(errs, common.NewFieldError("", "test %s: address is nil", ms.Name))
+		}
+	}
+	switch {
+	case ms.Exception != "" && ms.WriteError != "":
+		errs = append(errs, common.NewFieldError("exception", "exception and writeError are both set"))
+	case ms.Exception != "":
+		if _, ok := master.ExceptionCode(ms.Exception); !ok {
+			errs = append(errs, common.NewFieldError("exception", "unknown exception %q", ms.Exception))
+		}
+	case ms.WriteError != "":
+		if _, ok := master.ExceptionCode(ms.WriteError); !ok {
+			errs = append(errs, common.NewFieldError("writeError", "unknown exception %q", ms.WriteError))
+		}
+	}
+	for field, tables := range map[string]map[string][]*common.Value{
+		"expected":    ms.Expected,
+		"beforeWrite": ms.BeforeWrite,
+		"afterWrite":  ms.AfterWrite,
+	} {
+		for table := range tables {
+			switch table {
+			case CoilsTable, DiscreteInputTable, HoldingRegistersTable, InputRegistersTable:
+			default:
+				errs = append(errs, common.NewFieldError(field+"."+table, "unknown table %s", table))
+			}
+		}
+	}
+	return errs
+}
+
+// ResolveRegisters - переносит точки карты регистров в адреса и таблицы тестов шины
+func (ms *ModbusSlave) ResolveRegisters(m registers.Map) (errs []error) {
+	for i, test := range ms.Tests {
+		if test != nil && test.byRegisters() {
+			errs = append(errs, common.PrefixErrors(fmt.Sprintf("tests.%d", i), test.resolve(m))...)
+		}
+	}
+	for i, unit := range ms.Units {
+		if unit != nil {
+			errs = append(errs, common.PrefixErrors(fmt.Sprintf("units.%d", i), unit.ResolveRegisters(m))...)
+		}
+	}
+	return errs
+}
+
+// validateUnits - у устройств шины свои адреса, параметры порта задаются только у шины.
+// Тесты и таблицы устройств проверяются их собственным Validate
+func (ms *ModbusSlave) validateUnits() (errs []error) {
+	ids := map[uint8]bool{ms.SlaveId: true}
+	for i, unit := range ms.Units {
+		path := fmt.Sprintf("units.%d", i)
+		if unit.Mode != "" || unit.Port != "" || unit.BoundRate != 0 || unit.DataBits != 0 || unit.Parity != "" ||
+			unit.StopBits != 0 || unit.SilentInterval != "" || unit.Capture != "" {
+			errs = append(errs, common.NewFieldError(path, "port settings are set only for the bus"))
+		}
+		if len(unit.Units) > 0 {
+			errs = append(errs, common.NewFieldError(path+".units", "nested units are not supported"))
+		}
+		switch {
+		case unit.SlaveId == BroadcastId:
+			errs = append(errs, common.NewFieldError(path+".slaveId", "slave id 0 is reserved for broadcast"))
+		case ids[unit.SlaveId]:
+			errs = append(errs, common.NewFieldError(path+".slaveId", "slave id %d is used by several devices", unit.SlaveId))
+		}
+		ids[unit.SlaveId] = true
+
+		if strings.ToLower(ms.Mode) == master.ModeTCP {
+			errs = append(errs, tcpFaults(path+".faults", unit.Faults)...)
+			for j, test := range unit.Tests {
+				errs = append(errs, tcpFaults(fmt.Sprintf("%s.tests.%d.faults", path, j), test.Faults)...)
+			}
+		}
+	}
+	return errs
+}
+
+func tcpFaults(path string, faults []*fault.Fault) (errs []error) {
+	for i, f := range faults {
+		if f.Type == fault.CorruptCrc {
+			errs = append(errs, common.NewFieldError(fmt.Sprintf("%s.%d.type", path, i), "%s is not supported in tcp mode", f.Type))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"rtu-test/e2e/custom/slave"
+	"strings"
+)
+
+// customDecoder - разбирает кадры произвольного протокола по форматам slave:
+// read - запрос мастера, write - ответ, error - ответ с ошибкой
+type customDecoder struct {
+	frame *slave.CustomSlave
+}
+
+// decode - определяет формат кадра по стартовым и конечным байтам. Если под кадр подходят несколько
+// форматов с одинаковыми разделителями, выбирается первый со сходящейся контрольной суммой
+func (cd *customDecoder) decode(frame *Frame, adu []byte) error {
+	frame.Kind = KindUnknown
+	found := ""
+	for _, action := range cd.actions() {
+		start, _, _, end, err := cd.frame.ParseFormat(action)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(adu, start) || !bytes.HasSuffix(adu, end) {
+			continue
+		}
+		if found == "" {
+			found = action
+		}
+		pass, err := cd.frame.CheckCrc(action, adu)
+		if err != nil {
+			return err
+		}
+		if pass {
+			found = action
+			break
+		}
+	}
+	if found == "" {
+		return nil
+	}
+	crcOk, err := cd.frame.CheckCrc(found, adu)
+	if err != nil {
+		return err
+	}
+	data, err := cd.frame.ParseData(found, adu)
+	if err != nil {
+		return err
+	}
+	frame.Kind = strings.ToLower(found)
+	frame.CrcOk = crcOk
+	frame.Data = fmt.Sprintf("% x", data)
+	return nil
+}
+
+// actions - форматы заданные в конфигурации
+func (cd *customDecoder) actions() []string {
+	var actions []string
+	if len(cd.frame.ReadFormat) > 0 {
+		actions = append(actions, slave.ActionRead)
+	}
+	if len(cd.frame.WriteFormat) > 0 {
+		actions = append(actions, slave.ActionWrite)
+	}
+	if len(cd.frame.ErrorFormat) > 0 {
+		actions = append(actions, slave.ActionError)
+	}
+	return actions
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Тип кадра modbus
+const (
+	KindRequest   = "request"
+	KindResponse  = "response"
+	KindException = "exception"
+	KindUnknown   = "unknown"
+)
+
+// Frame - разобранный кадр. Сохраняется в файл JSON lines
+type Frame struct {
+	Time     time.Time
+	Protocol string
+	// modbus: request, response, exception, unknown. custom: read, write, error, unknown
+	Kind  string
+	Raw   string
+	CrcOk bool
+
+	SlaveId      *uint8   `json:",omitempty"`
+	Function     string   `json:",omitempty"`
+	FunctionCode uint8    `json:",omitempty"`
+	Address      *uint16  `json:",omitempty"`
+	Quantity     *uint16  `json:",omitempty"`
+	Values       []uint16 `json:",omitempty"`
+	Exception    string   `json:",omitempty"`
+	// Данные кадра без служебных полей
+	Data string `json:",omitempty"`
+}
+
+// tableHeader - заголовок таблицы кадров
+func tableHeader() string {
+	return fmt.Sprintf("%-15s  %-9s  %-3s  %-30s  %-5s  %s", "TIME", "KIND", "ID", "FUNCTION", "CRC", "DETAILS")
+}
+
+// Row - строка таблицы кадров
+func (f *Frame) Row() string {
+	id := ""
+	if f.SlaveId != nil {
+		id = fmt.Sprintf("%d", *f.SlaveId)
+	}
+	crc := "ok"
+	if !f.CrcOk {
+		crc = "bad"
+	}
+	var details []string
+	if f.Address != nil {
+		details = append(details, fmt.Sprintf("addr=0x%04x", *f.Address))
+	}
+	if f.Quantity != nil {
+		details = append(details, fmt.Sprintf("qty=%d", *f.Quantity))
+	}
+	if len(f.Values) > 0 {
+		details = append(details, fmt.Sprintf("values=%v", f.Values))
+	}
+	if f.Exception != "" {
+		details = append(details, f.Exception)
+	}
+	if f.Data != "" {
+		details = append(details, "data="+f.Data)
+	}
+	if f.Kind == KindUnknown {
+		details = append(details, "raw="+f.Raw)
+	}
+	return fmt.Sprintf("%-15s  %-9s  %-3s  %-30s  %-5s  %s",
+		f.Time.Format("15:04:05.000000"), f.Kind, id, f.Function, crc, strings.Join(details, " "))
+}
@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/schnack/mbslave"
+	"rtu-test/e2e/modbus/master"
+)
+
+// modbusDecoder - разбирает кадры modbus rtu. Запрос и ответ одной функции различаются
+// по предыдущему кадру: кадр после запроса к тому же устройству с той же функцией считается ответом
+type modbusDecoder struct {
+	pending *Frame
+}
+
+func (md *modbusDecoder) decode(frame *Frame, adu []byte) error {
+	md.decodeFrame(frame, adu)
+	return nil
+}
+
+func (md *modbusDecoder) decodeFrame(frame *Frame, adu []byte) {
+	frame.Kind = KindUnknown
+	// Адрес, функция и crc
+	if len(adu) < 4 {
+		md.pending = nil
+		return
+	}
+	frame.CrcOk = binary.LittleEndian.Uint16(adu[len(adu)-2:]) == mbslave.CalcCRC(adu[:len(adu)-2])
+	slaveId := adu[0]
+	frame.SlaveId = &slaveId
+	frame.FunctionCode = adu[1]
+	frame.Function = master.ModbusFunction(adu[1] &^ 0x80).String()
+	pdu := adu[2 : len(adu)-2]
+
+	pending := md.pending
+	md.pending = nil
+	if adu[1]&0x80 != 0 {
+		frame.Kind = KindException
+		if len(pdu) > 0 {
+			frame.Exception = master.ExceptionName(pdu[0])
+		}
+		return
+	}
+	if pending != nil && *pending.SlaveId == slaveId && pending.FunctionCode == adu[1] {
+		frame.Kind = KindResponse
+		md.decodeResponse(frame, pending, pdu)
+		return
+	}
+	frame.Kind = KindRequest
+	md.decodeRequest(frame, pdu)
+	md.pending = frame
+}
+
+func (md *modbusDecoder) decodeRequest(frame *Frame, pdu []byte) {
+	switch master.ModbusFunction(frame.FunctionCode) {
+	case master.ReadCoils, master.ReadDiscreteInputs, master.ReadHoldingRegisters, master.ReadInputRegisters:
+		if len(pdu) == 4 {
+			frame.Address, frame.Quantity = uint16At(pdu, 0), uint16At(pdu, 2)
+			return
+		}
+	case master.WriteSingleCoil, master.WriteSingleRegister:
+		if len(pdu) == 4 {
+			frame.Address = uint16At(pdu, 0)
+			frame.Values = []uint16{*uint16At(pdu, 2)}
+			return
+		}
+	case master.WriteMultipleCoils:
+		if len(pdu) >= 5 {
+			frame.Address, frame.Quantity = uint16At(pdu, 0), uint16At(pdu, 2)
+			frame.Values = bits(pdu[5:], *frame.Quantity)
+			return
+		}
+	case master.WriteMultipleRegisters:
+		if len(pdu) >= 5 {
+			frame.Address, frame.Quantity = uint16At(pdu, 0), uint16At(pdu, 2)
+			frame.Values = registers(pdu[5:])
+			return
+		}
+	case master.MaskWriteRegister:
+		if len(pdu) == 6 {
+			frame.Address = uint16At(pdu, 0)
+			frame.Values = []uint16{*uint16At(pdu, 2), *uint16At(pdu, 4)}
+			return
+		}
+	case master.ReadWriteMultipleRegisters:
+		if len(pdu) >= 9 {
+			// Адрес и количество чтения, записываемые значения
+			frame.Address, frame.Quantity = uint16At(pdu, 0), uint16At(pdu, 2)
+			frame.Values = registers(pdu[9:])
+			frame.Data = fmt.Sprintf("write addr=0x%04x qty=%d", *uint16At(pdu, 4), *uint16At(pdu, 6))
+			return
+		}
+	case master.ReadFIFOQueue:
+		if len(pdu) == 2 {
+			frame.Address = uint16At(pdu, 0)
+			return
+		}
+	}
+	frame.Data = fmt.Sprintf("% x", pdu)
+}
+
+func (md *modbusDecoder) decodeResponse(frame *Frame, request *Frame, pdu []byte) {
+	switch master.ModbusFunction(frame.FunctionCode) {
+	case master.ReadCoils, master.ReadDiscreteInputs:
+		if len(pdu) >= 1 {
+			frame.Address, frame.Quantity = request.Address, request.Quantity
+			quantity := uint16(len(pdu)-1) * 8
+			if request.Quantity != nil {
+				quantity = *request.Quantity
+			}
+			frame.Values = bits(pdu[1:], quantity)
+			return
+		}
+	case master.ReadHoldingRegisters, master.ReadInputRegisters, master.ReadWriteMultipleRegisters:
+		if len(pdu) >= 1 {
+			frame.Address, frame.Quantity = request.Address, request.Quantity
+			frame.Values = registers(pdu[1:])
+			return
+		}
+	case master.WriteSingleCoil, master.WriteSingleRegister:
+		if len(pdu) == 4 {
+			frame.Address = uint16At(pdu, 0)
+			frame.Values = []uint16{*uint16At(pdu, 2)}
+			return
+		}
+	case master.WriteMultipleCoils, master.WriteMultipleRegisters:
+		if len(pdu) == 4 {
+			frame.Address, frame.Quantity = uint16At(pdu, 0), uint16At(pdu, 2)
+			return
+		}
+	case master.MaskWriteRegister:
+		if len(pdu) == 6 {
+			frame.Address = uint16At(pdu, 0)
+			frame.Values = []uint16{*uint16At(pdu, 2), *uint16At(pdu, 4)}
+			return
+		}
+	case master.ReadFIFOQueue:
+		// Количество байт, количество значений, значения
+		if len(pdu) >= 4 {
+			frame.Address = request.Address
+			frame.Quantity = uint16At(pdu, 2)
+			frame.Values = registers(pdu[4:])
+			return
+		}
+	}
+	frame.Data = fmt.Sprintf("% x", pdu)
+}
+
+func uint16At(data []byte, index int) *uint16 {
+	value := binary.BigEndian.Uint16(data[index:])
+	return &value
+}
+
+func registers(data []byte) []uint16 {
+	values := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		values = append(values, binary.BigEndian.Uint16(data[i:]))
+	}
+	return values
+}
+
+func bits(data []byte, quantity uint16) []uint16 {
+	values := make([]uint16, 0, quantity)
+	for i := 0; i < int(quantity) && i/8 < len(data); i++ {
+		values = append(values, uint16(data[i/8]>>(i%8)&1))
+	}
+	return values
+}
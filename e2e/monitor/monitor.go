@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/custom/slave"
+	"rtu-test/e2e/transport"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Протоколы разбора кадров
+const (
+	ProtocolModbus = "modbus"
+	ProtocolCustom = "custom"
+)
+
+// Monitor - пассивный анализатор шины. Порт только читается, кадры разделяются по паузе
+type Monitor struct {
+	Port      string `yaml:"port"`
+	BoundRate int    `yaml:"boundRate"`
+	DataBits  int    `yaml:"dataBits"`
+	Parity    string `yaml:"parity"`
+	StopBits  int    `yaml:"stopBits"`
+	// Пауза между кадрами. По умолчанию 3.5 символа, для скорости выше 19200 - 1750us
+	SilentInterval string `yaml:"silentInterval"`
+	// modbus (по умолчанию) или custom
+	Protocol string `yaml:"protocol"`
+	// Описание кадров произвольного протокола в формате slave: const, staffing, len, crc и форматы
+	Custom *slave.CustomSlave `yaml:"custom"`
+	// Файл JSON lines с разобранными кадрами
+	Json string `yaml:"json"`
+
+	// Лог исполнителя. По умолчанию стандартный лог logrus
+	Log logrus.FieldLogger `yaml:"-"`
+	// Вызывается, когда порт открыт
+	OnReady func() `yaml:"-"`
+	// Вывод таблицы кадров. По умолчанию stdout
+	Out io.Writer `yaml:"-"`
+
+	mu     sync.Mutex
+	frames int
+	bad    int
+}
+
+// decoder - разбор кадра
+type decoder interface {
+	decode(frame *Frame, adu []byte) error
+}
+
+func (m *Monitor) logger() logrus.FieldLogger {
+	if m.Log == nil {
+		return logrus.StandardLogger()
+	}
+	return m.Log
+}
+
+// Validate - проверка настроек после загрузки конфигурации. Описание кадров custom проверяется отдельно
+func (m *Monitor) Validate() (errs []error) {
+	switch strings.ToLower(m.Protocol) {
+	case "", ProtocolModbus:
+	case ProtocolCustom:
+		if m.Custom == nil {
+			errs = append(errs, common.NewFieldError("custom", "frame description is required for custom protocol"))
+		}
+	default:
+		errs = append(errs, common.NewFieldError("protocol", "unknown protocol %q", m.Protocol))
+	}
+	return errs
+}
+
+// Stat - количество разобранных кадров и кадров с неверной контрольной суммой
+func (m *Monitor) Stat() (frames int, bad int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.frames, m.bad
+}
+
+func (m *Monitor) silentInterval() time.Duration {
+	if interval := common.ParseDuration(m.SilentInterval); interval != 0 {
+		return interval
+	}
+	if m.BoundRate <= 0 || m.BoundRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	// 3.5 символа по 11 бит
+	return time.Duration(38500000/m.BoundRate) * time.Microsecond
+}
+
+func (m *Monitor) newDecoder() decoder {
+	if strings.ToLower(m.Protocol) == ProtocolCustom {
+		return &customDecoder{frame: m.Custom}
+	}
+	return &modbusDecoder{}
+}
+
+// Run - открывает порт и выводит разобранные кадры до ошибки порта
+func (m *Monitor) Run() error {
+	out := m.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	var jsonLines *json.Encoder
+	if m.Json != "" {
+		file, err := os.Create(m.Json)
+		if err != nil {
+			return fmt.Errorf("open json: %s", err)
+		}
+		defer file.Close()
+		jsonLines = json.NewEncoder(file)
+	}
+
+	port, err := m.open()
+	if err != nil {
+		return err
+	}
+	defer port.Close()
+	if m.OnReady != nil {
+		m.OnReady()
+	}
+
+	protocol := strings.ToLower(m.Protocol)
+	if protocol == "" {
+		protocol = ProtocolModbus
+	}
+	d := m.newDecoder()
+	fmt.Fprintln(out, tableHeader())
+	return m.listen(port, func(t time.Time, adu []byte) error {
+		frame := &Frame{Time: t, Protocol: protocol, Raw: fmt.Sprintf("% x", adu)}
+		if err := d.decode(frame, adu); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.frames++
+		if !frame.CrcOk {
+			m.bad++
+		}
+		m.mu.Unlock()
+
+		fmt.Fprintln(out, frame.Row())
+		if jsonLines != nil {
+			if err := jsonLines.Encode(frame); err != nil {
+				m.logger().Errorf("write json: %s", err)
+			}
+		}
+		return nil
+	})
+}
+
+// readOnlyPort - порт монитора. Запись не доступна: монитор не передает в шину ни байта
+type readOnlyPort struct {
+	port transport.SerialPort
+}
+
+func (p *readOnlyPort) Read(b []byte) (int, error) {
+	return p.port.Read(b)
+}
+
+func (p *readOnlyPort) Close() error {
+	return p.port.Close()
+}
+
+// open - открывает порт только для чтения
+func (m *Monitor) open() (io.ReadCloser, error) {
+	port := transport.NewSerialPort(&transport.SerialPortConfig{
+		Port:     m.Port,
+		BaudRate: m.BoundRate,
+		DataBits: m.DataBits,
+		Parity:   m.Parity,
+		StopBits: m.StopBits,
+	})
+	if err := port.Connect(); err != nil {
+		return nil, err
+	}
+	return &readOnlyPort{port: port}, nil
+}
+
+// listen - собирает кадры из потока байт по паузе между ними. Время кадра - время первого байта.
+// Ошибка разбора кадра прекращает прослушивание
+func (m *Monitor) listen(port io.Reader, frame func(t time.Time, adu []byte) error) error {
+	silentInterval := m.silentInterval()
+	data := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() {
+		buff := make([]byte, 256)
+		for {
+			n, err := port.Read(buff)
+			if n > 0 {
+				data <- append([]byte{}, buff[:n]...)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	var adu []byte
+	var start time.Time
+	for {
+		select {
+		case chunk := <-data:
+			if len(adu) == 0 {
+				start = time.Now()
+			}
+			adu = append(adu, chunk...)
+		case err := <-errs:
+			if len(adu) > 0 {
+				if frameErr := frame(start, adu); frameErr != nil {
+					return frameErr
+				}
+			}
+			return err
+		case <-time.After(silentInterval):
+			if len(adu) > 0 {
+				if err := frame(start, adu); err != nil {
+					return err
+				}
+				adu = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package monitor
+
+import (
+	"bytes"
+	"github.com/schnack/gotest"
+	"io"
+	"rtu-test/e2e/custom/module"
+	"rtu-test/e2e/custom/slave"
+	"rtu-test/e2e/transport"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModbusDecoder(t *testing.T) {
+	d := &modbusDecoder{}
+
+	request := &Frame{}
+	d.decode(request, []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02, 0xc4, 0x0b})
+	if err := gotest.Expect(request.Kind).Eq(KindRequest); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(request.CrcOk).True(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(request.Function).Eq("read holding registers"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(*request.Quantity).Eq(uint16(2)); err != nil {
+		t.Error(err)
+	}
+
+	// Ответ той же функции от того же устройства
+	response := &Frame{}
+	d.decode(response, []byte{0x01, 0x03, 0x04, 0x00, 0x01, 0x00, 0x02, 0x2a, 0x32})
+	if err := gotest.Expect(response.Kind).Eq(KindResponse); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(response.CrcOk).True(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(*response.Address).Eq(uint16(0)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(response.Values).Eq([]uint16{1, 2}); err != nil {
+		t.Error(err)
+	}
+
+	exception := &Frame{}
+	d.decode(exception, []byte{0x01, 0x83, 0x02, 0xc0, 0xf1})
+	if err := gotest.Expect(exception.Kind).Eq(KindException); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(exception.Exception).Eq("illegal data address"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(exception.CrcOk).True(); err != nil {
+		t.Error(err)
+	}
+
+	bad := &Frame{}
+	d.decode(bad, []byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03, 0x00, 0x00})
+	if err := gotest.Expect(bad.CrcOk).False(); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(bad.Values).Eq([]uint16{3}); err != nil {
+		t.Error(err)
+	}
+
+	short := &Frame{}
+	d.decode(short, []byte{0x01})
+	if err := gotest.Expect(short.Kind).Eq(KindUnknown); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCustomDecoder(t *testing.T) {
+	d := &customDecoder{frame: &slave.CustomSlave{
+		Const: map[string][]string{
+			"start": {"0xFE"},
+			"read":  {"0x01"},
+			"write": {"0x02"},
+			"end":   {"0xFC"},
+		},
+		ReadFormat:  []string{"start", "read", "data#", "end"},
+		WriteFormat: []string{"start", "write", "data#", "end"},
+	}}
+
+	frame := &Frame{}
+	d.decode(frame, []byte{0xFE, 0x02, 0x0A, 0x0B, 0xFC})
+	if err := gotest.Expect(frame.Kind).Eq("write"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(frame.Data).Eq("0a 0b"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(frame.CrcOk).True(); err != nil {
+		t.Error(err)
+	}
+
+	frame = &Frame{}
+	d.decode(frame, []byte{0x00, 0x01})
+	if err := gotest.Expect(frame.Kind).Eq(KindUnknown); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCustomDecoder_SameDelimiters(t *testing.T) {
+	d := &customDecoder{frame: &slave.CustomSlave{
+		Const: map[string][]string{
+			"start": {"0xFE"},
+			"end":   {"0xFC"},
+		},
+		Crc: &module.Crc{
+			Algorithm: module.Mod256,
+			Read:      []string{"start", "data#"},
+			Write:     []string{"data#"},
+		},
+		ReadFormat:  []string{"start", "data#", "crc#", "end"},
+		WriteFormat: []string{"start", "data#", "crc#", "end"},
+	}}
+
+	for _, c := range []struct {
+		adu  []byte
+		kind string
+		crc  bool
+	}{
+		{[]byte{0xFE, 0x0A, 0x0B, 0x13, 0xFC}, "read", true},
+		{[]byte{0xFE, 0x0A, 0x0B, 0x15, 0xFC}, "write", true},
+		// Контрольная сумма не сходится ни с одним форматом
+		{[]byte{0xFE, 0x0A, 0x0B, 0x00, 0xFC}, "read", false},
+	} {
+		frame := &Frame{}
+		d.decode(frame, c.adu)
+		if err := gotest.Expect([]interface{}{frame.Kind, frame.CrcOk, frame.Data}).Eq([]interface{}{c.kind, c.crc, "0a 0b"}); err != nil {
+			t.Error(c.adu, err)
+		}
+	}
+}
+
+// chunkReader - выдает части с паузой между ними
+type chunkReader struct {
+	chunks [][]byte
+	pause  time.Duration
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.pause)
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestMonitor_Listen(t *testing.T) {
+	m := &Monitor{SilentInterval: "20ms"}
+	var frames [][]byte
+	port := &chunkReader{pause: 50 * time.Millisecond, chunks: [][]byte{{0x01, 0x02}, {0x03}}}
+	err := m.listen(port, func(_ time.Time, adu []byte) error {
+		frames = append(frames, adu)
+		return nil
+	})
+	if err := gotest.Expect(err).Eq(io.EOF); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(frames).Eq([][]byte{{0x01, 0x02}, {0x03}}); err != nil {
+		t.Error(err)
+	}
+
+	// Части без паузы собираются в один кадр
+	frames = nil
+	port = &chunkReader{chunks: [][]byte{{0x01, 0x02}, {0x03}}}
+	_ = m.listen(port, func(_ time.Time, adu []byte) error {
+		frames = append(frames, adu)
+		return nil
+	})
+	if err := gotest.Expect(frames).Eq([][]byte{{0x01, 0x02, 0x03}}); err != nil {
+		t.Error(err)
+	}
+}
+
+// busPort - порт шины, считает попытки записи
+type busPort struct {
+	chunkReader
+	writes int
+}
+
+func (p *busPort) Connect() error {
+	return nil
+}
+
+func (p *busPort) Close() error {
+	return nil
+}
+
+func (p *busPort) Write(b []byte) (int, error) {
+	p.writes++
+	return len(b), nil
+}
+
+func TestMonitor_RunReadOnly(t *testing.T) {
+	port := &busPort{chunkReader: chunkReader{chunks: [][]byte{{0x01, 0x03, 0x00, 0x00, 0x00, 0x01, 0x84, 0x0a}}}}
+	newSerialPort := transport.NewSerialPort
+	transport.NewSerialPort = func(config *transport.SerialPortConfig) transport.SerialPort {
+		return port
+	}
+	defer func() { transport.NewSerialPort = newSerialPort }()
+
+	m := &Monitor{Port: "bus", SilentInterval: "20ms", Out: &bytes.Buffer{}}
+	if err := gotest.Expect(m.Run()).Eq(io.EOF); err != nil {
+		t.Error(err)
+	}
+	frames, bad := m.Stat()
+	if err := gotest.Expect([]int{frames, bad}).Eq([]int{1, 0}); err != nil {
+		t.Error(err)
+	}
+	// Монитор ничего не пишет в порт
+	if err := gotest.Expect(port.writes).Eq(0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMonitor_Validate(t *testing.T) {
+	if err := gotest.Expect(len((&Monitor{}).Validate())).Eq(0); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len((&Monitor{Protocol: ProtocolCustom}).Validate())).Eq(1); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len((&Monitor{Protocol: "can"}).Validate())).Eq(1); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFrame_Row(t *testing.T) {
+	id := uint8(1)
+	address := uint16(0x10)
+	row := (&Frame{Kind: KindRequest, SlaveId: &id, Function: "read coils", CrcOk: true, Address: &address}).Row()
+	if err := gotest.Expect(strings.Contains(row, "addr=0x0010")).True(); err != nil {
+		t.Error(err)
+	}
+}
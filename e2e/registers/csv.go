@@ -0,0 +1,354 @@
+package registers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"rtu-test/e2e/common"
+	"strconv"
+	"strings"
+)
+
+// Point - строка карты регистров производителя
+type Point struct {
+	Register
+	Name        string
+	Description string
+	// Начальное значение для таблиц слейва и ожидаемое значение в тестах мастера
+	Value string
+}
+
+// Названия колонок в картах производителей
+var columns = map[string][]string{
+	"name":        {"name", "tag", "parameter", "signal", "имя", "название", "параметр"},
+	"table":       {"table", "area", "registertype", "object", "objecttype", "таблица", "область"},
+	"address":     {"address", "addr", "register", "offset", "адрес", "регистр"},
+	"type":        {"type", "datatype", "format", "тип", "типданных", "формат"},
+	"value":       {"value", "default", "initial", "initialvalue", "значение", "поумолчанию"},
+	"order":       {"wordorder", "byteorder", "order", "swap", "порядок", "порядокбайт"},
+	"scale":       {"scale", "multiplier", "factor", "gain", "coefficient", "множитель", "коэффициент"},
+	"offset":      {"valueoffset", "bias", "смещение"},
+	"unit":        {"unit", "units", "ед", "едизм", "единица", "единицы"},
+	"description": {"description", "comment", "note", "описание", "комментарий"},
+}
+
+// Синонимы таблиц, в том числе нотация Modicon (4x - holding registers)
+var tableNames = map[string]string{
+	"coil": Coils, "coils": Coils, "co": Coils, "0x": Coils, "0": Coils,
+	"discreteinput": DiscreteInput, "discreteinputs": DiscreteInput, "di": DiscreteInput, "1x": DiscreteInput, "1": DiscreteInput,
+	"holdingregister": HoldingRegisters, "holdingregisters": HoldingRegisters, "holding": HoldingRegisters, "hr": HoldingRegisters, "4x": HoldingRegisters, "4": HoldingRegisters,
+	"inputregister": InputRegisters, "inputregisters": InputRegisters, "ir": InputRegisters, "3x": InputRegisters, "3": InputRegisters,
+}
+
+// Синонимы типов из документации и ПЛК
+var typeNames = map[string]string{
+	"bool": Bool, "boolean": Bool, "bit": Bool,
+	"int16": Int16, "int": Int16, "short": Int16, "sint16": Int16, "s16": Int16,
+	"uint16": Uint16, "word": Uint16, "ushort": Uint16, "uint": Uint16, "u16": Uint16,
+	"int32": Int32, "dint": Int32, "long": Int32, "sint32": Int32, "s32": Int32,
+	"uint32": Uint32, "udint": Uint32, "dword": Uint32, "ulong": Uint32, "u32": Uint32,
+	"int64": Int64, "lint": Int64, "s64": Int64,
+	"uint64": Uint64, "ulint": Uint64, "lword": Uint64, "u64": Uint64,
+	"float32": Float32, "float": Float32, "real": Float32, "single": Float32,
+	"float64": Float64, "double": Float64, "lreal": Float64,
+}
+
+// ReadCSV - читает карту регистров из csv, выгруженного из таблицы. Разделитель (запятая, точка с запятой
+// или табуляция) определяется по заголовку. Обязательны колонки имени и адреса. Без колонки таблицы
+// пятизначные адреса разбираются в нотации Modicon (40001 - первый holding register)
+func ReadCSV(r io.Reader) (points []*Point, err error) {
+	reader := bufio.NewReader(r)
+	header, err := reader.Peek(reader.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	records := csv.NewReader(reader)
+	records.Comma = delimiter(string(header))
+	records.FieldsPerRecord = -1
+	records.LazyQuotes = true
+	records.TrimLeadingSpace = true
+
+	head, err := records.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %s", err)
+	}
+	index := make(map[string]int)
+	for i, title := range head {
+		title = normalize(strings.TrimPrefix(title, "\ufeff"))
+		for column, aliases := range columns {
+			if _, ok := index[column]; ok {
+				continue
+			}
+			for _, alias := range aliases {
+				if title == alias {
+					index[column] = i
+				}
+			}
+		}
+	}
+	for _, column := range []string{"name", "address"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("column %s not found in header", column)
+		}
+	}
+
+	// Номер строки файла, заголовок - первая строка
+	row := 1
+	for {
+		row++
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		p, err := newPoint(get)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", row, err)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func newPoint(get func(column string) string) (*Point, error) {
+	p := &Point{Name: get("name"), Description: get("description")}
+	p.Unit = get("unit")
+	if p.Name == "" {
+		return nil, fmt.Errorf("name is empty")
+	}
+
+	address := get("address")
+	if table := get("table"); table != "" {
+		var ok bool
+		if p.Table, ok = tableNames[normalize(table)]; !ok {
+			return nil, fmt.Errorf("unknown table %q", table)
+		}
+	} else if (len(address) == 5 || len(address) == 6) && strings.Trim(address, "0123456789") == "" {
+		// Modicon: первая цифра - таблица, остальные - номер регистра с единицы
+		table, ok := tableNames[address[:1]]
+		n, err := strconv.ParseUint(address[1:], 10, 16)
+		if !ok || err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid address %q", address)
+		}
+		p.Table = table
+		address = strconv.FormatUint(n-1, 10)
+	}
+	n, err := strconv.ParseUint(address, 0, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	a := uint16(n)
+	p.Address = &a
+
+	if t := get("type"); t != "" {
+		var ok bool
+		if p.Type, ok = typeNames[normalize(t)]; !ok {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+	}
+	if order := get("order"); order != "" {
+		p.WordOrder = common.WordOrder(strings.ToUpper(order))
+	}
+	for _, f := range []struct {
+		column string
+		value  **float64
+	}{{"scale", &p.Scale}, {"offset", &p.Offset}} {
+		s := get(f.column)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", f.column, s)
+		}
+		*f.value = &n
+	}
+	// Множитель 1 без смещения не меняет значение
+	if p.Scale != nil && *p.Scale == 1 && p.Offset == nil {
+		p.Scale = nil
+	}
+	if errs := p.Register.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	value := get("value")
+	if value == "" {
+		value = "0"
+		if p.kind() == Bool {
+			value = "false"
+		}
+	}
+	kind := p.kind()
+	if p.scaled() {
+		kind = Float64
+	}
+	if p.Value, err = literal(kind, value); err != nil {
+		return nil, fmt.Errorf("invalid value %q: %s", value, err)
+	}
+	return p, nil
+}
+
+// WriteSlave - конфигурация modbusSlave с начальными значениями всех таблиц
+func WriteSlave(w io.Writer, title string, points []*Point) error {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "---\nversion: 1.0.0\n\nname: %s\nconsole: stdout\nlog: stdout\nlogLvl: info\n\n", scalar(title+" simulator"))
+	fmt.Fprintf(b, "modbusSlave:\n  mode: rtu\n  slaveId: 0x01\n  port: /dev/ttyUSB0\n  boundRate: 9600\n  dataBits: 8\n  parity: N\n  stopBits: 1\n")
+	for _, table := range []string{Coils, DiscreteInput, HoldingRegisters, InputRegisters} {
+		var rows []*Point
+		for _, p := range points {
+			if p.table() == table {
+				rows = append(rows, p)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n  %s:\n", table)
+		for _, p := range rows {
+			fmt.Fprintf(b, "    - name: %s%s\n      address: 0x%04x\n%s", scalar(p.Name), p.comment(), *p.Address, p.fields("      "))
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteMaster - конфигурация modbusMaster с тестом чтения каждой точки. Ожидаются начальные значения,
+// группы тестов соответствуют таблицам
+func WriteMaster(w io.Writer, title string, points []*Point) error {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "---\nversion: 1.0.0\n\nname: %s\nconsole: stdout\nlog: stdout\nlogLvl: info\n\n", scalar(title))
+	fmt.Fprintf(b, "modbusMaster:\n  mode: rtu\n  slaveId: 0x01\n  port: /dev/ttyUSB0\n  boundRate: 9600\n  dataBits: 8\n  parity: N\n  stopBits: 1\n  timeout: 1s\n\n  tests:\n")
+	for _, group := range []struct{ table, name, function string }{
+		{Coils, "Coils", "read coils"},
+		{DiscreteInput, "DiscreteInputs", "read discrete inputs"},
+		{HoldingRegisters, "HoldingRegisters", "read holding registers"},
+		{InputRegisters, "InputRegisters", "read input registers"},
+	} {
+		var rows []*Point
+		for _, p := range points {
+			if p.table() == group.table {
+				rows = append(rows, p)
+			}
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n    %s:\n", group.name)
+		for _, p := range rows {
+			fmt.Fprintf(b, "      - name: %s%s\n        function: %s\n        address: 0x%04x\n        quantity: %d\n",
+				scalar(p.Name), p.comment(), group.function, *p.Address, p.Quantity())
+			fmt.Fprintf(b, "        expected:\n          - name: %s\n%s", scalar(p.Name), p.fields("            "))
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// comment - описание точки в комментарии
+func (p *Point) comment() string {
+	if s := strings.Join(strings.Fields(p.Description), " "); s != "" {
+		return "  # " + s
+	}
+	return ""
+}
+
+// fields - поля значения точки: порядок слов, масштаб, единица измерения и значение
+func (p *Point) fields(indent string) string {
+	b := &strings.Builder{}
+	if p.WordOrder != "" {
+		fmt.Fprintf(b, "%swordOrder: %s\n", indent, p.WordOrder)
+	}
+	kind := p.kind()
+	if p.scaled() {
+		fmt.Fprintf(b, "%sraw: %s\n", indent, kind)
+		if p.Scale != nil {
+			fmt.Fprintf(b, "%sscale: %s\n", indent, strconv.FormatFloat(*p.Scale, 'g', -1, 64))
+		}
+		if p.Offset != nil {
+			fmt.Fprintf(b, "%soffset: %s\n", indent, strconv.FormatFloat(*p.Offset, 'g', -1, 64))
+		}
+		kind = "float"
+	}
+	if p.Unit != "" {
+		fmt.Fprintf(b, "%sunit: %s\n", indent, scalar(p.Unit))
+	}
+	fmt.Fprintf(b, "%s%s: %s\n", indent, kind, p.Value)
+	return b.String()
+}
+
+// literal - значение в записи yaml для поля типа kind
+func literal(kind string, s string) (string, error) {
+	v := &common.Value{}
+	if err := setExact(v, kind, s); err != nil {
+		return "", err
+	}
+	switch kind {
+	case Bool:
+		return strconv.FormatBool(*v.Bool), nil
+	case Int16:
+		return strconv.FormatInt(int64(*v.Int16), 10), nil
+	case Uint16:
+		return strconv.FormatUint(uint64(*v.Uint16), 10), nil
+	case Int32:
+		return strconv.FormatInt(int64(*v.Int32), 10), nil
+	case Uint32:
+		return strconv.FormatUint(uint64(*v.Uint32), 10), nil
+	case Int64:
+		return strconv.FormatInt(*v.Int64, 10), nil
+	case Uint64:
+		return strconv.FormatUint(*v.Uint64, 10), nil
+	case Float32:
+		return strconv.FormatFloat(float64(*v.Float32), 'g', -1, 32), nil
+	}
+	return strconv.FormatFloat(*v.Float64, 'g', -1, 64), nil
+}
+
+// scalar - строка в записи yaml, при необходимости в кавычках
+func scalar(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// delimiter - разделитель колонок по строке заголовка
+func delimiter(header string) rune {
+	best, count := ',', strings.Count(header, ",")
+	for _, d := range []rune{';', '\t'} {
+		if n := strings.Count(header, string(d)); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+// normalize - название без регистра, пробелов и знаков для сравнения с синонимами
+func normalize(s string) string {
+	s = strings.ToLower(s)
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '_', '-', '.', '(', ')', '/':
+			return -1
+		}
+		return r
+	}, s)
+}
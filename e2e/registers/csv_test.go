@@ -0,0 +1,137 @@
+package registers
+
+import (
+	"bytes"
+	"github.com/schnack/gotest"
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+)
+
+const testCSV = "\ufeffName;Register type;Address;Data type;Default;Unit;Multiplier;Description\n" +
+	"Voltage L1;Input Register;0x0000;FLOAT;230.5;V;;Phase voltage\n" +
+	"Energy;3x;4;UDINT;123456;kWh;1;\"Total; active\"\n" +
+	";;;;;;;\n" +
+	"Setpoint;Holding;16;INT;-5;;;\n" +
+	"Relay;coil;0;bit;1;;;\n" +
+	"Serial;;40101;dword;42;;;\n" +
+	"Frequency;ir;6;word;50.01;Hz;0.01;\n"
+
+func TestReadCSV(t *testing.T) {
+	points, err := ReadCSV(strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type row struct {
+		Name, Table, Type, Value string
+		Address                  uint16
+	}
+	var rows []row
+	for _, p := range points {
+		rows = append(rows, row{p.Name, p.table(), p.kind(), p.Value, *p.Address})
+	}
+	if err := gotest.Expect(rows).Eq([]row{
+		{"Voltage L1", InputRegisters, Float32, "230.5", 0},
+		{"Energy", InputRegisters, Uint32, "123456", 4},
+		{"Setpoint", HoldingRegisters, Int16, "-5", 16},
+		{"Relay", Coils, Bool, "true", 0},
+		{"Serial", HoldingRegisters, Uint32, "42", 100},
+		{"Frequency", InputRegisters, Uint16, "50.01", 6},
+	}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(points[1].comment()).Eq("  # Total; active"); err != nil {
+		t.Error(err)
+	}
+	// Множитель 1 не делает значение масштабируемым
+	if err := gotest.Expect(points[1].fields("  ")).Eq("  unit: kWh\n  uint32: 123456\n"); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(points[5].fields("  ")).Eq("  raw: uint16\n  scale: 0.01\n  unit: Hz\n  float: 50.01\n"); err != nil {
+		t.Error(err)
+	}
+
+	// Запятая как разделитель, значения по умолчанию
+	points, err = ReadCSV(strings.NewReader("tag,addr\nstatus,0x0020\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect([]string{points[0].table(), points[0].kind(), points[0].Value}).
+		Eq([]string{HoldingRegisters, Uint16, "0"}); err != nil {
+		t.Error(err)
+	}
+
+	points, err = ReadCSV(strings.NewReader("tag,addr,type,word order\nvoltage,0,float,cdab\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(points[0].fields("  ")).Eq("  wordOrder: CDAB\n  float32: 0\n"); err != nil {
+		t.Error(err)
+	}
+
+	for _, data := range []string{
+		"name;type\nx;int16\n",
+		"name;address;type\nx;1;int8\n",
+		"name;address;table\nx;1;memory\n",
+		"name;address;type;value\nx;1;int16;40000\n",
+		"name;address\nx;70000\n",
+		"name;address;type;order\nx;1;float;abdc\n",
+		"name;address;scale\nx;1;ten\n",
+		"name;address;table;scale\nx;1;coil;0.1\n",
+	} {
+		if _, err := ReadCSV(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: error expected", data)
+		}
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	points, err := ReadCSV(strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var slave struct {
+		ModbusSlave map[string][]map[string]interface{} `yaml:"modbusSlave"`
+	}
+	b := &bytes.Buffer{}
+	if err := WriteSlave(b, "meter", points); err != nil {
+		t.Fatal(err)
+	}
+	// Настройки порта не являются списками и пропускаются при разборе
+	_ = yaml.Unmarshal(b.Bytes(), &slave)
+	if err := gotest.Expect(slave.ModbusSlave[InputRegisters]).Eq([]map[string]interface{}{
+		{"name": "Voltage L1", "address": 0, "unit": "V", "float32": 230.5},
+		{"name": "Energy", "address": 4, "unit": "kWh", "uint32": 123456},
+		{"name": "Frequency", "address": 6, "raw": "uint16", "scale": 0.01, "unit": "Hz", "float": 50.01},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	var master struct {
+		ModbusMaster struct {
+			Tests map[string][]struct {
+				Name     string                   `yaml:"name"`
+				Function string                   `yaml:"function"`
+				Address  uint16                   `yaml:"address"`
+				Quantity uint16                   `yaml:"quantity"`
+				Expected []map[string]interface{} `yaml:"expected"`
+			} `yaml:"tests"`
+		} `yaml:"modbusMaster"`
+	}
+	b.Reset()
+	if err := WriteMaster(b, "meter", points); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(b.Bytes(), &master); err != nil {
+		t.Fatal(err)
+	}
+	serial := master.ModbusMaster.Tests["HoldingRegisters"][1]
+	if err := gotest.Expect([]interface{}{serial.Function, serial.Address, serial.Quantity, serial.Expected[0]["uint32"]}).
+		Eq([]interface{}{"read holding registers", uint16(100), uint16(2), 42}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len(master.ModbusMaster.Tests)).Eq(3); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,479 @@
+package registers
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"math"
+	"rtu-test/e2e/common"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Таблицы modbus
+const (
+	Coils            = "coils"
+	DiscreteInput    = "discreteInput"
+	HoldingRegisters = "holdingRegisters"
+	InputRegisters   = "inputRegisters"
+)
+
+// Типы значений точек
+const (
+	Bool    = "bool"
+	Int16   = "int16"
+	Uint16  = "uint16"
+	Int32   = "int32"
+	Uint32  = "uint32"
+	Int64   = "int64"
+	Uint64  = "uint64"
+	Float32 = "float32"
+	Float64 = "float64"
+)
+
+// Максимальное количество бит и регистров в одном запросе чтения
+const (
+	MaxReadBits      = 2000
+	MaxReadRegisters = 125
+)
+
+// Map - карта регистров устройства: имя точки и ее расположение в таблицах modbus
+type Map map[string]*Register
+
+// Get - точка по имени. Точка с ошибками в настройках не возвращается
+func (m Map) Get(name string) (*Register, error) {
+	r, ok := m[name]
+	if !ok || r == nil {
+		return nil, fmt.Errorf("unknown register %s", name)
+	}
+	if len(r.Validate()) > 0 {
+		return nil, fmt.Errorf("register %s is invalid", name)
+	}
+	return r, nil
+}
+
+// Group - точки одной таблицы, идущие подряд, которые читаются одним запросом
+type Group struct {
+	Table    string
+	Address  uint16
+	Quantity uint16
+	Names    []string
+}
+
+// Groups - объединяет точки в запросы чтения. Таблицы идут в порядке первого упоминания,
+// точки внутри таблицы по адресу. Новый запрос начинается на разрыве адресов или
+// при превышении размера запроса
+func (m Map) Groups(names []string) (groups []Group, err error) {
+	var tables []string
+	byTable := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		r, err := m.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := byTable[r.table()]; !ok {
+			tables = append(tables, r.table())
+		}
+		byTable[r.table()] = append(byTable[r.table()], name)
+	}
+
+	for _, table := range tables {
+		points := byTable[table]
+		sort.SliceStable(points, func(i, j int) bool {
+			return *m[points[i]].Address < *m[points[j]].Address
+		})
+		limit := MaxReadRegisters
+		if table == Coils || table == DiscreteInput {
+			limit = MaxReadBits
+		}
+		for _, name := range points {
+			r := m[name]
+			last := len(groups) - 1
+			if last >= 0 && groups[last].Table == table &&
+				uint32(groups[last].Address)+uint32(groups[last].Quantity) == uint32(*r.Address) &&
+				int(groups[last].Quantity)+int(r.Quantity()) <= limit {
+				groups[last].Quantity += r.Quantity()
+				groups[last].Names = append(groups[last].Names, name)
+				continue
+			}
+			groups = append(groups, Group{Table: table, Address: *r.Address, Quantity: r.Quantity(), Names: []string{name}})
+		}
+	}
+	return groups, nil
+}
+
+// Register - точка карты регистров
+type Register struct {
+	// Таблица: coils, discreteInput, holdingRegisters или inputRegisters. По умолчанию holdingRegisters
+	Table   string  `yaml:"table"`
+	Address *uint16 `yaml:"address"`
+	// Тип значения. По умолчанию bool для coils и discreteInput, uint16 для регистров
+	Type string `yaml:"type"`
+	// Порядок слов 32 и 64 битных значений. По умолчанию порядок устройства
+	WordOrder common.WordOrder `yaml:"wordOrder"`
+	// Значение в инженерных единицах: raw * scale + offset. Ожидаемые значения задаются в инженерных единицах
+	Scale  *float64 `yaml:"scale"`
+	Offset *float64 `yaml:"offset"`
+	// Единица измерения для отчета
+	Unit string `yaml:"unit"`
+}
+
+// Validate - проверка точки после загрузки конфигурации
+func (r *Register) Validate() (errs []error) {
+	switch r.table() {
+	case Coils, DiscreteInput:
+		if r.kind() != Bool {
+			errs = append(errs, common.NewFieldError("type", "table %s supports only bool", r.table()))
+		}
+	case HoldingRegisters, InputRegisters:
+		switch r.kind() {
+		case Int16, Uint16, Int32, Uint32, Int64, Uint64, Float32, Float64:
+		default:
+			errs = append(errs, common.NewFieldError("type", "unknown type %q", r.Type))
+		}
+	default:
+		errs = append(errs, common.NewFieldError("table", "unknown table %q", r.Table))
+	}
+	if r.scaled() && r.kind() == Bool {
+		errs = append(errs, common.NewFieldError("scale", "type bool does not support scale and offset"))
+	}
+	if r.Scale != nil && *r.Scale == 0 {
+		errs = append(errs, common.NewFieldError("scale", "scale can not be zero"))
+	}
+	if err := r.WordOrder.Validate(); err != nil {
+		errs = append(errs, common.NewFieldError("wordOrder", "%s", err))
+	}
+	if r.Address == nil {
+		errs = append(errs, common.NewFieldError("", "address is nil"))
+	} else if uint32(*r.Address)+uint32(r.Quantity()) > math.MaxUint16+1 {
+		errs = append(errs, common.NewFieldError("address", "register is out of address space"))
+	}
+	return errs
+}
+
+// table - таблица точки с учетом значения по умолчанию
+func (r *Register) table() string {
+	if r.Table == "" {
+		return HoldingRegisters
+	}
+	return r.Table
+}
+
+// kind - тип значения с учетом значения по умолчанию
+func (r *Register) kind() string {
+	if r.Type != "" {
+		return r.Type
+	}
+	switch r.table() {
+	case Coils, DiscreteInput:
+		return Bool
+	}
+	return Uint16
+}
+
+// TableName - таблица точки
+func (r *Register) TableName() string {
+	return r.table()
+}
+
+// Quantity - количество бит для coils и discreteInput или регистров для остальных таблиц
+func (r *Register) Quantity() uint16 {
+	switch r.kind() {
+	case Int32, Uint32, Float32:
+		return 2
+	case Int64, Uint64, Float64:
+		return 4
+	}
+	return 1
+}
+
+// scaled - значение точки задано в инженерных единицах
+func (r *Register) scaled() bool {
+	return r.Scale != nil || r.Offset != nil
+}
+
+// value - значение точки без ожидаемого значения
+func (r *Register) value(name string) *common.Value {
+	v := &common.Value{Name: name, WordOrder: r.WordOrder, Unit: r.Unit}
+	if r.scaled() {
+		v.Raw, v.Scale, v.Offset = r.kind(), r.Scale, r.Offset
+	}
+	return v
+}
+
+// set - точное значение точки. Значение в инженерных единицах задается в float
+func (r *Register) set(v *common.Value, s string) error {
+	if !r.scaled() {
+		return setExact(v, r.kind(), s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	v.Float = &f
+	return err
+}
+
+// Value - значение для проверки точки. Без ожидаемого значения возвращается
+// нулевое значение нужного типа, которое используется только для разбора ответа
+func (r *Register) Value(name string, e *Expected) (*common.Value, error) {
+	v := r.value(name)
+	if e == nil {
+		return v, r.set(v, "0")
+	}
+	if len(e.unknown) > 0 {
+		return nil, fmt.Errorf("unknown field %s", strings.Join(e.unknown, ", "))
+	}
+	if err := r.expect(v, e); err != nil {
+		return nil, err
+	}
+	v.Tolerance, v.TolerancePercent, v.Not = e.Tolerance, e.TolerancePercent, e.Not
+	if errs := v.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return v, nil
+}
+
+// expect - точное значение, диапазон или список допустимых значений точки
+func (r *Register) expect(v *common.Value, e *Expected) error {
+	if len(e.OneOf) > 0 {
+		if e.Value != "" || e.Min != nil || e.Max != nil {
+			return fmt.Errorf("oneOf can not be used with value, min and max")
+		}
+		if r.kind() == Bool {
+			return fmt.Errorf("type bool does not support oneOf")
+		}
+		// Значения списка сравниваются в инженерных единицах, без масштаба - как есть
+		v.Raw, v.OneOf = r.kind(), e.OneOf
+		return nil
+	}
+	if e.Value != "" {
+		if e.Min != nil || e.Max != nil {
+			return fmt.Errorf("value can not be used with min and max")
+		}
+		return r.set(v, e.Value)
+	}
+	if e.Min == nil && e.Max == nil {
+		return fmt.Errorf("expected value is not set")
+	}
+	if r.scaled() {
+		if e.Min != nil && e.Max != nil && *e.Min > *e.Max {
+			return fmt.Errorf("min %g is greater than max %g", *e.Min, *e.Max)
+		}
+		v.MinFloat, v.MaxFloat = e.Min, e.Max
+		return nil
+	}
+	return setRange(v, r.kind(), e.Min, e.Max)
+}
+
+// WriteValue - значение для записи в точку. Адрес задается для записи в таблицы слейва
+func (r *Register) WriteValue(name string, value string) (*common.Value, error) {
+	v := r.value(name)
+	v.Address = fmt.Sprintf("0x%04x", *r.Address)
+	return v, r.set(v, value)
+}
+
+// Expected - ожидаемое значение точки: точное значение value, диапазон min..max или список oneOf.
+// Короткая запись voltage: 230 равнозначна voltage: {value: 230}
+type Expected struct {
+	Value string    `yaml:"value"`
+	Min   *float64  `yaml:"min"`
+	Max   *float64  `yaml:"max"`
+	OneOf []float64 `yaml:"oneOf"`
+	// Допуск точного значения и отрицание результата проверки
+	Tolerance        *float64 `yaml:"tolerance"`
+	TolerancePercent *float64 `yaml:"tolerancePercent"`
+	Not              bool     `yaml:"not"`
+
+	// Неизвестные поля. Выводятся при проверке точки
+	unknown []string
+}
+
+// UnmarshalYAML - короткая запись значения и проверка неизвестных полей
+func (e *Expected) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Value = node.Value
+		return nil
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			switch node.Content[i].Value {
+			case "value", "min", "max", "oneOf", "tolerance", "tolerancePercent", "not":
+			default:
+				e.unknown = append(e.unknown, node.Content[i].Value)
+			}
+		}
+	}
+	type expected Expected
+	return node.Decode((*expected)(e))
+}
+
+func setExact(v *common.Value, kind string, s string) error {
+	switch kind {
+	case Bool:
+		b, err := strconv.ParseBool(s)
+		v.Bool = &b
+		return err
+	case Int16:
+		n, err := strconv.ParseInt(s, 0, 16)
+		x := int16(n)
+		v.Int16 = &x
+		return err
+	case Uint16:
+		n, err := strconv.ParseUint(s, 0, 16)
+		x := uint16(n)
+		v.Uint16 = &x
+		return err
+	case Int32:
+		n, err := strconv.ParseInt(s, 0, 32)
+		x := int32(n)
+		v.Int32 = &x
+		return err
+	case Uint32:
+		n, err := strconv.ParseUint(s, 0, 32)
+		x := uint32(n)
+		v.Uint32 = &x
+		return err
+	case Int64:
+		n, err := strconv.ParseInt(s, 0, 64)
+		v.Int64 = &n
+		return err
+	case Uint64:
+		n, err := strconv.ParseUint(s, 0, 64)
+		v.Uint64 = &n
+		return err
+	case Float32:
+		n, err := strconv.ParseFloat(s, 32)
+		x := float32(n)
+		v.Float32 = &x
+		return err
+	case Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		v.Float64 = &n
+		return err
+	}
+	return fmt.Errorf("unknown type %q", kind)
+}
+
+func setRange(v *common.Value, kind string, min, max *float64) error {
+	integer := kind != Float32 && kind != Float64
+	var lowest, highest float64
+	switch kind {
+	case Int16:
+		lowest, highest = math.MinInt16, math.MaxInt16
+	case Uint16:
+		highest = math.MaxUint16
+	case Int32:
+		lowest, highest = math.MinInt32, math.MaxInt32
+	case Uint32:
+		highest = math.MaxUint32
+	case Int64:
+		// MaxInt64 и MaxUint64 в float64 округляются до 2^63 и 2^64 и не помещаются в тип,
+		// поэтому верхней границей служит ближайшее меньшее float64
+		lowest, highest = math.MinInt64, math.Nextafter(math.MaxInt64, 0)
+	case Uint64:
+		highest = math.Nextafter(math.MaxUint64, 0)
+	case Float32:
+		lowest, highest = -math.MaxFloat32, math.MaxFloat32
+	case Float64:
+		lowest, highest = -math.MaxFloat64, math.MaxFloat64
+	default:
+		return fmt.Errorf("type %s does not support min and max", kind)
+	}
+	lo, err := bound(min, lowest, highest, integer, math.Ceil)
+	if err != nil {
+		return err
+	}
+	hi, err := bound(max, lowest, highest, integer, math.Floor)
+	if err != nil {
+		return err
+	}
+	if lo != nil && hi != nil && *lo > *hi {
+		return fmt.Errorf("min %g is greater than max %g", *min, *max)
+	}
+
+	for _, b := range []struct {
+		f   *float64
+		min bool
+	}{{lo, true}, {hi, false}} {
+		if b.f == nil {
+			continue
+		}
+		f := *b.f
+		switch kind {
+		case Int16:
+			x := int16(f)
+			if b.min {
+				v.MinInt16 = &x
+			} else {
+				v.MaxInt16 = &x
+			}
+		case Uint16:
+			x := uint16(f)
+			if b.min {
+				v.MinUint16 = &x
+			} else {
+				v.MaxUint16 = &x
+			}
+		case Int32:
+			x := int32(f)
+			if b.min {
+				v.MinInt32 = &x
+			} else {
+				v.MaxInt32 = &x
+			}
+		case Uint32:
+			x := uint32(f)
+			if b.min {
+				v.MinUint32 = &x
+			} else {
+				v.MaxUint32 = &x
+			}
+		case Int64:
+			x := int64(f)
+			if b.min {
+				v.MinInt64 = &x
+			} else {
+				v.MaxInt64 = &x
+			}
+		case Uint64:
+			x := uint64(f)
+			if b.min {
+				v.MinUint64 = &x
+			} else {
+				v.MaxUint64 = &x
+			}
+		case Float32:
+			x := float32(f)
+			if b.min {
+				v.MinFloat32 = &x
+			} else {
+				v.MaxFloat32 = &x
+			}
+		case Float64:
+			if b.min {
+				v.MinFloat64 = &f
+			} else {
+				v.MaxFloat64 = &f
+			}
+		}
+	}
+	return nil
+}
+
+// bound - граница диапазона в пределах типа. Для целых типов граница округляется внутрь диапазона
+func bound(f *float64, lowest, highest float64, integer bool, round func(float64) float64) (*float64, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if *f < lowest || *f > highest {
+		return nil, fmt.Errorf("%g is out of type limits", *f)
+	}
+	b := *f
+	if integer {
+		b = round(b)
+	}
+	return &b, nil
+}
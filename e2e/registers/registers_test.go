@@ -0,0 +1,186 @@
+package registers
+
+import (
+	"github.com/schnack/gotest"
+	"gopkg.in/yaml.v3"
+	"rtu-test/e2e/common"
+	"testing"
+)
+
+func testMap(t *testing.T) Map {
+	var m Map
+	config := `
+voltage: {address: 0x0010, type: float32, unit: V, wordOrder: CDAB}
+current: {address: 0x0012, type: float32}
+status: {address: 0x0020}
+power: {table: inputRegisters, address: 0x0014, type: int32}
+alarm: {table: coils, address: 3}
+frequency: {table: inputRegisters, address: 0x0030, scale: 0.01, unit: Hz}
+ready: {table: coils, address: 4}
+`
+	if err := yaml.Unmarshal([]byte(config), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMap_Groups(t *testing.T) {
+	m := testMap(t)
+	groups, err := m.Groups([]string{"status", "ready", "current", "power", "voltage", "alarm", "current"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(groups).Eq([]Group{
+		{Table: HoldingRegisters, Address: 0x0010, Quantity: 4, Names: []string{"voltage", "current"}},
+		{Table: HoldingRegisters, Address: 0x0020, Quantity: 1, Names: []string{"status"}},
+		{Table: Coils, Address: 3, Quantity: 2, Names: []string{"alarm", "ready"}},
+		{Table: InputRegisters, Address: 0x0014, Quantity: 2, Names: []string{"power"}},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := m.Groups([]string{"voltage", "humidity"}); err == nil {
+		t.Error("unknown register is grouped")
+	}
+}
+
+func TestRegister_Value(t *testing.T) {
+	m := testMap(t)
+	var expected map[string]*Expected
+	config := `
+voltage: {min: 220, max: 240}
+status: 0x02
+power: {min: -10.5}
+alarm: true
+`
+	if err := yaml.Unmarshal([]byte(config), &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := m["voltage"].Value("voltage", expected["voltage"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(v.Type()).Eq(common.Float32Range); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect([]float32{*v.MinFloat32, *v.MaxFloat32}).Eq([]float32{220, 240}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect([]string{v.Name, v.Unit}).Eq([]string{"voltage", "V"}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(v.WordOrder).Eq(common.CDAB); err != nil {
+		t.Error(err)
+	}
+
+	v, _ = m["status"].Value("status", expected["status"])
+	if err := gotest.Expect(*v.Uint16).Eq(uint16(2)); err != nil {
+		t.Error(err)
+	}
+
+	// Граница целого типа округляется внутрь диапазона
+	v, _ = m["power"].Value("power", expected["power"])
+	if err := gotest.Expect(*v.MinInt32).Eq(int32(-10)); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(v.MaxInt32 == nil).True(); err != nil {
+		t.Error(err)
+	}
+
+	v, _ = m["alarm"].Value("alarm", expected["alarm"])
+	if err := gotest.Expect(*v.Bool).True(); err != nil {
+		t.Error(err)
+	}
+
+	// Значение в инженерных единицах
+	v, err = m["frequency"].Value("frequency", &Expected{Value: "50.01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect([]interface{}{v.Raw, *v.Scale, *v.Float, v.Type()}).Eq([]interface{}{Uint16, 0.01, 50.01, common.Uint16}); err != nil {
+		t.Error(err)
+	}
+	v, _ = m["frequency"].Value("frequency", &Expected{Min: func() *float64 { f := 49.5; return &f }()})
+	if err := gotest.Expect([]interface{}{*v.MinFloat, v.MaxFloat == nil}).Eq([]interface{}{49.5, true}); err != nil {
+		t.Error(err)
+	}
+
+	// Допуск, список значений и отрицание
+	config = `
+voltage: {value: 230, tolerancePercent: 2}
+status: {oneOf: [1, 2, 4], not: true}
+alarm: {value: true, tolerance: 1}
+`
+	expected = nil
+	if err := yaml.Unmarshal([]byte(config), &expected); err != nil {
+		t.Fatal(err)
+	}
+	v, _ = m["voltage"].Value("voltage", expected["voltage"])
+	if err := gotest.Expect([]float64{float64(*v.Float32), *v.TolerancePercent}).Eq([]float64{230, 2}); err != nil {
+		t.Error(err)
+	}
+	v, _ = m["status"].Value("status", expected["status"])
+	if err := gotest.Expect([]interface{}{v.Raw, v.OneOf, v.Not}).Eq([]interface{}{Uint16, []float64{1, 2, 4}, true}); err != nil {
+		t.Error(err)
+	}
+	if _, err := m["alarm"].Value("alarm", expected["alarm"]); err == nil {
+		t.Error("tolerance of bool point is accepted")
+	}
+
+	// Без ожидаемого значения точка только читается
+	v, _ = m["current"].Value("current", nil)
+	if err := gotest.Expect(v.Type()).Eq(common.Float32); err != nil {
+		t.Error(err)
+	}
+
+	for name, e := range map[string]*Expected{
+		"status": {Min: func() *float64 { f := 70000.0; return &f }()},
+		"alarm":  {Value: "yes"},
+		"power":  {},
+	} {
+		if _, err := m[name].Value(name, e); err == nil {
+			t.Errorf("%s: error expected", name)
+		}
+	}
+
+	// Границы 2^63 и 2^64 за пределами int64 и uint64
+	address := uint16(0x0100)
+	for _, c := range []struct {
+		kind  string
+		max   string
+		valid bool
+	}{
+		{Int64, "9223372036854775807", false},
+		{Int64, "9223372036854774784", true},
+		{Uint64, "18446744073709551615", false},
+		{Uint64, "18446744073709549568", true},
+	} {
+		var e Expected
+		if err := yaml.Unmarshal([]byte("{max: "+c.max+"}"), &e); err != nil {
+			t.Fatal(err)
+		}
+		_, err := (&Register{Table: HoldingRegisters, Address: &address, Type: c.kind}).Value("counter", &e)
+		if err := gotest.Expect(err == nil).Eq(c.valid); err != nil {
+			t.Error(c.kind, c.max, err)
+		}
+	}
+}
+
+func TestRegister_Validate(t *testing.T) {
+	address := uint16(0xffff)
+	for _, r := range []*Register{
+		{Table: "holding", Address: &address},
+		{Table: Coils, Address: &address, Type: Uint16},
+		{Address: &address, Type: Float32},
+		{Type: "int8"},
+		{Table: Coils, Address: &address, Scale: func() *float64 { f := 0.1; return &f }()},
+	} {
+		if err := gotest.Expect(len(r.Validate()) > 0).True(); err != nil {
+			t.Error(err, r)
+		}
+	}
+	if err := gotest.Expect(len((&Register{Address: &address}).Validate())).Eq(0); err != nil {
+		t.Error(err)
+	}
+}
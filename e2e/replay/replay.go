@@ -0,0 +1,285 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/sirupsen/logrus"
+	"os"
+	"rtu-test/e2e/capture"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/transport"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Направление записанных запросов
+const (
+	// Запись сделана мастером: запросы отправлены, ответы приняты
+	RequestsTX = "tx"
+	// Запись сделана слейвом: запросы приняты, ответы отправлены
+	RequestsRX = "rx"
+)
+
+// Replay - имитирует устройство по записи обмена в hex логе (capture: hex=path).
+// Входящий запрос сравнивается с записанными запросами и в ответ отправляется записанный ответ
+type Replay struct {
+	Port      string `yaml:"port"`
+	BoundRate int    `yaml:"boundRate"`
+	DataBits  int    `yaml:"dataBits"`
+	Parity    string `yaml:"parity"`
+	StopBits  int    `yaml:"stopBits"`
+	// Пауза после которой принятые байты считаются кадром без ответа
+	SilentInterval string `yaml:"silentInterval"`
+	// Hex лог записи
+	File string `yaml:"file"`
+	// tx (по умолчанию) или rx
+	Requests string `yaml:"requests"`
+	// Отвечать с задержкой из записи
+	Timing bool `yaml:"timing"`
+
+	// Лог исполнителя. По умолчанию стандартный лог logrus
+	Log logrus.FieldLogger `yaml:"-"`
+	// Вызывается, когда порт открыт и слейв готов принимать запросы
+	OnReady func() `yaml:"-"`
+
+	mu     sync.Mutex
+	report Report
+}
+
+// Report - результат воспроизведения
+type Report struct {
+	// Количество запросов на которые отправлен записанный ответ
+	Matched int
+	// Запросы отсутствующие в записи
+	Unmatched []string `json:",omitempty"`
+}
+
+// Exchange - записанный запрос и ответ на него
+type Exchange struct {
+	Request  []byte
+	Response []byte
+	// Время от запроса до ответа
+	Delay time.Duration
+}
+
+func (r *Replay) logger() logrus.FieldLogger {
+	if r.Log == nil {
+		return logrus.StandardLogger()
+	}
+	return r.Log
+}
+
+// Validate - проверка настроек после загрузки конфигурации
+func (r *Replay) Validate() (errs []error) {
+	if r.File == "" {
+		errs = append(errs, common.NewFieldError("file", "hex log is not specified"))
+	}
+	switch strings.ToLower(r.Requests) {
+	case "", RequestsTX, RequestsRX:
+	default:
+		errs = append(errs, common.NewFieldError("requests", "unknown value %q", r.Requests))
+	}
+	return errs
+}
+
+// Report - результат воспроизведения на текущий момент
+func (r *Replay) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.Unmatched = append([]string{}, r.report.Unmatched...)
+	return report
+}
+
+// Load - читает запись и собирает пары запрос-ответ
+func (r *Replay) Load() ([]Exchange, error) {
+	file, err := os.Open(r.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := capture.ReadHex(file)
+	if err != nil {
+		return nil, err
+	}
+	requests := capture.TX
+	if strings.ToLower(r.Requests) == RequestsRX {
+		requests = capture.RX
+	}
+	return Exchanges(records, requests, r.silentInterval()), nil
+}
+
+// silentInterval - пауза между кадрами, по умолчанию 50ms
+func (r *Replay) silentInterval() time.Duration {
+	if silentInterval := common.ParseDuration(r.SilentInterval); silentInterval > 0 {
+		return silentInterval
+	}
+	return 50 * time.Millisecond
+}
+
+// Exchanges - пары запрос-ответ. Каждый кадр в направлении запросов начинает новую пару,
+// кадры в обратном направлении до следующего запроса составляют ответ. Записи в одном направлении
+// с паузой меньше silentInterval - части одного кадра и объединяются
+func Exchanges(records []capture.Record, requests capture.Direction, silentInterval time.Duration) []Exchange {
+	var exchanges []Exchange
+	var start time.Time
+	for i, record := range records {
+		continued := i > 0 && records[i-1].Direction == record.Direction && record.Time.Sub(records[i-1].Time) < silentInterval
+		if record.Direction == requests {
+			if continued && len(exchanges) > 0 {
+				last := &exchanges[len(exchanges)-1]
+				last.Request = append(last.Request, record.Data...)
+			} else {
+				exchanges = append(exchanges, Exchange{Request: append([]byte{}, record.Data...)})
+			}
+			// Задержка ответа отсчитывается от конца запроса
+			start = record.Time
+			continue
+		}
+		// Ответ без запроса в начале записи пропускается
+		if len(exchanges) == 0 {
+			continue
+		}
+		last := &exchanges[len(exchanges)-1]
+		if len(last.Response) == 0 {
+			last.Delay = record.Time.Sub(start)
+		}
+		last.Response = append(last.Response, record.Data...)
+	}
+	return exchanges
+}
+
+// Run - открывает порт и отвечает на запросы по записи
+func (r *Replay) Run() error {
+	exchanges, err := r.Load()
+	if err != nil {
+		return common.NewConfigError("replay %s: %s", r.File, err)
+	}
+	if len(exchanges) == 0 {
+		return common.NewConfigError("replay %s: no requests recorded", r.File)
+	}
+	silentInterval := r.silentInterval()
+
+	port := transport.NewSerialPort(&transport.SerialPortConfig{
+		Port:     r.Port,
+		BaudRate: r.BoundRate,
+		DataBits: r.DataBits,
+		Parity:   r.Parity,
+		StopBits: r.StopBits,
+	})
+	if err := port.Connect(); err != nil {
+		return err
+	}
+	defer port.Close()
+	if r.OnReady != nil {
+		r.OnReady()
+	}
+	r.logger().Debugf("replay %s: %d requests", r.File, len(exchanges))
+
+	p := newPlayer(exchanges)
+	data, errs := readChan(port)
+	var buff []byte
+	for {
+		select {
+		case chunk := <-data:
+			buff = append(buff, chunk...)
+			exchange, trash := p.match(buff)
+			if exchange == nil {
+				continue
+			}
+			if len(trash) > 0 {
+				r.logger().Debugf("Drop the trash: % 02x", trash)
+			}
+			buff = nil
+			if err := r.answer(port, exchange); err != nil {
+				return err
+			}
+		case err := <-errs:
+			return err
+		case <-time.After(silentInterval):
+			if len(buff) == 0 {
+				continue
+			}
+			r.logger().Warnf("Request not found in record: % 02x", buff)
+			r.mu.Lock()
+			r.report.Unmatched = append(r.report.Unmatched, hex.EncodeToString(buff))
+			r.mu.Unlock()
+			buff = nil
+		}
+	}
+}
+
+// answer - отправляет записанный ответ
+func (r *Replay) answer(port transport.SerialPort, exchange *Exchange) error {
+	r.mu.Lock()
+	r.report.Matched++
+	r.mu.Unlock()
+	if len(exchange.Response) == 0 {
+		r.logger().Infof("Request % 02x: no answer in record", exchange.Request)
+		return nil
+	}
+	if r.Timing {
+		time.Sleep(exchange.Delay)
+	}
+	r.logger().Infof("Request % 02x: answer % 02x", exchange.Request, exchange.Response)
+	_, err := port.Write(exchange.Response)
+	return err
+}
+
+// readChan - чтение порта в отдельной горутине, чтобы отслеживать паузы между кадрами
+func readChan(port transport.SerialPort) (<-chan []byte, <-chan error) {
+	data := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() {
+		buff := make([]byte, 256)
+		for {
+			n, err := port.Read(buff)
+			if n > 0 {
+				data <- append([]byte{}, buff[:n]...)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	return data, errs
+}
+
+// player - выбирает записанные ответы. Одинаковые запросы получают ответы по очереди записи,
+// после последнего ответа очередь начинается сначала
+type player struct {
+	exchanges []Exchange
+	next      map[string]int
+}
+
+func newPlayer(exchanges []Exchange) *player {
+	return &player{exchanges: exchanges, next: make(map[string]int)}
+}
+
+// match - ищет запрос в конце буфера. Байты перед запросом возвращаются как мусор
+func (p *player) match(buff []byte) (*Exchange, []byte) {
+	var found []int
+	for i, exchange := range p.exchanges {
+		if !bytes.HasSuffix(buff, exchange.Request) {
+			continue
+		}
+		// Предпочитаем самый длинный запрос
+		if len(found) > 0 && len(p.exchanges[found[0]].Request) != len(exchange.Request) {
+			if len(p.exchanges[found[0]].Request) > len(exchange.Request) {
+				continue
+			}
+			found = nil
+		}
+		found = append(found, i)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	key := string(p.exchanges[found[0]].Request)
+	index := found[p.next[key]%len(found)]
+	p.next[key]++
+	exchange := &p.exchanges[index]
+	return exchange, buff[:len(buff)-len(exchange.Request)]
+}
@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"github.com/schnack/gotest"
+	"rtu-test/e2e/capture"
+	"testing"
+	"time"
+)
+
+func TestExchanges(t *testing.T) {
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []capture.Record{
+		// Ответ без запроса пропускается
+		{Time: start, Direction: capture.RX, Data: []byte{0xFF}},
+		{Time: start, Direction: capture.TX, Data: []byte{0x01}},
+		{Time: start.Add(10 * time.Millisecond), Direction: capture.RX, Data: []byte{0x02}},
+		{Time: start.Add(12 * time.Millisecond), Direction: capture.RX, Data: []byte{0x03}},
+		// Запрос без ответа
+		{Time: start.Add(20 * time.Millisecond), Direction: capture.TX, Data: []byte{0x04}},
+	}
+
+	exchanges := Exchanges(records, capture.TX, 0)
+	if err := gotest.Expect(len(exchanges)).Eq(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(exchanges[0].Response).Eq([]byte{0x02, 0x03}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(exchanges[0].Delay).Eq(10 * time.Millisecond); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(len(exchanges[1].Response)).Eq(0); err != nil {
+		t.Error(err)
+	}
+
+	// Запись слейва: запросы приняты
+	exchanges = Exchanges(records, capture.RX, 0)
+	if err := gotest.Expect(exchanges[0].Request).Eq([]byte{0xFF}); err != nil {
+		t.Error(err)
+	}
+
+	// Запрос, записанный частями с паузой меньше silentInterval
+	records = []capture.Record{
+		{Time: start, Direction: capture.RX, Data: []byte{0x01, 0x03}},
+		{Time: start.Add(2 * time.Millisecond), Direction: capture.RX, Data: []byte{0x00, 0x00}},
+		{Time: start.Add(4 * time.Millisecond), Direction: capture.RX, Data: []byte{0x00, 0x01}},
+		{Time: start.Add(10 * time.Millisecond), Direction: capture.TX, Data: []byte{0x01, 0x03, 0x02}},
+		{Time: start.Add(11 * time.Millisecond), Direction: capture.TX, Data: []byte{0x00, 0x2a}},
+		// Следующий запрос после паузы
+		{Time: start.Add(100 * time.Millisecond), Direction: capture.RX, Data: []byte{0x01, 0x03}},
+		{Time: start.Add(200 * time.Millisecond), Direction: capture.RX, Data: []byte{0x01, 0x04}},
+	}
+	exchanges = Exchanges(records, capture.RX, 5*time.Millisecond)
+	if err := gotest.Expect(len(exchanges)).Eq(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(exchanges[0].Request).Eq([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(exchanges[0].Response).Eq([]byte{0x01, 0x03, 0x02, 0x00, 0x2a}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(exchanges[0].Delay).Eq(6 * time.Millisecond); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect([][]byte{exchanges[1].Request, exchanges[2].Request}).Eq([][]byte{{0x01, 0x03}, {0x01, 0x04}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlayer_match(t *testing.T) {
+	p := newPlayer([]Exchange{
+		{Request: []byte{0x01, 0x03}, Response: []byte{0xA1}},
+		{Request: []byte{0x02}, Response: []byte{0xB1}},
+		{Request: []byte{0x01, 0x03}, Response: []byte{0xA2}},
+		{Request: []byte{0x01, 0x02, 0x03}, Response: []byte{0xC1}},
+	})
+
+	// Одинаковые запросы получают ответы по очереди
+	for _, expected := range []byte{0xA1, 0xA2, 0xA1} {
+		exchange, _ := p.match([]byte{0x01, 0x03})
+		if err := gotest.Expect(exchange.Response).Eq([]byte{expected}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Мусор перед запросом отбрасывается
+	exchange, trash := p.match([]byte{0xFF, 0x02})
+	if err := gotest.Expect(exchange.Response).Eq([]byte{0xB1}); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(trash).Eq([]byte{0xFF}); err != nil {
+		t.Error(err)
+	}
+
+	// Самый длинный запрос
+	exchange, _ = p.match([]byte{0x01, 0x02, 0x03})
+	if err := gotest.Expect(exchange.Response).Eq([]byte{0xC1}); err != nil {
+		t.Error(err)
+	}
+
+	if exchange, _ := p.match([]byte{0x05}); exchange != nil {
+		t.Error("expected no match")
+	}
+}
+
+func TestReplay_Validate(t *testing.T) {
+	r := &Replay{Requests: "both"}
+	if err := gotest.Expect(len(r.Validate())).Eq(2); err != nil {
+		t.Error(err)
+	}
+}
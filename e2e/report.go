@@ -0,0 +1,146 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	slave2 "rtu-test/e2e/custom/slave"
+	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/modbus/slave"
+	"rtu-test/e2e/replay"
+	"strings"
+	"time"
+)
+
+const (
+	ReportJUnit = "junit"
+	ReportJSON  = "json"
+)
+
+// Report - сводный отчет о запуске для сохранения в файл
+type Report struct {
+	Name         string
+	Description  string
+	Time         time.Time
+	Master       *master.ReportGroups            `json:",omitempty"`
+	CustomMaster *master.ReportGroups            `json:",omitempty"`
+	ModbusSlave  []slave.ReportSlaveTest         `json:",omitempty"`
+	CustomSlave  []*slave2.ReportCustomSlaveTest `json:",omitempty"`
+	Replay       *replay.Report                  `json:",omitempty"`
+}
+
+// NewReport - отчет о запуске всех режимов устройства
+func (d *Device) NewReport() Report {
+	report := Report{
+		Name:         d.Name,
+		Description:  d.Description,
+		Time:         time.Now(),
+		Master:       d.modbusMasterReport,
+		CustomMaster: d.customMasterReport,
+	}
+	if d.ModbusSlave != nil {
+		report.ModbusSlave = d.ModbusSlave.Reports()
+	}
+	if d.CustomSlave != nil {
+		report.CustomSlave = d.CustomSlave.Reports()
+	}
+	if d.Replay != nil {
+		replayReport := d.Replay.Report()
+		report.Replay = &replayReport
+	}
+	return report
+}
+
+// WriteReports - сохраняет отчет в файлы заданные в report в формате "junit=path.xml".
+// Несколько отчетов перечисляются через запятую
+func (d *Device) WriteReports(report Report) {
+	if d.Report == "" {
+		return
+	}
+	for _, item := range strings.Split(d.Report, ",") {
+		format, path, err := parseReport(item)
+		if err != nil {
+			fmt.Printf("Report %s: %s\n", item, err)
+			continue
+		}
+		if err := writeReport(format, path, report); err != nil {
+			fmt.Printf("Report %s: %s\n", path, err)
+			continue
+		}
+		fmt.Printf("Report saved: %s\n", path)
+	}
+}
+
+func parseReport(item string) (format string, path string, err error) {
+	parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("expected format=path")
+	}
+	format = strings.ToLower(parts[0])
+	switch format {
+	case ReportJUnit, ReportJSON:
+	default:
+		return "", "", fmt.Errorf("unknown report format %s", parts[0])
+	}
+	return format, parts[1], nil
+}
+
+func writeReport(format string, path string, report Report) error {
+	groups := report.masterGroups()
+	if format == ReportJUnit && groups == nil {
+		return fmt.Errorf("junit report is supported only for master")
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch format {
+	case ReportJUnit:
+		return groups.WriteJUnit(file)
+	case ReportJSON:
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return nil
+}
+
+// Pass - все выполненные тесты прошли успешно
+func (r Report) Pass() bool {
+	if r.Master != nil && !r.Master.Pass() {
+		return false
+	}
+	if r.CustomMaster != nil && !r.CustomMaster.Pass() {
+		return false
+	}
+	for _, test := range r.ModbusSlave {
+		if test.Skip == "" && !test.Pass {
+			return false
+		}
+	}
+	for _, test := range r.CustomSlave {
+		if test.Skip == "" && !test.Pass {
+			return false
+		}
+	}
+	if r.Replay != nil && len(r.Replay.Unmatched) > 0 {
+		return false
+	}
+	return true
+}
+
+// masterGroups - группы тестов всех мастеров для junit
+func (r Report) masterGroups() *master.ReportGroups {
+	switch {
+	case r.Master == nil:
+		return r.CustomMaster
+	case r.CustomMaster == nil:
+		return r.Master
+	}
+	groups := *r.Master
+	groups.ReportGroup = append(append([]master.ReportGroup{}, r.Master.ReportGroup...), r.CustomMaster.ReportGroup...)
+	return &groups
+}
@@ -0,0 +1,369 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/transport"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readyTimeout - максимальное ожидание готовности слейвов перед запуском мастеров
+var readyTimeout = 5 * time.Second
+
+// Режимы работы устройства в конфигурации
+const (
+	RoleModbusMaster = "modbusMaster"
+	RoleModbusSlave  = "modbusSlave"
+	RoleCustomSlave  = "slave"
+	RoleCustomMaster = "master"
+	RoleReplay       = "replay"
+	RoleMonitor      = "monitor"
+)
+
+// role - режим работы устройства на своем порту. В одной конфигурации может быть несколько режимов
+type role struct {
+	device *Device
+	name   string
+	port   *string
+	master bool
+	tcp    bool
+	// run - запускает режим и возвращает код завершения
+	run func(ctx context.Context, r *role) int
+	// stat - статистика выполненных тестов
+	stat func() roleStat
+
+	// Лог и префикс сообщений режима
+	log    logrus.FieldLogger
+	prefix string
+	// Код завершения режима
+	code     int
+	finished bool
+
+	// Закрывается, когда слейв открыл порт или адрес
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// setReady - сообщает о готовности режима принимать запросы
+func (r *role) setReady() {
+	r.readyOnce.Do(func() { close(r.ready) })
+}
+
+// roleStat - количество тестов режима
+type roleStat struct {
+	Total int
+	Fail  int
+	Skip  int
+}
+
+// roles - режимы заданные в конфигурации устройства
+func (d *Device) roles() []*role {
+	var roles []*role
+	if d.ModbusMaster != nil {
+		if d.modbusMasterReport == nil {
+			d.modbusMasterReport = &master.ReportGroups{Name: d.Name, Description: d.Description}
+		}
+		roles = append(roles, &role{
+			device: d,
+			name:   RoleModbusMaster,
+			port:   &d.ModbusMaster.Port,
+			master: true,
+			tcp:    strings.ToLower(d.ModbusMaster.Mode) == master.ModeTCP,
+			run: func(ctx context.Context, r *role) int {
+				d.ModbusMaster.Log = r.log
+				fmt.Printf("%sOpen port: %s\n", r.prefix, d.ModbusMaster.Port)
+				// Запуск тестов
+				if err := d.ModbusMaster.Run(d.modbusMasterReport); err != nil {
+					fmt.Printf("%sExit app modbus master: %s\n", r.prefix, err)
+					return exitCode(err)
+				}
+				return passCode(d.modbusMasterReport.Pass())
+			},
+			stat: func() roleStat { return groupsStat(d.modbusMasterReport) },
+		})
+	}
+	if d.ModbusSlave != nil {
+		roles = append(roles, &role{
+			device: d,
+			name:   RoleModbusSlave,
+			port:   &d.ModbusSlave.Port,
+			tcp:    strings.ToLower(d.ModbusSlave.Mode) == master.ModeTCP,
+			run: func(ctx context.Context, r *role) int {
+				d.ModbusSlave.Log = r.log
+				d.ModbusSlave.OnReady = r.setReady
+				fmt.Printf("%sOpen port: %s\n", r.prefix, d.ModbusSlave.Port)
+				if err := d.ModbusSlave.Run(); err != nil && err != io.EOF {
+					fmt.Printf("%sExit app modbus slave: %s\n", r.prefix, err)
+					return exitCode(err)
+				}
+				return passCode(r.stat().Fail == 0)
+			},
+			stat: func() (stat roleStat) {
+				for _, test := range d.ModbusSlave.Reports() {
+					stat.add(test.Pass, test.Skip)
+				}
+				return stat
+			},
+		})
+	}
+	if d.CustomSlave != nil {
+		roles = append(roles, &role{
+			device: d,
+			name:   RoleCustomSlave,
+			port:   &d.CustomSlave.Port,
+			run: func(ctx context.Context, r *role) int {
+				d.CustomSlave.Log = r.log
+				d.CustomSlave.OnReady = r.setReady
+				fmt.Printf("%sOpen port: %s\n", r.prefix, d.CustomSlave.Port)
+				if err := d.CustomSlave.Run(); err != nil {
+					fmt.Printf("%sExit app slave: %s\n", r.prefix, err)
+					return exitCode(err)
+				}
+				return passCode(r.stat().Fail == 0)
+			},
+			stat: func() (stat roleStat) {
+				for _, test := range d.CustomSlave.Reports() {
+					stat.add(test.Pass, test.Skip)
+				}
+				return stat
+			},
+		})
+	}
+	if d.CustomMaster != nil {
+		if d.customMasterReport == nil {
+			d.customMasterReport = &master.ReportGroups{Name: d.Name, Description: d.Description}
+		}
+		roles = append(roles, &role{
+			device: d,
+			name:   RoleCustomMaster,
+			port:   &d.CustomMaster.Port,
+			master: true,
+			run: func(ctx context.Context, r *role) int {
+				d.CustomMaster.Log = r.log
+				fmt.Printf("%sOpen port: %s\n", r.prefix, d.CustomMaster.Port)
+				// Запуск тестов
+				if err := d.CustomMaster.Run(ctx, d.customMasterReport); err != nil {
+					fmt.Printf("%sExit app master: %s\n", r.prefix, err)
+					return exitCode(err)
+				}
+				return passCode(d.customMasterReport.Pass())
+			},
+			stat: func() roleStat { return groupsStat(d.customMasterReport) },
+		})
+	}
+	if d.Replay != nil {
+		roles = append(roles, &role{
+			device: d,
+			name:   RoleReplay,
+			port:   &d.Replay.Port,
+			run: func(ctx context.Context, r *role) int {
+				d.Replay.Log = r.log
+				d.Replay.OnReady = r.setReady
+				fmt.Printf("%sOpen port: %s\n", r.prefix, d.Replay.Port)
+				if err := d.Replay.Run(); err != nil && err != io.EOF {
+					fmt.Printf("%sExit app replay: %s\n", r.prefix, err)
+					return exitCode(err)
+				}
+				return passCode(r.stat().Fail == 0)
+			},
+			stat: func() roleStat {
+				report := d.Replay.Report()
+				return roleStat{Total: report.Matched + len(report.Unmatched), Fail: len(report.Unmatched)}
+			},
+		})
+	}
+	if d.Monitor != nil {
+		roles = append(roles, &role{
+			device: d,
+			name:   RoleMonitor,
+			port:   &d.Monitor.Port,
+			run: func(ctx context.Context, r *role) int {
+				d.Monitor.Log = r.log
+				d.Monitor.OnReady = r.setReady
+				fmt.Printf("%sOpen port: %s\n", r.prefix, d.Monitor.Port)
+				if err := d.Monitor.Run(); err != nil && err != io.EOF {
+					fmt.Printf("%sExit app monitor: %s\n", r.prefix, err)
+					return exitCode(err)
+				}
+				return ExitPass
+			},
+			// Монитор не выполняет тестов, в сводке количество кадров
+			stat: func() roleStat {
+				frames, _ := d.Monitor.Stat()
+				return roleStat{Total: frames}
+			},
+		})
+	}
+	return roles
+}
+
+func (s *roleStat) add(pass bool, skip string) {
+	switch {
+	case skip != "":
+		s.Skip++
+	case !pass:
+		s.Total++
+		s.Fail++
+	default:
+		s.Total++
+	}
+}
+
+// groupsStat - статистика тестов мастера
+func groupsStat(groups *master.ReportGroups) (stat roleStat) {
+	for _, group := range groups.ReportGroup {
+		for _, test := range group.Tests {
+			stat.add(test.Pass, test.Skip)
+		}
+	}
+	return stat
+}
+
+// title - имя режима в сообщениях и сводке
+func (r *role) title() string {
+	return r.device.title() + "/" + r.name
+}
+
+// resolvePort - заменяет virtual://name путем к концу виртуального порта
+func (r *role) resolvePort() error {
+	if r.tcp || !transport.IsVirtual(*r.port) {
+		return nil
+	}
+	name := *r.port
+	path, err := transport.ResolvePort(name)
+	if err != nil {
+		return err
+	}
+	*r.port = path
+	if peer := transport.VirtualPeer(name); peer != "" {
+		fmt.Printf("%sVirtual port %s: %s, peer: %s\n", r.prefix, name, path, peer)
+	} else {
+		fmt.Printf("%sVirtual port %s: %s\n", r.prefix, name, path)
+	}
+	return nil
+}
+
+// checkPorts - каждый режим работает на своем последовательном порту
+func checkPorts(roles []*role) error {
+	used := make(map[string]*role)
+	for _, r := range roles {
+		if r.tcp || *r.port == "" || transport.IsVirtual(*r.port) {
+			continue
+		}
+		if other, ok := used[*r.port]; ok {
+			return fmt.Errorf("port %s is used by %s and %s", *r.port, other.title(), r.title())
+		}
+		used[*r.port] = r
+	}
+	return nil
+}
+
+// runRoles - запускает режимы одновременно. Слейвы работают до выхода из программы,
+// поэтому ожидается завершение всех мастеров. Без мастеров ожидается завершение слейвов
+func runRoles(ctx context.Context, roles []*role) int {
+	type result struct {
+		role *role
+		code int
+	}
+	results := make(chan result, len(roles))
+	masters := 0
+	for _, r := range roles {
+		if r.master {
+			masters++
+		}
+	}
+	start := func(r *role) (done chan struct{}) {
+		done = make(chan struct{})
+		go func() {
+			code := r.run(ctx, r)
+			close(done)
+			results <- result{role: r, code: code}
+		}()
+		return done
+	}
+
+	// Мастера запускаются после того, как слейвы открыли порты, чтобы не пропустить запросы.
+	// Слейв, завершившийся с ошибкой, или истекшее ожидание не задерживают мастеров
+	dones := make(map[*role]chan struct{})
+	for _, r := range roles {
+		if !r.master {
+			r.ready = make(chan struct{})
+			dones[r] = start(r)
+		}
+	}
+	timeout := time.NewTimer(readyTimeout)
+	defer timeout.Stop()
+	expired := false
+	for _, r := range roles {
+		if r.master || expired {
+			continue
+		}
+		select {
+		case <-r.ready:
+		case <-dones[r]:
+		case <-timeout.C:
+			expired = true
+			fmt.Printf("%s is not ready after %s, start masters\n", r.title(), readyTimeout)
+		}
+	}
+	for _, r := range roles {
+		if r.master {
+			start(r)
+		}
+	}
+
+	code := ExitPass
+	for remaining := len(roles); remaining > 0; remaining-- {
+		res := <-results
+		res.role.code = res.code
+		res.role.finished = true
+		if res.code > code {
+			code = res.code
+		}
+		if res.role.master {
+			masters--
+			if masters == 0 {
+				break
+			}
+		} else if res.code != ExitPass && masters > 0 {
+			// Слейв завершился раньше мастера
+			break
+		}
+	}
+
+	for _, r := range roles {
+		switch {
+		case r.finished:
+			continue
+		case r.master:
+			// Мастер не успел выполнить тесты
+			r.code = ExitFail
+		default:
+			// Работающие слейвы оцениваются по выполненным тестам
+			r.code = passCode(r.stat().Fail == 0)
+		}
+		if r.code > code {
+			code = r.code
+		}
+	}
+	return code
+}
+
+// printSummary - сводка по всем режимам после выполнения тестов
+func printSummary(roles []*role) {
+	fmt.Println("Summary:")
+	for _, r := range roles {
+		if r.master && !r.finished {
+			fmt.Printf("  %s %s: INTERRUPTED\n", r.title(), *r.port)
+			continue
+		}
+		stat := r.stat()
+		fmt.Printf("  %s %s: %s (tests: %d, fail: %d, skip: %d)\n",
+			r.title(), *r.port, codeName(r.code), stat.Total, stat.Fail, stat.Skip)
+	}
+}
@@ -0,0 +1,157 @@
+package e2e
+
+import (
+	"context"
+	"github.com/schnack/gotest"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"rtu-test/e2e/common"
+	"rtu-test/e2e/modbus/master"
+	"rtu-test/e2e/modbus/slave"
+	"rtu-test/e2e/replay"
+	"rtu-test/e2e/transport"
+	"testing"
+)
+
+func TestRun_SeveralRoles(t *testing.T) {
+	if _, err := transport.ResolvePort("virtual://check-roles"); err != nil {
+		t.Skipf("pty is not available: %s", err)
+	}
+	defer transport.CloseVirtual()
+
+	var register uint16 = 0x0102
+	var address uint16 = 0
+	// Слейв и мастер в одной конфигурации, каждый на своем порту
+	d := &Device{
+		Name: "bench",
+		ModbusSlave: &slave.ModbusSlave{
+			SlaveId: 1, Port: "virtual://roles", BoundRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, SilentInterval: "5ms",
+			HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &register}},
+		},
+		ModbusMaster: &master.ModbusMaster{
+			SlaveId: 1, Port: "virtual://roles", BoundRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, Timeout: "1s",
+			Tests: map[string][]*master.ModbusMasterTest{"Default": {{
+				Name: "read", Function: "ReadHoldingRegisters", Address: &address,
+				Expected: []*common.Value{{Name: "param1", Uint16: &register}},
+			}}},
+		},
+	}
+
+	if err := gotest.Expect(Run(context.Background(), d)).Eq(ExitPass); err != nil {
+		t.Error(err)
+	}
+
+	report := d.NewReport()
+	if err := gotest.Expect(len(report.Master.ReportGroup)).Eq(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(report.Pass()).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRun_TcpRoles(t *testing.T) {
+	// Свободный адрес для слейва
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	var register uint16 = 0x0304
+	var start uint16 = 0
+	// Мастер запускается после открытия адреса слейвом, без повторных попыток подключения
+	slaveDevice := &Device{
+		Name: "slave",
+		ModbusSlave: &slave.ModbusSlave{
+			Mode: master.ModeTCP, SlaveId: 1, Port: address,
+			HoldingRegisters: []*common.Value{{Name: "param1", Address: "0x0000", Uint16: &register}},
+		},
+	}
+	masterDevice := &Device{
+		Name: "master",
+		ModbusMaster: &master.ModbusMaster{
+			Mode: master.ModeTCP, SlaveId: 1, Port: address, Timeout: "1s",
+			Tests: map[string][]*master.ModbusMasterTest{"Default": {{
+				Name: "read", Function: "ReadHoldingRegisters", Address: &start,
+				Expected: []*common.Value{{Name: "param1", Uint16: &register}},
+			}}},
+		},
+	}
+
+	if err := gotest.Expect(Run(context.Background(), slaveDevice, masterDevice)).Eq(ExitPass); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(masterDevice.NewReport().Pass()).True(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckPorts(t *testing.T) {
+	first := &Device{Name: "first", ModbusMaster: &master.ModbusMaster{Port: "/dev/ttyUSB0"}}
+	second := &Device{Name: "second", ModbusSlave: &slave.ModbusSlave{Port: "/dev/ttyUSB0"}}
+	if err := gotest.Expect(checkPorts(append(first.roles(), second.roles()...))).NotNil(); err != nil {
+		t.Error(err)
+	}
+
+	second.ModbusSlave.Port = "/dev/ttyUSB1"
+	if err := gotest.Expect(checkPorts(append(first.roles(), second.roles()...))).Nil(); err != nil {
+		t.Error(err)
+	}
+
+	// tcp мастер и слейв используют один адрес
+	first.ModbusMaster.Mode = master.ModeTCP
+	second.ModbusSlave.Mode = master.ModeTCP
+	first.ModbusMaster.Port = "127.0.0.1:502"
+	second.ModbusSlave.Port = "127.0.0.1:502"
+	if err := gotest.Expect(checkPorts(append(first.roles(), second.roles()...))).Nil(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoleStat(t *testing.T) {
+	groups := &master.ReportGroups{ReportGroup: []master.ReportGroup{{Tests: []master.ReportMasterTest{
+		{Pass: true},
+		{Pass: false},
+		{Pass: true, Skip: "skip"},
+	}}}}
+	if err := gotest.Expect(groupsStat(groups)).Eq(roleStat{Total: 2, Fail: 1, Skip: 1}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRun_Replay(t *testing.T) {
+	if _, err := transport.ResolvePort("virtual://check-replay"); err != nil {
+		t.Skipf("pty is not available: %s", err)
+	}
+	defer transport.CloseVirtual()
+
+	file := filepath.Join(t.TempDir(), "traffic.log")
+	record := "2021-03-01T10:00:00.000000Z TX 01 03 00 00 00 01 84 0a\n" +
+		"2021-03-01T10:00:00.010000Z RX 01 03 02 01 02 38 15\n"
+	if err := ioutil.WriteFile(file, []byte(record), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var register uint16 = 0x0102
+	var address uint16 = 0
+	replayDevice := &Device{Replay: &replay.Replay{
+		Port: "virtual://replay", BoundRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, File: file, Timing: true,
+	}}
+	masterDevice := &Device{ModbusMaster: &master.ModbusMaster{
+		SlaveId: 1, Port: "virtual://replay", BoundRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, Timeout: "1s",
+		Tests: map[string][]*master.ModbusMasterTest{"Default": {{
+			Name: "read", Function: "ReadHoldingRegisters", Address: &address,
+			Expected: []*common.Value{{Name: "param1", Uint16: &register}},
+		}}},
+	}}
+
+	if err := gotest.Expect(Run(context.Background(), replayDevice, masterDevice)).Eq(ExitPass); err != nil {
+		t.Error(err)
+	}
+	if err := gotest.Expect(replayDevice.Replay.Report().Matched).Eq(1); err != nil {
+		t.Error(err)
+	}
+}
@@ -45,7 +45,11 @@ const TestSlaveCustomSKIP = `--- SKIP       {{.Name}}
 const TestSlaveCustomRUN = `=== RUN        {{.Name}}`
 const TestSlaveCustomPASS = `--- PASS:      {{.Name}}`
 const TestSlaveCustomFAIL = `--- FAIL:      {{.Name}}
-{{range .Expected}}{{with .Pass}}{{else}}    {{.Name}}:
+{{with .Crc}}{{with .Pass}}{{else}}    {{.Name}}:
+
+            expected: ({{.Type}}) {{.Expected}}
+                 got: ({{.Type}}) {{.Got}}
+{{end}}{{end}}{{range .Expected}}{{with .Pass}}{{else}}    {{.Name}}:
 
             expected: ({{.Type}}) {{.Expected}}
                  got: ({{.Type}}) {{.Got}}
@@ -17,6 +17,14 @@ type SerialPortConfig struct {
 	Timeout        time.Duration
 }
 
+// SilentInterval - пауза между кадрами в 3.5 символа по 11 бит. Для скорости выше 19200 - 1750us
+func SilentInterval(baudRate int) time.Duration {
+	if baudRate <= 0 || baudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return time.Duration(38500000/baudRate) * time.Microsecond
+}
+
 type SerialPort interface {
 	Connect() (err error)
 	Close() error
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Префикс виртуального порта. Два конфига с одинаковым virtual://name соединяются между собой
+const VirtualPrefix = "virtual://"
+
+var virtualPorts = make(map[string]*virtualPort)
+var muVirtualPorts sync.Mutex
+
+// IsVirtual - порт задан как virtual://name
+func IsVirtual(port string) bool {
+	return strings.HasPrefix(port, VirtualPrefix)
+}
+
+// ResolvePort - для virtual://name создает пару соединенных портов и возвращает путь к одному из концов.
+// Первый вызов получает первый конец, второй - второй. Остальные порты возвращаются без изменений
+func ResolvePort(port string) (string, error) {
+	if !IsVirtual(port) {
+		return port, nil
+	}
+	name := strings.TrimPrefix(port, VirtualPrefix)
+
+	muVirtualPorts.Lock()
+	defer muVirtualPorts.Unlock()
+	vp, ok := virtualPorts[name]
+	if !ok {
+		var err error
+		if vp, err = newVirtualPort(); err != nil {
+			return "", fmt.Errorf("virtual port %s: %s", name, err)
+		}
+		virtualPorts[name] = vp
+	}
+	if vp.next >= len(vp.paths) {
+		return "", fmt.Errorf("virtual port %s: both ends are already in use", name)
+	}
+	path := vp.paths[vp.next]
+	vp.next++
+	return path, nil
+}
+
+// VirtualPeer - путь к свободному концу виртуального порта для подключения внешней программы
+func VirtualPeer(port string) string {
+	muVirtualPorts.Lock()
+	defer muVirtualPorts.Unlock()
+	vp, ok := virtualPorts[strings.TrimPrefix(port, VirtualPrefix)]
+	if !ok || vp.next >= len(vp.paths) {
+		return ""
+	}
+	return vp.paths[vp.next]
+}
+
+// CloseVirtual - закрывает все виртуальные порты
+func CloseVirtual() {
+	muVirtualPorts.Lock()
+	defer muVirtualPorts.Unlock()
+	for name, vp := range virtualPorts {
+		vp.Close()
+		delete(virtualPorts, name)
+	}
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// virtualPort - пара pty, данные между которыми копируются в обе стороны.
+// Концы доступны как обычные последовательные порты /dev/pts/N
+type virtualPort struct {
+	paths   [2]string
+	masters [2]*os.File
+	next    int
+}
+
+func newVirtualPort() (*virtualPort, error) {
+	vp := &virtualPort{}
+	for i := range vp.masters {
+		master, path, err := openPty()
+		if err != nil {
+			vp.Close()
+			return nil, err
+		}
+		vp.masters[i] = master
+		vp.paths[i] = path
+	}
+	go vp.bridge(vp.masters[0], vp.masters[1])
+	go vp.bridge(vp.masters[1], vp.masters[0])
+	return vp, nil
+}
+
+// bridge - копирует данные из одного pty в другой
+func (vp *virtualPort) bridge(src, dst *os.File) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, syscall.EIO):
+			// Порт со стороны программы еще не открыт или уже закрыт
+			time.Sleep(10 * time.Millisecond)
+		case errors.Is(err, io.EOF), errors.Is(err, os.ErrClosed):
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (vp *virtualPort) Close() {
+	for _, master := range vp.masters {
+		if master != nil {
+			master.Close()
+		}
+	}
+}
+
+// openPty - открывает новый pty и переводит его в raw режим
+func openPty() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	var number uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	path := fmt.Sprintf("/dev/pts/%d", number)
+
+	// Без raw режима pty возвращает эхо и преобразует символы конца строки
+	slave, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	defer slave.Close()
+	if err := makeRaw(slave.Fd()); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	return master, path, nil
+}
+
+func makeRaw(fd uintptr) error {
+	var t syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); err != nil {
+		return err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	return ioctl(fd, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"github.com/schnack/gotest"
+	"testing"
+	"time"
+)
+
+func TestResolvePort(t *testing.T) {
+	defer CloseVirtual()
+
+	first, err := ResolvePort("virtual://bench1")
+	if err != nil {
+		t.Skipf("pty is not available: %s", err)
+	}
+	if err := gotest.Expect(VirtualPeer("virtual://bench1") != "").True(); err != nil {
+		t.Error(err)
+	}
+	second, err := ResolvePort("virtual://bench1")
+	if err := gotest.Expect(err).Nil(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolvePort("virtual://bench1"); err == nil {
+		t.Error("expected error for the third end")
+	}
+	if path, _ := ResolvePort("/dev/ttyUSB0"); path != "/dev/ttyUSB0" {
+		t.Errorf("unexpected path %s", path)
+	}
+
+	portA := NewSerialPort(&SerialPortConfig{Port: first, BaudRate: 9600, DataBits: 8, StopBits: 1})
+	portB := NewSerialPort(&SerialPortConfig{Port: second, BaudRate: 9600, DataBits: 8, StopBits: 1})
+	defer portA.Close()
+	defer portB.Close()
+
+	if _, err := portA.Write([]byte{0x01, 0x0a, 0x0d, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan []byte)
+	go func() {
+		var data []byte
+		buf := make([]byte, 16)
+		for len(data) < 4 {
+			n, err := portB.Read(buf)
+			if err != nil {
+				return
+			}
+			data = append(data, buf[:n]...)
+		}
+		result <- data
+	}()
+
+	select {
+	case data := <-result:
+		// Данные передаются без изменений
+		if err := gotest.Expect(data).Eq([]byte{0x01, 0x0a, 0x0d, 0x03}); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("timeout")
+	}
+}
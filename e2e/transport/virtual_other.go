@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package transport
+
+import "fmt"
+
+type virtualPort struct {
+	paths [2]string
+	next  int
+}
+
+func newVirtualPort() (*virtualPort, error) {
+	return nil, fmt.Errorf("virtual ports are supported only on linux")
+}
+
+func (vp *virtualPort) Close() {}
@@ -0,0 +1,180 @@
+package e2e
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"rtu-test/e2e/common"
+	"strconv"
+	"strings"
+)
+
+// Problem - ошибка в файле конфигурации
+type Problem struct {
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+var validatorType = reflect.TypeOf((*common.Validator)(nil)).Elem()
+
+// Validate - строгая проверка файла конфигурации: неизвестные поля, ошибки типов
+// и проверки Validate() загружаемых объектов
+func Validate(fileName string) ([]Problem, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateData(data), nil
+}
+
+func ValidateData(data []byte) (problems []Problem) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []Problem{{Message: err.Error()}}
+	}
+
+	// Ошибки типов содержат номер строки
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&Device{}); err != nil {
+		var typeError *yaml.TypeError
+		if errors.As(err, &typeError) {
+			for _, e := range typeError.Errors {
+				problems = append(problems, problemFromYaml(e))
+			}
+		} else {
+			problems = append(problems, Problem{Message: err.Error()})
+		}
+	}
+
+	walkNode(&root, reflect.TypeOf(Device{}), &problems)
+	return problems
+}
+
+// problemFromYaml - выделяет номер строки из ошибки yaml вида "line 5: ..."
+func problemFromYaml(message string) Problem {
+	if strings.HasPrefix(message, "line ") {
+		parts := strings.SplitN(strings.TrimPrefix(message, "line "), ": ", 2)
+		if line, err := strconv.Atoi(parts[0]); err == nil && len(parts) == 2 {
+			return Problem{Line: line, Message: parts[1]}
+		}
+	}
+	return Problem{Message: message}
+}
+
+func walkNode(node *yaml.Node, t reflect.Type, problems *[]Problem) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			walkNode(n, t, problems)
+		}
+		return
+	case yaml.AliasNode:
+		walkNode(node.Alias, t, problems)
+		return
+	}
+
+	if reflect.PtrTo(t).Implements(validatorType) && node.Kind == yaml.MappingNode {
+		value := reflect.New(t)
+		// Ошибки типов уже выведены при загрузке всего файла
+		if err := node.Decode(value.Interface()); err == nil {
+			for _, err := range value.Interface().(common.Validator).Validate() {
+				*problems = append(*problems, newProblem(node, err))
+			}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				*problems = append(*problems, Problem{Line: key.Line, Message: fmt.Sprintf("unknown field %s", key.Value)})
+				continue
+			}
+			walkNode(value, fieldType, problems)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			walkNode(node.Content[i], t.Elem(), problems)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, n := range node.Content {
+			walkNode(n, t.Elem(), problems)
+		}
+	}
+}
+
+// yamlFields - поля структуры по именам yaml
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// newProblem - находит строку поля с ошибкой
+func newProblem(node *yaml.Node, err error) Problem {
+	var fieldError *common.FieldError
+	if !errors.As(err, &fieldError) || fieldError.Path == "" {
+		return Problem{Line: node.Line, Message: err.Error()}
+	}
+	line := node.Line
+	for _, part := range strings.Split(fieldError.Path, ".") {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == part {
+					next = node.Content[i+1]
+					line = node.Content[i].Line
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(part); err == nil && index < len(node.Content) {
+				next = node.Content[index]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return Problem{Line: line, Message: err.Error()}
+}
@@ -0,0 +1,76 @@
+package e2e
+
+import (
+	"github.com/schnack/gotest"
+	"testing"
+)
+
+func TestValidateData(t *testing.T) {
+	config := `
+name: bad
+modbusMaster:
+  boundrate: 9600
+  tests:
+    Default:
+      - name: t1
+        function: read holdings
+        address: 1
+      - name: t2
+        function: ReadHoldingRegisters
+        expected:
+          - name: x
+            uint16: 1
+            bool: true
+slave:
+  readFormat: [start, data#]
+  test:
+    - name: a
+      next: [b]
+`
+	problems := ValidateData([]byte(config))
+	lines := make([]int, 0, len(problems))
+	for _, p := range problems {
+		lines = append(lines, p.Line)
+	}
+	if err := gotest.Expect(lines).Eq([]int{4, 8, 10, 13, 17, 20}); err != nil {
+		t.Error(err, problems)
+	}
+	if err := gotest.Expect(problems[0].String()).Eq("line 4: unknown field boundrate"); err != nil {
+		t.Error(err)
+	}
+
+	// Ошибка типа
+	problems = ValidateData([]byte("modbusMaster:\n  slaveId: abc\n"))
+	if err := gotest.Expect(len(problems)).Eq(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := gotest.Expect(problems[0].Line).Eq(2); err != nil {
+		t.Error(err)
+	}
+
+	// Ссылки на карту регистров
+	config = `
+registers:
+  voltage: {address: 0x0010, type: float32}
+  alarm: {table: coil, address: 1}
+modbusMaster:
+  tests:
+    Default:
+      - name: t1
+        read: [voltage, current]
+        expected:
+          voltage: {min: 220, mx: 240}
+`
+	problems = ValidateData([]byte(config))
+	lines = lines[:0]
+	for _, p := range problems {
+		lines = append(lines, p.Line)
+	}
+	if err := gotest.Expect(lines).Eq([]int{9, 11, 4}); err != nil {
+		t.Error(err, problems)
+	}
+
+	if err := gotest.Expect(len(ValidateData([]byte("name: ok\nmodbusSlave:\n  port: /dev/ttyUSB0\n")))).Eq(0); err != nil {
+		t.Error(err)
+	}
+}
@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/goburrow/modbus v0.1.0
+	github.com/goburrow/serial v0.1.0
 	github.com/schnack/gotest v0.7.1
 	github.com/schnack/mbslave v0.2.1
 	github.com/shiena/ansicolor v0.0.0-20200904210342-c7312218db18
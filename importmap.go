@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"rtu-test/e2e"
+	"rtu-test/e2e/registers"
+	"strings"
+)
+
+// runImportMap - команда import-map: создает из карты регистров в csv конфигурацию слейва
+// с начальными значениями таблиц и конфигурацию мастера с тестами чтения каждой точки.
+// Файлы создаются рядом с csv или с префиксом из второго аргумента, существующие файлы не заменяются
+func runImportMap(args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Println("usage: rtu-test import-map map.csv [prefix]")
+		return e2e.ExitConfig
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		fmt.Printf("Open map: %s\n", err)
+		return e2e.ExitConfig
+	}
+	defer file.Close()
+
+	points, err := registers.ReadCSV(file)
+	if err != nil {
+		fmt.Printf("Parse map %s: %s\n", args[0], err)
+		return e2e.ExitConfig
+	}
+	if len(points) == 0 {
+		fmt.Printf("Map %s has no registers\n", args[0])
+		return e2e.ExitConfig
+	}
+
+	prefix := strings.TrimSuffix(args[0], filepath.Ext(args[0]))
+	if len(args) == 2 {
+		prefix = args[1]
+	}
+	title := filepath.Base(strings.TrimSuffix(args[0], filepath.Ext(args[0])))
+	slaveFile, masterFile := prefix+"_slave.yml", prefix+"_master.yml"
+	for _, name := range []string{slaveFile, masterFile} {
+		if _, err := os.Stat(name); err == nil {
+			fmt.Printf("File %s already exists\n", name)
+			return e2e.ExitConfig
+		}
+	}
+
+	for _, out := range []struct {
+		name  string
+		write func(f *os.File) error
+	}{
+		{slaveFile, func(f *os.File) error { return registers.WriteSlave(f, title, points) }},
+		{masterFile, func(f *os.File) error { return registers.WriteMaster(f, title, points) }},
+	} {
+		f, err := os.Create(out.name)
+		if err != nil {
+			fmt.Printf("Create %s: %s\n", out.name, err)
+			return e2e.ExitConfig
+		}
+		err = out.write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fmt.Printf("Write %s: %s\n", out.name, err)
+			return e2e.ExitConfig
+		}
+	}
+
+	fmt.Printf("Imported %d registers: %s, %s\n", len(points), slaveFile, masterFile)
+	fmt.Printf("Run without hardware: rtu-test loopback %s %s\n", slaveFile, masterFile)
+	return e2e.ExitPass
+}
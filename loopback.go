@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"rtu-test/e2e"
+)
+
+// runLoopback - команда loopback: запускает конфигурации в одном процессе,
+// соединяя их виртуальным портом. Каждый файл - отдельное устройство
+func runLoopback(fileNames []string, logs string, logLvl string) int {
+	if len(fileNames) < 2 {
+		fmt.Println("usage: rtu-test loopback slave.yml master.yml")
+		return e2e.ExitConfig
+	}
+	if code := validateFiles(fileNames); code != e2e.ExitPass {
+		return code
+	}
+
+	devices := make([]*e2e.Device, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		d := &e2e.Device{}
+		if err := d.Load(fileName); err != nil {
+			fmt.Printf("Loading configuration: %s\nError: %s\n", fileName, err)
+			return e2e.ExitConfig
+		}
+		fmt.Printf("Loading configuration: %s\n", fileName)
+		if logs != "" {
+			d.Log = logs
+		}
+		if logLvl != "" {
+			d.LogLvl = logLvl
+		}
+		devices = append(devices, d)
+	}
+	return e2e.RunLoopback(context.Background(), devices...)
+}
@@ -17,62 +17,131 @@ func main() {
 	var filter = flag.String("f", "", "filter")
 	var logs = flag.String("l", "", "log")
 	var logLvl = flag.String("lvl", "", "logLvl")
+	var report = flag.String("report", "", "report files, e.g. junit=report.xml,json=report.json")
 	var help = flag.Bool("h", false, "help")
+	flag.Usage = usage
 	flag.Parse()
 
 	if *help {
-		flag.PrintDefaults()
+		flag.Usage()
 		os.Exit(0)
 	}
 
-	d := e2e.Init()
 	fileNames := flag.Args()
 
+	// Проверка конфигурации без запуска тестов
+	if len(fileNames) > 0 && fileNames[0] == "validate" {
+		if len(fileNames) == 1 {
+			fileNames = append(fileNames, "test.yml")
+		}
+		os.Exit(validateFiles(fileNames[1:]))
+	}
+
+	// Запуск нескольких конфигураций, соединенных виртуальным портом
+	if len(fileNames) > 0 && fileNames[0] == "loopback" {
+		logrus.Exit(runLoopback(fileNames[1:], *logs, *logLvl))
+	}
+
+	// Конфигурации слейва и мастера из карты регистров в csv
+	if len(fileNames) > 0 && fileNames[0] == "import-map" {
+		os.Exit(runImportMap(fileNames[1:]))
+	}
+
+	// Пассивный анализатор шины
+	if len(fileNames) > 0 && fileNames[0] == "monitor" {
+		logrus.Exit(runMonitor(fileNames[1:], *comport, *logs, *logLvl))
+	}
+
 	if len(fileNames) == 0 {
 		fileNames = append(fileNames, "test.yml")
 	}
 
-	// Загружаем конфигурацию
+	if err := singleDeviceFlags(fileNames, *comport, *report); err != nil {
+		fmt.Println(err)
+		os.Exit(e2e.ExitConfig)
+	}
+
+	// Перед запуском конфигурация проверяется полностью
+	if code := validateFiles(fileNames); code != e2e.ExitPass {
+		os.Exit(code)
+	}
+
+	// Загружаем конфигурацию. Каждый файл - отдельное устройство со своими портами и отчетами
+	devices := make([]*e2e.Device, 0, len(fileNames))
 	for _, fileName := range fileNames {
+		d := &e2e.Device{}
 		if err := d.Load(fileName); err != nil {
-			fmt.Printf("Loading configuration: %s\nError: %s", fileName, err)
-			return
+			fmt.Printf("Loading configuration: %s\nError: %s\n", fileName, err)
+			os.Exit(e2e.ExitConfig)
 		} else {
 			fmt.Printf("Loading configuration: %s\n", fileName)
 		}
+		devices = append(devices, d)
 	}
 
-	// Заменяем путь для вывода лога
-	if *logs != "" {
-		d.Log = *logs
-	}
+	for _, d := range devices {
+		// Заменяем путь для вывода лога
+		if *logs != "" {
+			d.Log = *logs
+		}
 
-	// Заменяем уровень лога
-	if *logLvl != "" {
-		d.LogLvl = *logLvl
-	}
+		// Заменяем уровень лога
+		if *logLvl != "" {
+			d.LogLvl = *logLvl
+		}
 
-	// Заменяем comport
-	if *comport != "" {
-		switch {
-		case d.ModbusMaster != nil:
-			d.ModbusMaster.Port = *comport
-		case d.ModbusSlave != nil:
-			d.ModbusSlave.Port = *comport
-		case d.CustomSlave != nil:
-			d.CustomSlave.Port = *comport
+		// Заменяем фильтр
+		if *filter != "" {
+			if d.ModbusMaster != nil {
+				d.ModbusMaster.Filter = *filter
+			}
+			if d.CustomMaster != nil {
+				d.CustomMaster.Filter = *filter
+			}
 		}
-	}
 
-	// Заменяем фильтр
-	if *filter != "" {
-		if d.ModbusMaster != nil {
-			d.ModbusMaster.Filter = *filter
+		// Заменяем файлы отчетов
+		if *report != "" {
+			d.Report = *report
+		}
+
+		// Заменяем comport
+		if *comport != "" {
+			d.SetPort(*comport)
 		}
 	}
 
-	// Запускаем тесты
-	d.RunTest(context.Background())
-	//
-	logrus.Exit(0)
+	// Запускаем тесты всех устройств одновременно, код завершения отражает результат
+	logrus.Exit(e2e.Run(context.Background(), devices...))
+}
+
+func usage() {
+	fmt.Fprint(flag.CommandLine.Output(), `Usage:
+  rtu-test [flags] [file.yml ...]
+  rtu-test validate [file.yml ...]
+  rtu-test loopback file.yml ...
+  rtu-test monitor [file.yml ...]
+  rtu-test import-map map.csv [prefix]
+
+Each file is a separate device with its own ports and reports, files are not merged into one device.
+Log and console settings are taken from the flags or the first file.
+Flags -p and -report are allowed only with a single file.
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+// singleDeviceFlags - порт и файлы отчетов задаются одному устройству, для нескольких файлов флаги не применимы
+func singleDeviceFlags(fileNames []string, comport string, report string) error {
+	if len(fileNames) < 2 {
+		return nil
+	}
+	if comport != "" {
+		return fmt.Errorf("flag -p is allowed only with a single configuration file, got %d", len(fileNames))
+	}
+	if report != "" {
+		return fmt.Errorf("flag -report is allowed only with a single configuration file, got %d", len(fileNames))
+	}
+	return nil
 }
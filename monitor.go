@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"rtu-test/e2e"
+)
+
+// runMonitor - команда monitor: запускает только пассивный анализатор шины из конфигураций,
+// остальные режимы не запускаются
+func runMonitor(fileNames []string, comport string, logs string, logLvl string) int {
+	if len(fileNames) == 0 {
+		fileNames = append(fileNames, "monitor.yml")
+	}
+	if err := singleDeviceFlags(fileNames, comport, ""); err != nil {
+		fmt.Println(err)
+		return e2e.ExitConfig
+	}
+	if code := validateFiles(fileNames); code != e2e.ExitPass {
+		return code
+	}
+
+	devices := make([]*e2e.Device, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		d := &e2e.Device{}
+		if err := d.Load(fileName); err != nil {
+			fmt.Printf("Loading configuration: %s\nError: %s\n", fileName, err)
+			return e2e.ExitConfig
+		}
+		fmt.Printf("Loading configuration: %s\n", fileName)
+		if d.Monitor == nil {
+			fmt.Printf("Configuration %s has no monitor section\n", fileName)
+			return e2e.ExitConfig
+		}
+		d.ModbusMaster, d.ModbusSlave, d.CustomMaster, d.CustomSlave, d.Replay = nil, nil, nil, nil, nil
+		if logs != "" {
+			d.Log = logs
+		}
+		if logLvl != "" {
+			d.LogLvl = logLvl
+		}
+		devices = append(devices, d)
+	}
+	if comport != "" {
+		devices[0].SetPort(comport)
+	}
+	return e2e.Run(context.Background(), devices...)
+}
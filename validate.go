@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"rtu-test/e2e"
+)
+
+// validateFiles - проверяет файлы конфигурации и выводит все найденные ошибки.
+// Возвращает код завершения программы
+func validateFiles(fileNames []string) int {
+	code := e2e.ExitPass
+	for _, fileName := range fileNames {
+		problems, err := e2e.Validate(fileName)
+		if err != nil {
+			fmt.Printf("%s: %s\n", fileName, err)
+			code = e2e.ExitConfig
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", fileName, problem)
+		}
+		if len(problems) > 0 {
+			code = e2e.ExitConfig
+		}
+	}
+	return code
+}